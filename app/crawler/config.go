@@ -0,0 +1,71 @@
+package crawler
+
+import (
+	"net/http"
+	"time"
+)
+
+// Stdout is the Output value that writes the results to standard output
+const Stdout = "-"
+
+// Config defines the parameters of a crawl
+// Fields mirror the command line options, zero values select the defaults of the engine
+type Config struct {
+	Site                    []string      // Site URLs the crawl starts from, on the same or different hosts (at least one required)
+	Type                    []string      // Document types / file name extensions to analyse
+	Sniff                   bool          // Detect the type of URLs without a document extension from their Content-Type
+	Include                 []string      // Regular expressions (or "glob:" path globs) of URLs to keep, all others are skipped
+	Exclude                 []string      // Regular expressions (or "glob:" path globs) of URLs to skip, neither crawled nor analysed
+	PathPrefix              string        // Path URLs must start with to be crawled or analysed, e.g. /docs/ (any path if empty)
+	Output                  string        // Output file name, Stdout for standard output (nothing is written if empty)
+	ErrorOutput             string        // File name the records of documents that failed are written to, Stdout for standard output (none if empty)
+	Format                  string        // Output format: json (default) or ndjson
+	Pretty                  bool          // Indent the JSON output
+	WithSummary             bool          // Write the summary of the run as the last element of the output, under a "_summary" key
+	SummaryFile             string        // File name the summary of the run is written to as a JSON object, Stdout for standard output (none if empty)
+	SplitByType             bool          // Write the documents of each type to a file of their own, e.g. output.pdf.json for output.json
+	SortBy                  string        // Field the documents are sorted by: url (default), title or modified
+	Wrap                    bool          // Write the documents in an object with the site, start time and count, under "documents"
+	Stream                  bool          // Write the documents of the JSON output as they are analysed, unsorted, instead of at the end
+	ReportErrors            bool          // Print the documents that failed to be analysed and why to stderr
+	Webhook                 string        // URL the metadata JSON of each analysed document is posted to (none if empty)
+	WebhookConcurrency      int           // Number of concurrent POST requests to the webhook (4 if zero)
+	WebhookOnly             bool          // Post the documents to the webhook without writing the output
+	Paramax                 int           // Maximum number of parallel threads
+	Delay                   time.Duration // Minimum delay between requests to the same host
+	Timeout                 time.Duration // HTTP request timeout (per-client defaults if zero)
+	MaxSize                 string        // Maximum document size, e.g. 250M or bytes (unlimited if zero)
+	TempDir                 string        // Directory of the temporary files of the downloads (OS temp directory if empty)
+	RangeRequests           bool          // Read large OOXML documents by HTTP range requests where the server supports them
+	Depth                   int           // Maximum number of clicks from the site page, 1 scans the site page only (unlimited if zero)
+	MaxPages                int           // Maximum number of pages fetched while crawling (unlimited if zero)
+	MaxDocs                 int           // Number of documents analysed successfully after which no new analysis starts (unlimited if zero)
+	MaxDuration             time.Duration // Maximum duration of the crawl and analysis together (unlimited if zero)
+	Sitemap                 bool          // Seed the crawl with the pages listed in the site's sitemap.xml
+	URLFile                 string        // File listing URLs to analyse whether the crawl finds them or not, one per line (# starts a comment)
+	NoCrawl                 bool          // Only analyse the site and listed URLs, following no links
+	IncludeSubdomains       bool          // Crawl every host within the registrable domains of the site pages, e.g. docs.example.com for www.example.com
+	AnalyseExternal         bool          // Keep the documents of the requested types linked on hosts that are not crawled whatever the PathPrefix
+	RespectNofollow         bool          // Follow no links of tags marked rel="nofollow"
+	Scheme                  []string      // URL schemes links are followed and documents fetched over (http and https if empty)
+	HTTPSOnly               bool          // Reject plain http links, whatever the schemes
+	NoNormalize             bool          // Tell URLs apart by their exact spelling instead of their normalized form
+	Dedup                   bool          // Report documents with the same content (SHA-256) once, at the first URL analysed
+	LowMemory               bool          // Keep a hash of each crawled URL instead of the URL, and only candidate documents in full
+	StateFile               string        // File the progress of the run is saved to and resumed from (none if empty)
+	Manifest                string        // File recording the analysed documents, so the next run only analyses those that changed (none if empty)
+	User                    string        // User name for HTTP basic authentication on the site
+	Password                string        // Password for HTTP basic authentication on the site
+	Header                  []string      // Additional request headers "Name: Value" sent to the site
+	Cookie                  []string      // Cookies "name=value; name2=value2" sent to the site, e.g. a session cookie
+	UserAgent               string        // User-Agent header sent with every request
+	Retries                 int           // Number of retries after a network error or 5xx response
+	RetryWait               time.Duration // Wait before the first retry, doubled for every next one
+	FollowExternalRedirects bool          // Follow redirects from the site to other hosts (refused and recorded as failures if false)
+	Proxy                   string        // URL of the proxy every request is sent through, in place of HTTP_PROXY and the like (environment if empty)
+	Progress                string        // Status line refreshed on stderr: auto (only if a terminal) or force (none if empty)
+	LogLevel                string        // Level of the events logged to stderr: debug, info, warn, error or quiet (quiet if empty)
+	LogFormat               string        // Format of the events logged to stderr: text or json (text if empty)
+	Logger                  Logger        // Logger receiving the events instead, LogLevel is ignored if set
+	HTTPClient              *http.Client  // Base client of every request, providing the transport, proxy and cookie jar (a plain client if nil)
+}
@@ -0,0 +1,185 @@
+package crawler
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"docscrawler/app/fetch"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Map of HTML tags to their attributes holding links followed by harv
+// Extend it to collect links from more tags or attributes, e.g. "button": {"data-href"}
+var linkAttrs = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"link":   {"href"},
+	"iframe": {"src"},
+}
+
+// harv (harvest) extracts all links from the HTML document at the provided URL
+// and adds them to the URL storage for further processing
+// Links are read from the tag attributes listed in linkAttrs
+// and resolved against the <base href> of the page if it declares one
+// Links are stored one level deeper than the page they were found on; pages that
+// already sit at maxDepth are not harvested at all (maxDepth 0 = unlimited)
+// Links rejected by the filter are not stored, nor with respectNofollow those of tags
+// whose rel attribute lists nofollow, e.g. rel="nofollow noopener"
+// Reports whether an HTML page was fetched, which URLs skipped at maxDepth, failed requests
+// and documents are not; returns an error if the page cannot be fetched or read
+func harv(ctx context.Context, client *fetch.Client, baseUrl *url.URL, urlStorage *tUrlStorage, filter *tUrlFilter, maxDepth int, respectNofollow bool, logger Logger) (fetched bool, err error) {
+	// Pages not in storage (the seed) are at depth 0
+	depth, _ := urlStorage.depth(baseUrl)
+	if maxDepth > 0 && depth >= maxDepth {
+		logger.Debug("url skipped", "url", baseUrl, "reason", "depth limit")
+		return false, nil
+	}
+
+	resp, err := client.Get(ctx, baseUrl.String())
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	// Check if the response is successful
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to fetch page: status code %d", resp.StatusCode)
+	}
+	fetched = isHtml(resp)
+	logger.Info("page fetched", "url", baseUrl, "depth", depth)
+
+	body, err := decodeBody(resp)
+	if err != nil {
+		return fetched, err
+	}
+	defer body.Close()
+
+	// Links are resolved against the page URL, or the <base href> of the page once declared
+	linkBase := baseUrl.String()
+	baseFound := false
+
+	// Parse HTML content
+	z := html.NewTokenizer(body)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			// End of document, or the body could not be read to the end
+			if z.Err() == io.EOF {
+				return fetched, nil
+			}
+			return fetched, z.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+
+			// Only the first <base> with an href counts, as in browsers
+			if token.Data == "base" && !baseFound {
+				for _, attr := range token.Attr {
+					if attr.Key != "href" {
+						continue
+					}
+					baseFound = true
+					if u, err := resolveUrl(baseUrl.String(), attr.Val); err == nil {
+						linkBase = u.String()
+					}
+				}
+			}
+
+			if respectNofollow && isNofollow(token) {
+				continue
+			}
+
+			// Look for the link attributes of the tag
+			keys := linkAttrs[token.Data]
+			for _, attr := range token.Attr {
+				if !slices.Contains(keys, attr.Key) {
+					continue
+				}
+
+				// Handle relative URLs
+				url, err := resolveUrl(linkBase, attr.Val)
+				if err != nil {
+					continue
+				}
+				if !filter.allow(url) {
+					logger.Debug("url skipped", "url", url, "reason", "excluded by filter")
+					continue
+				}
+
+				// Add link to results if it's new
+				if urlStorage.addDepth(url, depth+1) {
+					logger.Debug("url discovered", "url", url, "page", baseUrl)
+				}
+			}
+		}
+	}
+}
+
+// isHtml reports whether the response is an HTML page by its Content-Type
+func isHtml(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// isNofollow reports whether the rel attribute of the tag lists nofollow
+// Rel values are space-separated and case-insensitive
+func isNofollow(token html.Token) bool {
+	for _, attr := range token.Attr {
+		if attr.Key == "rel" && slices.ContainsFunc(strings.Fields(attr.Val), func(v string) bool {
+			return strings.EqualFold(v, "nofollow")
+		}) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeBody returns the response body decompressed according to its Content-Encoding
+// The client only decompresses gzip it asked for itself, and drops the header when it does;
+// servers compressing regardless of the request, or with deflate, are handled here
+// Deflate is expected to be zlib-wrapped, as specified, but raw deflate data is also accepted
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(resp.Body)
+	case "deflate":
+		br := bufio.NewReader(resp.Body)
+		header, err := br.Peek(2)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read deflate stream: %w", err)
+		}
+		// A zlib header declares the deflate method and is a multiple of 31
+		if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+// resolveUrl converts a relative URL to an absolute URL using the base URL
+// Returns a parsed URL object or an error if parsing fails
+func resolveUrl(baseStr string, href string) (*url.URL, error) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(baseStr)
+	if err != nil {
+		return nil, err
+	}
+	return base.ResolveReference(u), nil
+}
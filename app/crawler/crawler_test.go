@@ -0,0 +1,407 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"docscrawler/app/fetch"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveUrl(t *testing.T) {
+	testCases := []struct {
+		name     string
+		baseURL  string
+		href     string
+		expected string
+		hasError bool
+	}{
+		{
+			name:     "Absolute URL",
+			baseURL:  "https://example.com/page",
+			href:     "https://example.org/another",
+			expected: "https://example.org/another",
+			hasError: false,
+		},
+		{
+			name:     "Relative URL",
+			baseURL:  "https://example.com/page",
+			href:     "documents/doc.pdf",
+			expected: "https://example.com/documents/doc.pdf",
+			hasError: false,
+		},
+		{
+			name:     "Root relative URL",
+			baseURL:  "https://example.com/page/subpage",
+			href:     "/documents/doc.pdf",
+			expected: "https://example.com/documents/doc.pdf",
+			hasError: false,
+		},
+		{
+			name:     "URL with query parameters",
+			baseURL:  "https://example.com/page",
+			href:     "document?format=pdf&id=123",
+			expected: "https://example.com/document?format=pdf&id=123",
+			hasError: false,
+		},
+		{
+			name:     "Invalid base URL",
+			baseURL:  "://invalid.url",
+			href:     "/document.pdf",
+			hasError: true,
+		},
+		{
+			name:     "Invalid href URL",
+			baseURL:  "https://example.com",
+			href:     "://invalid.url",
+			hasError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolvedURL, err := resolveUrl(tc.baseURL, tc.href)
+
+			if tc.hasError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, resolvedURL.String())
+			}
+		})
+	}
+}
+
+func TestHarv(t *testing.T) {
+	// Create a test server with a mock HTML page
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		html := `
+		<!DOCTYPE html>
+		<html>
+		<head>
+			<title>Test Page</title>
+		</head>
+		<body>
+			<a href="https://example.com/document1.pdf">Document 1</a>
+			<a href="/document2.pdf">Document 2</a>
+			<a href="document3.pdf">Document 3</a>
+			<a href="https://different-domain.com/doc.pdf">External Document</a>
+			<a href="invalid:url">Invalid URL</a>
+		</body>
+		</html>
+		`
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(html))
+	}))
+	defer ts.Close()
+
+	baseURL, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	client := fetch.NewClient(fetch.Options{Timeout: crawlHttpTimeout})
+	urlStorage := newUrlStorage()
+
+	// Run the crawler
+	_, err = harv(context.Background(), client, baseURL, urlStorage, nil, 0, false, tQuietLogger{})
+	require.NoError(t, err)
+
+	// Check the collected URLs
+	urls := urlStorage.getAllUrls()
+
+	// Helper function to check if a URL is in the collection
+	findURL := func(target string) bool {
+		for _, u := range urls {
+			if u.String() == target {
+				return true
+			}
+		}
+		return false
+	}
+
+	// URLs that should be found
+	assert.True(t, findURL("https://example.com/document1.pdf"), "Should find absolute URL")
+	assert.True(t, findURL(ts.URL+"/document2.pdf"), "Should find root-relative URL")
+	assert.True(t, findURL(ts.URL+"/document3.pdf"), "Should find relative URL")
+	assert.True(t, findURL("https://different-domain.com/doc.pdf"), "Should find external domain URL")
+
+	// Don't test for invalid URL since it depends on the implementation
+	// whether invalid URLs are silently ignored or added
+
+	// Test harvesting a non-existent URL
+	invalidURL, _ := url.Parse("http://non-existent-domain-that-should-fail.example")
+	urlStorage2 := newUrlStorage()
+	_, err = harv(context.Background(), client, invalidURL, urlStorage2, nil, 0, false, tQuietLogger{})
+	assert.Error(t, err, "Unreachable page should be reported")
+
+	// Should not cause panic and should not add any URLs
+	assert.Len(t, urlStorage2.getAllUrls(), 0, "Should not add URLs from non-existent site")
+}
+
+func TestHarvLinkAttrs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html>
+		<head><link rel="alternate" href="/feed.pdf" /></head>
+		<body>
+			<a href="/anchor.pdf">A</a>
+			<map><area shape="rect" href="/area.pdf"></map>
+			<iframe src="/embedded.pdf"></iframe>
+			<img src="/image.png">
+			<a title="/title.pdf">No link</a>
+		</body></html>`))
+	}))
+	defer ts.Close()
+
+	baseURL, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	urlStorage := newUrlStorage()
+	_, err = harv(context.Background(), fetch.NewClient(fetch.Options{}), baseURL, urlStorage, nil, 0, false, tQuietLogger{})
+	require.NoError(t, err)
+
+	for _, link := range []string{"feed.pdf", "anchor.pdf", "area.pdf", "embedded.pdf"} {
+		exists, _ := urlStorage.check(baseURL.JoinPath(link))
+		assert.True(t, exists, "Link %s should be found", link)
+	}
+	for _, link := range []string{"image.png", "title.pdf"} {
+		exists, _ := urlStorage.check(baseURL.JoinPath(link))
+		assert.False(t, exists, "Attribute of %s is not in the table", link)
+	}
+}
+
+func TestHarvNofollow(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body>
+			<a href="/followed.pdf">Followed</a>
+			<a href="/nofollow.pdf" rel="nofollow">Nofollow</a>
+			<a href="/several.pdf" rel="noopener  NoFollow">Several values</a>
+			<a href="/noopener.pdf" rel="noopener">Other value</a>
+		</body></html>`))
+	}))
+	defer ts.Close()
+
+	baseURL, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name            string
+		respectNofollow bool
+		found           []string
+		skipped         []string
+	}{
+		{"Nofollow ignored by default", false, []string{"followed.pdf", "nofollow.pdf", "several.pdf", "noopener.pdf"}, nil},
+		{"Nofollow respected", true, []string{"followed.pdf", "noopener.pdf"}, []string{"nofollow.pdf", "several.pdf"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlStorage := newUrlStorage()
+			_, err := harv(context.Background(), fetch.NewClient(fetch.Options{}), baseURL, urlStorage, nil, 0, tt.respectNofollow, tQuietLogger{})
+			require.NoError(t, err)
+			for _, link := range tt.found {
+				exists, _ := urlStorage.check(baseURL.JoinPath(link))
+				assert.True(t, exists, "Link %s should be found", link)
+			}
+			for _, link := range tt.skipped {
+				exists, _ := urlStorage.check(baseURL.JoinPath(link))
+				assert.False(t, exists, "Link %s should be skipped", link)
+			}
+		})
+	}
+}
+
+func TestHarvBaseHref(t *testing.T) {
+	testCases := []struct {
+		name     string
+		page     string
+		expected []string // Links expected, relative to the server URL
+	}{
+		{
+			name:     "Root-relative base",
+			page:     `<head><base href="/docs/"></head><body><a href="report.pdf">R</a><a href="../other.pdf">O</a><a href="/top.pdf">T</a></body>`,
+			expected: []string{"/docs/report.pdf", "/other.pdf", "/top.pdf"},
+		},
+		{
+			name:     "Links before the base keep the page URL",
+			page:     `<a href="early.pdf">E</a><base href="/docs/"><a href="late.pdf">L</a>`,
+			expected: []string{"/pages/early.pdf", "/docs/late.pdf"},
+		},
+		{
+			name:     "Only the first base counts",
+			page:     `<base target="_blank"><base href="/docs/"><base href="/ignored/"><a href="report.pdf">R</a>`,
+			expected: []string{"/docs/report.pdf"},
+		},
+		{
+			name:     "No base",
+			page:     `<a href="report.pdf">R</a>`,
+			expected: []string{"/pages/report.pdf"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tc.page))
+			}))
+			defer ts.Close()
+
+			pageURL, err := url.Parse(ts.URL + "/pages/index.html")
+			require.NoError(t, err)
+			urlStorage := newUrlStorage()
+			_, err = harv(context.Background(), fetch.NewClient(fetch.Options{}), pageURL, urlStorage, nil, 0, false, tQuietLogger{})
+			require.NoError(t, err)
+
+			var found []string
+			for _, u := range urlStorage.getAllUrls() {
+				found = append(found, strings.TrimPrefix(u.String(), ts.URL))
+			}
+			assert.ElementsMatch(t, tc.expected, found)
+		})
+	}
+}
+
+func TestHarvCompressed(t *testing.T) {
+	page := []byte(`<html><body><a href="/document.pdf">Document</a></body></html>`)
+	compress := func(t *testing.T, encoding string) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		case "raw deflate":
+			var err error
+			w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+			require.NoError(t, err)
+		default:
+			return page
+		}
+		_, err := w.Write(page)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return buf.Bytes()
+	}
+
+	testCases := []struct {
+		name     string
+		encoding string // Content-Encoding sent by the server
+		body     string // Compression of the body, as named by compress
+	}{
+		{name: "Gzip not asked for", encoding: "gzip", body: "gzip"},
+		{name: "Deflate", encoding: "deflate", body: "deflate"},
+		{name: "Raw deflate", encoding: "deflate", body: "raw deflate"},
+		{name: "Uncompressed", encoding: "", body: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := compress(t, tc.body)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.encoding != "" {
+					w.Header().Set("Content-Encoding", tc.encoding)
+				}
+				w.Write(body)
+			}))
+			defer ts.Close()
+
+			baseURL, err := url.Parse(ts.URL)
+			require.NoError(t, err)
+
+			// Asking for an uncompressed page keeps the client from decompressing gzip itself
+			client := fetch.NewClient(fetch.Options{
+				Hosts:  []string{baseURL.Host},
+				Header: http.Header{"Accept-Encoding": {"identity"}},
+			})
+			urlStorage := newUrlStorage()
+			_, err = harv(context.Background(), client, baseURL, urlStorage, nil, 0, false, tQuietLogger{})
+			require.NoError(t, err)
+
+			exists, _ := urlStorage.check(baseURL.JoinPath("document.pdf"))
+			assert.True(t, exists, "Link should be found in the decompressed page")
+		})
+	}
+
+	t.Run("Unsupported encoding", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "br")
+			w.Write(page)
+		}))
+		defer ts.Close()
+
+		baseURL, err := url.Parse(ts.URL)
+		require.NoError(t, err)
+		_, err = harv(context.Background(), fetch.NewClient(fetch.Options{}), baseURL, newUrlStorage(), nil, 0, false, tQuietLogger{})
+		assert.ErrorContains(t, err, `unsupported content encoding "br"`)
+	})
+}
+
+func TestHarvDepth(t *testing.T) {
+	// Each page links to the next one, forming a chain /0 -> /1 -> /2 -> ...
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(r.URL.Path, "/%d", &n)
+		fmt.Fprintf(w, `<html><body><a href="/%d">next</a><a href="/doc%d.pdf">doc</a></body></html>`, n+1, n)
+	}))
+	defer ts.Close()
+
+	seed, err := url.Parse(ts.URL + "/0")
+	require.NoError(t, err)
+
+	client := fetch.NewClient(fetch.Options{Timeout: crawlHttpTimeout})
+
+	// harvChain imitates the crawl loop for a single worker
+	harvChain := func(maxDepth int) *tUrlStorage {
+		urlStorage := newUrlStorage()
+		harv(context.Background(), client, seed, urlStorage, nil, maxDepth, false, tQuietLogger{})
+		for i := 0; i < 10; i++ {
+			u, ok := urlStorage.use()
+			if !ok {
+				break
+			}
+			harv(context.Background(), client, u, urlStorage, nil, maxDepth, false, tQuietLogger{})
+		}
+		return urlStorage
+	}
+
+	t.Run("Depth 1 scans the seed page only", func(t *testing.T) {
+		urlStorage := harvChain(1)
+
+		total, _ := urlStorage.count()
+		assert.Equal(t, 2, total, "Only links from the seed page should be collected")
+
+		doc, _ := url.Parse(ts.URL + "/doc0.pdf")
+		d, exists := urlStorage.depth(doc)
+		assert.True(t, exists, "Document linked from the seed should be collected")
+		assert.Equal(t, 1, d, "Document linked from the seed should be at depth 1")
+	})
+
+	t.Run("Depth 2 follows two clicks", func(t *testing.T) {
+		urlStorage := harvChain(2)
+
+		doc1, _ := url.Parse(ts.URL + "/doc1.pdf")
+		d, exists := urlStorage.depth(doc1)
+		assert.True(t, exists, "Document two clicks away should be collected")
+		assert.Equal(t, 2, d)
+
+		doc2, _ := url.Parse(ts.URL + "/doc2.pdf")
+		exists, _ = urlStorage.check(doc2)
+		assert.False(t, exists, "Document three clicks away should not be collected")
+	})
+
+	t.Run("Zero depth is unlimited", func(t *testing.T) {
+		urlStorage := harvChain(0)
+
+		doc5, _ := url.Parse(ts.URL + "/doc5.pdf")
+		exists, _ := urlStorage.check(doc5)
+		assert.True(t, exists, "Deep documents should be collected without a limit")
+	})
+}
@@ -0,0 +1,1239 @@
+// Package crawler discovers the documents of a site and analyses their metadata
+// It is the engine behind the docscrawler command and can be embedded as a library:
+// build a Config, create an Engine with New and call Run to get the results as []Result
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"docscrawler/app/fetch"
+	"docscrawler/app/researchers"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Output formats
+const (
+	formatJson   = "json"   // Single JSON array
+	formatNdjson = "ndjson" // Newline-delimited JSON, one document per line
+	formatSqlite = "sqlite" // SQLite database, one row per document
+)
+
+// Constants for crawl timing
+const (
+	crawlHttpTimeout = 10 * time.Second // Default HTTP request timeout for fetching pages while crawling
+)
+
+// Engine represents the main crawler engine
+// Manages URL and document storages, processing parameters, and output configuration
+type Engine struct {
+	seeds          []*url.URL              // Site pages to start crawling from
+	listed         []*url.URL              // URLs read from the URL file, analysed whether the crawl finds them or not
+	noCrawl        bool                    // Only analyse the site pages and listed URLs, following no links
+	siteDomains    []string                // Hostnames of the site pages, or their registrable domains with subdomains
+	subdomains     bool                    // Crawl the subdomains of the registrable domains of the site pages
+	urlStorage     *tUrlStorage            // Storage for URLs discovered during crawling
+	docStorage     map[string]Result       // Storage for processed documents, by URL
+	errorStorage   map[string]tErrorRecord // Documents that failed, by URL
+	dedup          bool                    // Skip documents whose content was already analysed at another URL
+	hashes         map[string]string       // URL each content hash was first analysed at, by hash
+	stateFile      string                  // File the progress of the run is saved to and resumed from (none if empty)
+	stateInterval  time.Duration           // Interval between two saves of the state
+	restored       map[string]string       // Type of the documents analysed in an earlier run, by URL
+	prior          map[string]*tRestored   // Documents of an earlier run to revalidate, by URL
+	manifestFile   string                  // File recording the analysed documents for the next run (none if empty)
+	unfinished     map[string]bool         // Pages handed out for crawling whose harvest did not finish, by URL
+	reportErrors   bool                    // Print a summary of failed documents to stderr
+	docTypes       []string                // Document types/extensions to look for
+	outputFileName string                  // Output file name, Stdout for standard output (no output if empty)
+	errorFileName  string                  // Error records file name, Stdout for standard output (none if empty)
+	summaryFile    string                  // Summary file name, Stdout for standard output (none if empty)
+	format         string                  // Output format (json or ndjson)
+	pretty         bool                    // Indent the JSON output
+	withSummary    bool                    // Write the summary of the run as the last element of the output
+	splitByType    bool                    // Write the documents of each type to an output file of their own
+	wrap           bool                    // Write the documents in an envelope object with the details of the run
+	sortBy         string                  // Field the documents are sorted by in the output
+	streamJson     bool                    // Write the JSON array output during the analysis, as NDJSON always is
+	started        time.Time               // Start of the last run
+	summary        Summary                 // Summary of the last run
+	stream         *tStream                // Stream documents are written to as soon as analysed (nil if buffered)
+	paramax        int                     // Maximum number of parallel threads
+	gate           *fetch.Gate             // Politeness gate spacing out requests to each host
+	crawlClient    *fetch.Client           // HTTP client for fetching pages while crawling
+	downloader     *researchers.Downloader // Document downloader shared by the researchers
+	webhookClient  *fetch.Client           // HTTP client posting the documents to the webhook (nil without a webhook)
+	webhookUrl     string                  // URL each analysed document is posted to (none if empty)
+	webhookWorkers int                     // Number of concurrent POST requests to the webhook
+	webhook        *tWebhook               // Webhook the documents are posted to during the analysis (nil if none)
+	maxDepth       int                     // Maximum link depth from the seed (0 = unlimited)
+	nofollow       bool                    // Skip the links of tags marked rel="nofollow"
+	schemes        []string                // URL schemes links are followed and documents fetched over
+	sitemap        bool                    // Seed the crawl from the site's sitemap.xml
+	sniff          bool                    // Detect the type of extensionless URLs by Content-Type
+	maxPages       int                     // Maximum number of pages fetched while crawling (0 = unlimited)
+	maxDuration    time.Duration           // Maximum duration of the crawl and analysis (0 = unlimited)
+	maxDocs        int64                   // Documents analysed successfully after which no analysis starts (0 = unlimited)
+	filter         *tUrlFilter             // Filter applied to discovered URLs
+	logger         Logger                  // Logger receiving the events of the crawl
+	progress       func(done, total int)   // Callback reporting the progress of the analysis (nil if none)
+	statusOut      io.Writer               // Writer the status line of the run is refreshed on (nil if none)
+	stats          tStats                  // Counters of the run, reported on the status line
+	mutex          sync.Mutex              // Mutex protecting docStorage, errorStorage, hashes, unfinished and summary
+}
+
+// New initializes a new crawler engine with the provided configuration
+func New(cfg Config) (*Engine, error) {
+
+	engine := new(Engine)
+	engine.urlStorage = newUrlStorage()
+	if cfg.LowMemory {
+		engine.urlStorage = newLowMemoryUrlStorage(engine.isCandidate)
+	}
+	if cfg.NoNormalize {
+		engine.urlStorage.keyOf = (*url.URL).String
+	}
+	engine.docStorage = make(map[string]Result)
+	engine.errorStorage = make(map[string]tErrorRecord)
+	engine.docTypes = make([]string, len(cfg.Type))
+
+	// Validate document types
+	for i, st := range cfg.Type {
+		ok := researchers.Is(st)
+		if !ok {
+			return nil, errors.New("unknown document format for analysis")
+		}
+		engine.docTypes[i] = st
+	}
+	engine.stats.docsByType = newDocsByType(engine.docTypes)
+
+	engine.outputFileName = cfg.Output
+	engine.errorFileName = cfg.ErrorOutput
+	engine.summaryFile = cfg.SummaryFile
+
+	// Files split by type are named after the output file
+	if cfg.SplitByType && (cfg.Output == "" || cfg.Output == Stdout) {
+		return nil, errors.New("splitting the output by type requires an output file")
+	}
+	engine.splitByType = cfg.SplitByType
+
+	// The state lists every URL discovered, which low-memory mode does not keep
+	if cfg.StateFile != "" && cfg.LowMemory {
+		return nil, errors.New("a state file cannot be kept in low-memory mode")
+	}
+	engine.stateFile = cfg.StateFile
+	engine.stateInterval = stateInterval
+	engine.manifestFile = cfg.Manifest
+
+	// Validate output format, JSON array by default
+	switch cfg.Format {
+	case "", formatJson:
+		engine.format = formatJson
+	case formatNdjson:
+		engine.format = formatNdjson
+	case formatSqlite:
+		engine.format = formatSqlite
+	default:
+		return nil, errors.New("unknown output format")
+	}
+	if engine.format == formatSqlite {
+		if (cfg.Output == "" || cfg.Output == Stdout) && !cfg.WebhookOnly {
+			return nil, errors.New("the sqlite format requires an output file")
+		}
+		// The summary can still be written with --summary-file
+		if cfg.SplitByType || cfg.Wrap || cfg.WithSummary {
+			return nil, errors.New("the sqlite output cannot be split by type, wrapped or followed by the summary")
+		}
+	}
+
+	engine.pretty = cfg.Pretty
+
+	// The envelope is a single JSON object, which NDJSON has no place for
+	if cfg.Wrap && engine.format == formatNdjson {
+		return nil, errors.New("the output cannot be wrapped in ndjson format")
+	}
+	engine.wrap = cfg.Wrap
+
+	// Streamed documents are written one by one, neither sorted nor counted beforehand,
+	// and not kept for a later output or state file
+	if cfg.Stream && (cfg.SplitByType || cfg.Wrap || engine.format == formatSqlite || cfg.StateFile != "" || cfg.Manifest != "") {
+		return nil, errors.New("a streamed output cannot be split by type, wrapped, written to sqlite or recorded in a state file or manifest")
+	}
+	engine.streamJson = cfg.Stream
+
+	switch cfg.SortBy {
+	case "":
+		engine.sortBy = SortByUrl
+	case SortByUrl, SortByTitle, SortByModified:
+		engine.sortBy = cfg.SortBy
+	default:
+		return nil, errors.New("unknown sort field")
+	}
+
+	engine.reportErrors = cfg.ReportErrors
+
+	engine.paramax = cfg.Paramax
+
+	engine.gate = fetch.NewGate(cfg.Delay)
+
+	engine.maxDepth = cfg.Depth
+	engine.nofollow = cfg.RespectNofollow
+
+	engine.sitemap = cfg.Sitemap
+	engine.sniff = cfg.Sniff
+
+	engine.maxPages = cfg.MaxPages
+	engine.maxDuration = cfg.MaxDuration
+	engine.maxDocs = int64(cfg.MaxDocs)
+
+	var err error
+	if cfg.URLFile != "" {
+		engine.listed, err = readUrlFile(cfg.URLFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	engine.noCrawl = cfg.NoCrawl
+	engine.dedup = cfg.Dedup
+	engine.withSummary = cfg.WithSummary
+	engine.hashes = make(map[string]string)
+	engine.restored = make(map[string]string)
+	engine.prior = make(map[string]*tRestored)
+	engine.unfinished = make(map[string]bool)
+
+	engine.filter, err = newUrlFilter(cfg.Include, cfg.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	engine.logger = cfg.Logger
+	if engine.logger == nil {
+		engine.logger, err = NewLogger(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Progress is only reported to a terminal, unless forced
+	switch cfg.Progress {
+	case "":
+	case ProgressAuto:
+		if isTerminal(os.Stderr) {
+			engine.statusOut = os.Stderr
+		}
+	case ProgressForce:
+		engine.statusOut = os.Stderr
+	default:
+		return nil, errors.New("unknown progress mode")
+	}
+
+	// Parse and validate the starting URLs
+	if len(cfg.Site) == 0 {
+		return engine, errors.New("no site URL")
+	}
+
+	// Other schemes need an HTTP client able to fetch them (see http.Transport.RegisterProtocol)
+	schemes := cfg.Scheme
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	for _, scheme := range schemes {
+		scheme = strings.ToLower(scheme)
+		if scheme == "http" && cfg.HTTPSOnly || slices.Contains(engine.schemes, scheme) {
+			continue
+		}
+		engine.schemes = append(engine.schemes, scheme)
+	}
+	if len(engine.schemes) == 0 {
+		return engine, errors.New("no URL scheme accepted")
+	}
+
+	var hosts []string
+	for _, site := range cfg.Site {
+		seed, err := url.ParseRequestURI(site)
+		if err != nil {
+			return engine, fmt.Errorf("invalid URL %q", site)
+		}
+		if !engine.isValidScheme(seed) {
+			return engine, fmt.Errorf("site URL %s uses a scheme not accepted", seed)
+		}
+		engine.seeds = append(engine.seeds, seed)
+		if !slices.Contains(hosts, seed.Host) {
+			hosts = append(hosts, seed.Host)
+		}
+
+		domain := strings.ToLower(seed.Hostname())
+		if cfg.IncludeSubdomains {
+			domain = registrableDomainOf(domain)
+		}
+		if !slices.Contains(engine.siteDomains, domain) {
+			engine.siteDomains = append(engine.siteDomains, domain)
+		}
+	}
+	engine.subdomains = cfg.IncludeSubdomains
+
+	// The site pages must be in the subtree crawled, or the crawl would find nothing
+	if cfg.PathPrefix != "" {
+		engine.filter.pathPrefix = "/" + strings.TrimPrefix(cfg.PathPrefix, "/")
+		for _, seed := range engine.seeds {
+			if !engine.filter.underPrefix(seed) {
+				return engine, fmt.Errorf("site URL %s is not under the path prefix %s", seed, engine.filter.pathPrefix)
+			}
+		}
+		// The prefix scopes the crawl of the site, documents it links to on other hosts may be anywhere
+		if cfg.AnalyseExternal {
+			engine.filter.anyPath = func(u *url.URL) bool {
+				return !engine.onSite(u.Hostname()) && slices.Contains(engine.docTypes, extensionOf(u))
+			}
+		}
+	}
+
+	// The proxy applies to every request, whichever the host
+	httpClient := cfg.HTTPClient
+	if cfg.Proxy != "" {
+		httpClient, err = withProxy(httpClient, cfg.Proxy)
+		if err != nil {
+			return engine, err
+		}
+	}
+
+	// Credentials are only ever sent to the site itself
+	clientOpts := fetch.Options{
+		Timeout:   cfg.Timeout,
+		Gate:      engine.gate,
+		UserAgent: cfg.UserAgent,
+		Hosts:     hosts,
+		User:      cfg.User,
+		Password:  cfg.Password,
+		Retries:   cfg.Retries,
+		RetryWait: cfg.RetryWait,
+
+		FollowExternalRedirects: cfg.FollowExternalRedirects,
+
+		HTTPClient: httpClient,
+	}
+	// A timeout set on the injected client takes the place of the per-client defaults
+	if clientOpts.Timeout == 0 && httpClient != nil {
+		clientOpts.Timeout = httpClient.Timeout
+	}
+	clientOpts.Header, err = parseHeaders(cfg.Header)
+	if err != nil {
+		return engine, err
+	}
+	// Cookies of a session are sent like the headers, in a single Cookie header as HTTP requires
+	cookies := slices.Concat(clientOpts.Header.Values("Cookie"), cfg.Cookie)
+	if len(cookies) > 0 {
+		clientOpts.Header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+	engine.downloader = researchers.NewDownloader(researchers.NewClient(clientOpts))
+	if cfg.MaxSize != "" {
+		engine.downloader.MaxFileSize, err = parseSize(cfg.MaxSize)
+		if err != nil {
+			return engine, err
+		}
+	}
+	if cfg.TempDir != "" {
+		err = checkTempDir(cfg.TempDir)
+		if err != nil {
+			return engine, err
+		}
+		engine.downloader.TempDir = cfg.TempDir
+	}
+	engine.downloader.Ranges = cfg.RangeRequests
+	if clientOpts.Timeout == 0 {
+		clientOpts.Timeout = crawlHttpTimeout
+	}
+	engine.crawlClient = fetch.NewClient(clientOpts)
+
+	// The webhook is not the site: no credentials, headers or politeness delay
+	if cfg.WebhookOnly && cfg.Webhook == "" {
+		return engine, errors.New("writing no output requires a webhook to post the documents to")
+	}
+	if cfg.Webhook != "" {
+		if _, err := url.ParseRequestURI(cfg.Webhook); err != nil {
+			return engine, fmt.Errorf("invalid webhook URL %q", cfg.Webhook)
+		}
+		engine.webhookUrl = cfg.Webhook
+		engine.webhookWorkers = cfg.WebhookConcurrency
+		if engine.webhookWorkers <= 0 {
+			engine.webhookWorkers = webhookConcurrency
+		}
+		engine.webhookClient = fetch.NewClient(fetch.Options{
+			Timeout:    clientOpts.Timeout,
+			UserAgent:  cfg.UserAgent,
+			Retries:    cfg.Retries,
+			RetryWait:  cfg.RetryWait,
+			HTTPClient: httpClient,
+		})
+		if cfg.WebhookOnly {
+			engine.outputFileName = ""
+		}
+	}
+
+	return engine, nil
+}
+
+// Run executes the three main phases of the crawling process:
+// 1. crawl - discover URLs, starting from the site pages and listed URLs (skipped with NoCrawl)
+// 2. analyser - process documents
+// 3. output - write the results and error records to their outputs, if configured
+// Returns the analysed documents in output order;
+// documents streamed to the output (NDJSON, or JSON with Stream) are not kept in memory, so none are returned
+// The error joins every failure of the run: the site page failing to be fetched,
+// a *DocumentsError counting the documents that failed, output write failures,
+// and the context error if the run was cancelled
+// Cancelling the context stops the crawl and analysis phases early,
+// the documents analysed so far are still written to the output and returned
+func (engine *Engine) Run(ctx context.Context) ([]Result, error) {
+	start := time.Now()
+	engine.started = start
+
+	// The status line is ended before any output, which may go to the same terminal
+	stopStatus := func() {}
+	if engine.statusOut != nil {
+		stopStatus = engine.startStatus(engine.statusOut)
+		defer stopStatus()
+	}
+
+	// Once the deadline passes, crawl and analysis start no new work and what was gathered is written
+	// The deadline is not an error of the run, unlike a cancellation of ctx
+	runCtx := ctx
+	if engine.maxDuration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, engine.maxDuration)
+		defer cancel()
+	}
+
+	// The progress of an interrupted run is restored and saved regularly, so a crash loses little
+	stopSaving := func() {}
+	if engine.stateFile != "" {
+		engine.loadState()
+		stopSaving = engine.startSavingState(engine.stateInterval)
+	}
+	if engine.manifestFile != "" {
+		engine.loadManifest()
+	}
+
+	// Listed URLs are queued first, so the crawl also follows the links of those on the site
+	// Listed URLs pass the same filter as discovered ones
+	for _, u := range engine.listed {
+		if !engine.filter.allow(u) {
+			engine.logger.Debug("url skipped", "url", u, "reason", "excluded by filter")
+			continue
+		}
+		engine.urlStorage.add(u)
+	}
+	var crawlErr error
+	if engine.noCrawl {
+		for _, seed := range engine.seeds {
+			engine.urlStorage.add(seed)
+		}
+	} else {
+		crawlErr = engine.crawl(runCtx)
+	}
+
+	// NDJSON, and JSON with Stream, is streamed during the analysis instead of being buffered until the end
+	// Documents are kept for the state file and the manifest though, and those restored are written with the others
+	streamed := engine.format == formatNdjson || engine.format == formatJson && engine.streamJson
+	if streamed && engine.outputFileName != "" && !engine.splitByType && engine.stateFile == "" && engine.manifestFile == "" {
+		out, err := openOutput(engine.outputFileName)
+		if err != nil {
+			return nil, err
+		}
+		if out != os.Stdout {
+			defer out.Close()
+		}
+		engine.stream = newStream(out, engine.format == formatNdjson, engine.pretty)
+		defer func() { engine.stream = nil }()
+	}
+
+	// Documents are posted until the run is cancelled, the deadline only stops the analysis
+	if engine.webhookUrl != "" {
+		engine.webhook = newWebhook(ctx, engine.webhookClient, engine.webhookUrl, engine.webhookWorkers, engine.logger)
+	}
+	analyseErr := engine.analyser(runCtx)
+	var webhookErr error
+	if engine.webhook != nil {
+		webhookErr = engine.webhook.stop()
+		engine.webhook = nil
+	}
+	stopStatus()
+	stopSaving()
+	if engine.stateFile != "" {
+		engine.finishState(runCtx.Err() != nil)
+	}
+
+	summary := engine.summarize(time.Since(start))
+	if ctx.Err() == nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		engine.logger.Warn("deadline reached",
+			"max_duration", engine.maxDuration,
+			"unprocessed", int64(summary.URLs)-engine.stats.urlsDone.Load(),
+		)
+	}
+	engine.mutex.Lock()
+	engine.summary = summary
+	engine.mutex.Unlock()
+
+	var outputErr, errorsErr, summaryErr, manifestErr error
+	switch {
+	case engine.stream != nil:
+		if engine.withSummary {
+			outputErr = engine.stream.send(tSummaryRecord{Summary: summary})
+		}
+		if closeErr := engine.stream.close(); outputErr == nil && closeErr != nil {
+			outputErr = fmt.Errorf("failed to write %s: %w", engine.outputFileName, closeErr)
+		}
+	case engine.stream == nil && engine.outputFileName != "":
+		outputErr = engine.output()
+	}
+	if engine.errorFileName != "" {
+		errorsErr = engine.outputErrors()
+	}
+	if engine.summaryFile != "" {
+		summaryErr = engine.outputSummary(summary)
+	}
+	if engine.manifestFile != "" {
+		manifestErr = engine.saveManifest(runCtx.Err() != nil)
+	}
+
+	if engine.reportErrors {
+		engine.outErrors(os.Stderr)
+	}
+
+	engine.logger.Info("run finished",
+		"urls", summary.URLs,
+		"pages", summary.PagesCrawled,
+		"documents", engine.stats.docsFound.Load(),
+		"analysed", summary.Analysed,
+		"failed", summary.Failed,
+		"elapsed", time.Since(start),
+		"by_type", summary.DocumentsFound,
+		"bytes", summary.BytesDownloaded,
+	)
+
+	return engine.results(), errors.Join(crawlErr, analyseErr, webhookErr, outputErr, errorsErr, summaryErr, manifestErr, ctx.Err())
+}
+
+// errorRecords returns the documents that failed sorted by URL
+func (engine *Engine) errorRecords() []tErrorRecord {
+	records := make([]tErrorRecord, 0, len(engine.errorStorage))
+	for _, record := range engine.errorStorage {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Url < records[j].Url })
+	return records
+}
+
+// results returns the stored documents sorted by the sort field
+func (engine *Engine) results() []Result {
+	results := make([]Result, 0, len(engine.docStorage))
+	for _, result := range engine.docStorage {
+		results = append(results, result)
+	}
+	sortResults(results, engine.sortBy)
+	return results
+}
+
+// crawl recursively discovers URLs starting from the base URL
+// Workers report completion on a done channel: the loop wakes as soon as a worker finishes
+// (having queued the links it found), keeps at most paramax workers active,
+// and stops once the queue is empty and no worker is active
+// When the context is cancelled no new work is dispatched and in-flight requests are aborted
+// Returns an error if the site page cannot be fetched; other pages that fail are skipped
+func (engine *Engine) crawl(ctx context.Context) error {
+	done := make(chan bool, engine.paramax) // Completion signals of workers, set if a page was fetched; never blocks a worker
+	active := 0                             // Number of workers currently harvesting
+	pages := 0                              // Number of HTML pages fetched, the site pages included
+
+	// waitAll blocks until every active worker has finished
+	waitAll := func() {
+		for ; active > 0; active-- {
+			<-done
+		}
+	}
+
+	// Pages listed in the sitemap of each site are queued before link-following starts
+	if engine.sitemap {
+		sitemapSites := make(map[string]bool)
+		for _, seed := range engine.seeds {
+			site := seed.Scheme + "://" + seed.Host
+			if !sitemapSites[site] {
+				sitemapSites[site] = true
+				seedSitemap(ctx, engine.crawlClient, seed, engine.urlStorage, engine.filter, engine.logger)
+			}
+		}
+	}
+
+	// The site pages are harvested first, one after another
+	// Failing to fetch one is a crawl error, unless the crawl was cancelled
+	var err error
+	for _, seed := range engine.seeds {
+		fetched, seedErr := harv(ctx, engine.crawlClient, seed, engine.urlStorage, engine.filter, engine.maxDepth, engine.nofollow, engine.logger)
+		if fetched {
+			pages++
+			engine.stats.pagesCrawled.Add(1)
+		}
+		if seedErr != nil && ctx.Err() == nil {
+			err = errors.Join(err, fmt.Errorf("failed to crawl site page %s: %w", seed, seedErr))
+			engine.logger.Error("site page failed", "url", seed, "error", seedErr)
+		}
+	}
+
+	for {
+		if ctx.Err() != nil {
+			// Cancelled: in-flight requests are aborting, wait for their workers
+			waitAll()
+			return err
+		}
+		if engine.maxPages > 0 && active > 0 && pages+active >= engine.maxPages {
+			// The workers in flight may fetch the last pages allowed, or turn out not to
+			// fetch pages at all (depth limit, documents, failures): wait for one of them
+			select {
+			case <-ctx.Done():
+			case fetched := <-done:
+				active--
+				if fetched {
+					pages++
+				}
+			}
+			continue
+		}
+
+		urlBase, ok := engine.urlStorage.use()
+		if !ok {
+			if active == 0 {
+				// No more URLs to process and no active workers
+				return err
+			}
+			// No URLs to process but workers are still active, wait for one of them
+			select {
+			case <-ctx.Done():
+			case fetched := <-done:
+				active--
+				if fetched {
+					pages++
+				}
+			}
+			continue
+		}
+		// Until its harvest finishes, the page is crawled again by a run resuming from the state
+		engine.setUnfinished(urlBase, true)
+
+		if !engine.isValidScheme(urlBase) {
+			engine.logger.Debug("url skipped", "url", urlBase, "reason", "unsupported scheme")
+			engine.setUnfinished(urlBase, false)
+			continue
+		}
+		if !engine.onSite(urlBase.Hostname()) {
+			engine.logger.Debug("url skipped", "url", urlBase, "reason", "other host")
+			engine.setUnfinished(urlBase, false)
+			continue
+		}
+		if engine.maxPages > 0 && pages >= engine.maxPages {
+			// Page limit reached, no worker is in flight: dispatch nothing new
+			engine.logger.Info("page limit reached", "pages", pages)
+			return err
+		}
+		if active >= engine.paramax {
+			// All workers are busy, wait for a free slot
+			select {
+			case <-ctx.Done():
+				waitAll()
+				return err
+			case fetched := <-done:
+				active--
+				if fetched {
+					pages++
+				}
+			}
+		}
+
+		active++
+		urlCopy := *urlBase
+		go func(u *url.URL) {
+			fetched, err := harv(ctx, engine.crawlClient, u, engine.urlStorage, engine.filter, engine.maxDepth, engine.nofollow, engine.logger)
+			if fetched {
+				engine.stats.pagesCrawled.Add(1)
+			}
+			if ctx.Err() == nil {
+				engine.setUnfinished(u, false)
+			}
+			if err != nil && ctx.Err() == nil {
+				engine.logger.Warn("page failed", "url", u, "error", err)
+			}
+			done <- fetched
+		}(&urlCopy)
+	}
+}
+
+// SetProgress sets a callback invoked by the analysis phase as each discovered URL is finished with,
+// whether it was a document or not; total is the number of discovered URLs
+// Calls are serialized, so done counts up by one from 1 to total (unless cancelled)
+func (engine *Engine) SetProgress(progress func(done, total int)) {
+	engine.progress = progress
+}
+
+// analyser processes discovered URLs looking for document files of specified types
+// Uses a worker pool pattern with a guard channel to limit concurrent operations
+// When the context is cancelled no new documents are started and in-flight downloads are aborted
+// Returns a *DocumentsError if any of the documents failed
+func (engine *Engine) analyser(ctx context.Context) error {
+
+	guard := make(chan bool, engine.paramax)
+	defer close(guard)
+
+	var wg sync.WaitGroup
+
+	urls := engine.urlStorage.getAllUrls()
+	var progressMu sync.Mutex // Serializes progress callbacks
+	done := 0                 // Number of URLs finished with
+
+dispatch:
+	for _, url := range urls {
+		url := url
+		// A free slot must not win over a cancellation already noticed
+		if ctx.Err() != nil {
+			break
+		}
+		if engine.docLimitReached() {
+			engine.logger.Info("document limit reached", "documents", engine.maxDocs)
+			break
+		}
+		select {
+		case <-ctx.Done():
+			// Cancelled: start no new documents
+			break dispatch
+		case guard <- true:
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Process URL if it has a matching document extension (or Content-Type when sniffing)
+			// Downloads run concurrently, only the storage write is serialized
+			// Documents restored from the state file are not downloaded again, unless they can be revalidated
+			// Documents of the manifest keep their type, so revalidating them needs no sniffing
+			t, restored := engine.restored[url.String()]
+			prior, revalidated := engine.prior[url.String()]
+			supported := engine.isValidScheme(url)
+			switch {
+			case restored || !supported:
+			case revalidated && slices.Contains(engine.docTypes, prior.docType):
+				t = prior.docType
+			default:
+				t = engine.docTypeOf(ctx, url)
+			}
+			switch {
+			case restored && !revalidated:
+				engine.stats.countFound(t)
+				engine.stats.docsAnalysed.Add(1)
+				engine.logger.Debug("url skipped", "url", url, "reason", "analysed in an earlier run")
+			case !supported:
+				engine.logger.Debug("url skipped", "url", url, "reason", "unsupported scheme")
+			case t == "":
+				engine.logger.Debug("url skipped", "url", url, "reason", "not a requested document")
+			case engine.docLimitReached():
+				// Analyses in flight may have reached the limit since the dispatch
+				// A document downloaded to detect its type is released, no researcher will read it
+				engine.downloader.Discard(url.String())
+				engine.logger.Debug("url skipped", "url", url, "reason", "document limit reached")
+			default:
+				engine.stats.countFound(t)
+				eng := researchers.New(t, engine.downloader)
+				err := eng.Do(ctx, url.String())
+				// A document unchanged since the earlier run is reported with the metadata of that run
+				notModified := errors.Is(err, researchers.ErrNotModified)
+				if notModified {
+					eng, err = prior, nil
+				}
+				duplicateOf := ""
+				if err == nil && engine.dedup {
+					duplicateOf = engine.firstWithHash(eng, url.String())
+				}
+				if err == nil && duplicateOf == "" && engine.stream != nil {
+					err = engine.stream.send(eng)
+				}
+				if err == nil && duplicateOf == "" && !notModified && engine.webhook != nil {
+					err = engine.webhook.send(url.String(), eng)
+				}
+				oversized := errors.Is(err, researchers.ErrTooLarge)
+				switch {
+				case oversized:
+					engine.stats.docsFailed.Add(1)
+					engine.logger.Warn("document skipped", "url", url, "type", t, "reason", "oversized", "error", err)
+				case err != nil:
+					engine.stats.docsFailed.Add(1)
+					engine.logger.Warn("document failed", "url", url, "type", t, "error", err)
+				case duplicateOf != "":
+					engine.stats.docsAnalysed.Add(1)
+					engine.logger.Info("duplicate document skipped", "url", url, "type", t, "same_as", duplicateOf)
+				case notModified:
+					engine.stats.docsAnalysed.Add(1)
+					engine.logger.Debug("url skipped", "url", url, "reason", "not modified since an earlier run")
+				default:
+					engine.stats.docsAnalysed.Add(1)
+					engine.logger.Info("document analysed", "url", url, "type", t)
+				}
+				var record tErrorRecord
+				if err != nil {
+					record = tErrorRecord{Url: url.String(), Type: t, Error: err.Error()}
+					if describer, ok := eng.(researchers.FileDescriber); ok {
+						file := describer.File()
+						record.FileSize, record.HttpLastModified = file.Size, file.LastModified
+					}
+				}
+				engine.mutex.Lock()
+				switch {
+				case oversized:
+					record.Reason = "oversized"
+					engine.errorStorage[url.String()] = record
+					delete(engine.docStorage, url.String())
+				case err != nil:
+					// A document of an earlier run failing its revalidation is no longer reported
+					engine.errorStorage[url.String()] = record
+					delete(engine.docStorage, url.String())
+				case duplicateOf != "":
+					// Only the first URL of the content is reported
+				case engine.stream == nil:
+					// Streamed documents are not kept in memory
+					engine.docStorage[url.String()] = Result{Url: url.String(), Type: t, Metadata: eng}
+				}
+				engine.mutex.Unlock()
+			}
+
+			engine.stats.urlsDone.Add(1)
+			if engine.progress != nil {
+				progressMu.Lock()
+				done++
+				engine.progress(done, len(urls))
+				progressMu.Unlock()
+			}
+			<-guard
+
+		}()
+	}
+
+	wg.Wait()
+
+	if len(engine.errorStorage) > 0 {
+		return &DocumentsError{Failed: len(engine.errorStorage)}
+	}
+	return nil
+}
+
+// docLimitReached reports whether as many documents as the limit have been analysed successfully
+// The counter is checked before each analysis, so the analyses in flight can take the total past the limit
+func (engine *Engine) docLimitReached() bool {
+	return engine.maxDocs > 0 && engine.stats.docsAnalysed.Load() >= engine.maxDocs
+}
+
+// firstWithHash records the content hash of the document analysed at the URL
+// Returns the URL the same content was analysed at first, or "" if the content is new
+// or the researcher does not know its hash
+// With concurrent downloads, which URL comes first is not determined by the crawl order
+func (engine *Engine) firstWithHash(researcher researchers.Researcher, u string) string {
+	hasher, ok := researcher.(researchers.ContentHasher)
+	if !ok || hasher.Hash() == "" {
+		return ""
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	// A document revalidated from an earlier run may come back unchanged from a server ignoring validators
+	if first, seen := engine.hashes[hasher.Hash()]; seen && first != u {
+		return first
+	}
+	engine.hashes[hasher.Hash()] = u
+	return ""
+}
+
+// isCandidate reports whether the URL may be a document of the requested types, which docTypeOf
+// tells apart: one with their extension or, when sniffing, one without the extension of another type
+func (engine *Engine) isCandidate(u *url.URL) bool {
+	ext := extensionOf(u)
+	return slices.Contains(engine.docTypes, ext) || engine.sniff && !researchers.Is(ext)
+}
+
+// docTypeOf returns the requested document type of the URL, or "" if it is not to be analysed
+// The type is taken from the extension; with sniffing enabled, URLs without a known
+// extension are typed by the Content-Type of a HEAD request, and when that is inconclusive
+// (a generic binary type or HEAD not allowed) by the magic bytes of the downloaded content
+// The answer to the HEAD request is kept by the downloader for the download of the document,
+// and released when the URL is not one
+func (engine *Engine) docTypeOf(ctx context.Context, u *url.URL) (docType string) {
+	ext := extensionOf(u)
+	if slices.Contains(engine.docTypes, ext) {
+		return ext
+	}
+
+	if !engine.sniff || researchers.Is(ext) {
+		return ""
+	}
+
+	defer func() {
+		if docType == "" {
+			engine.downloader.Discard(u.String())
+		}
+	}()
+	resp, err := engine.downloader.Head(ctx, u.String())
+	if err != nil {
+		return ""
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	switch {
+	case resp.StatusCode == http.StatusMethodNotAllowed,
+		resp.StatusCode == http.StatusOK && isOpaqueContentType(contentType):
+		t, err := engine.downloader.DetectType(ctx, u.String(), engine.docTypes)
+		if err != nil {
+			return ""
+		}
+		return t
+	case resp.StatusCode != http.StatusOK:
+		return ""
+	}
+
+	t, ok := researchers.ByMimeType(contentType)
+	if !ok || !slices.Contains(engine.docTypes, t) {
+		return ""
+	}
+	return t
+}
+
+// extensionOf returns the lowercased file name extension of the URL path, without the dot
+// The query and fragment are not part of the path; dots in directory names are ignored
+func extensionOf(u *url.URL) string {
+	return strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), "."))
+}
+
+// isOpaqueContentType reports whether a Content-Type value says nothing about the document type
+func isOpaqueContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	return mediaType == "application/octet-stream" || mediaType == "binary/octet-stream"
+}
+
+// openOutput opens an output destination: stdout or the named file
+func openOutput(fileName string) (*os.File, error) {
+	if fileName == Stdout {
+		return os.Stdout, nil
+	}
+	return os.Create(fileName)
+}
+
+// outErrors writes a summary of the documents that failed to be analysed, sorted by URL
+func (engine *Engine) outErrors(writer io.Writer) {
+	if len(engine.errorStorage) == 0 {
+		return
+	}
+
+	urls := make([]string, 0, len(engine.errorStorage))
+	for url := range engine.errorStorage {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	fmt.Fprintf(writer, "%d document(s) failed:\n", len(urls))
+	for _, url := range urls {
+		fmt.Fprintf(writer, "  %s: %s\n", url, engine.errorStorage[url].Error)
+	}
+}
+
+// tJsonOutputter is an object written to the output as JSON: document metadata or an error record
+type tJsonOutputter interface {
+	OutJSON(writer io.Writer) error
+}
+
+// outIndentedJSON writes the object as JSON indented by two spaces
+// The object is nested one level deep, as an element of the output array
+func outIndentedJSON(writer io.Writer, rr tJsonOutputter) error {
+	var compact, indented bytes.Buffer
+	err := rr.OutJSON(&compact)
+	if err != nil {
+		return err
+	}
+	err = json.Indent(&indented, compact.Bytes(), "  ", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = indented.WriteTo(writer)
+	return err
+}
+
+// withProxy returns a copy of the client (a plain client if nil) sending its requests through
+// the proxy at the URL, e.g. http://proxy.example.com:3128 or socks5://localhost:1080,
+// in place of the proxy of the environment (HTTP_PROXY, HTTPS_PROXY, NO_PROXY)
+// The transport of the client must be an *http.Transport, or none for the default one
+func withProxy(client *http.Client, proxy string) (*http.Client, error) {
+	proxyUrl, err := url.Parse(proxy)
+	if err != nil || proxyUrl.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", proxy)
+	}
+	switch proxyUrl.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyUrl.Scheme)
+	}
+
+	withProxy := &http.Client{}
+	if client != nil {
+		*withProxy = *client
+	}
+	var transport *http.Transport
+	switch base := withProxy.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = base.Clone()
+	default:
+		return nil, errors.New("a proxy cannot be set on the transport of the HTTP client")
+	}
+	transport.Proxy = http.ProxyURL(proxyUrl)
+	withProxy.Transport = transport
+	return withProxy, nil
+}
+
+// parseHeaders converts "Name: Value" strings into an HTTP header
+// Repeated names are kept as multiple values
+func parseHeaders(lines []string) (http.Header, error) {
+	header := make(http.Header)
+	for _, line := range lines {
+		name, value, found := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: Value\"", line)
+		}
+		header.Add(name, strings.TrimSpace(value))
+	}
+	return header, nil
+}
+
+// parseSize converts a size like "250M", "1.5GB" or "1048576" into bytes
+// Units are binary (K = 1024) and case-insensitive
+func parseSize(st string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+		{"k", 1 << 10}, {"m", 1 << 20}, {"g", 1 << 30},
+		{"b", 1},
+	}
+
+	number := strings.ToLower(strings.TrimSpace(st))
+	multiplier := 1.0
+	for _, unit := range units {
+		if strings.HasSuffix(number, unit.suffix) {
+			number = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", st)
+	}
+	return int64(value * multiplier), nil
+}
+
+// checkTempDir verifies that the directory exists and temporary files can be created in it
+func checkTempDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("temp directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("temp directory: %s is not a directory", dir)
+	}
+	probe, err := os.CreateTemp(dir, "probe-*")
+	if err != nil {
+		return fmt.Errorf("temp directory is not writable: %w", err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// onSite reports whether pages on the hostname are crawled: those on the hosts of the
+// site pages and, with subdomains included, on any host within their registrable domains
+func (engine *Engine) onSite(hostname string) bool {
+	hostname = strings.ToLower(hostname)
+	for _, domain := range engine.siteDomains {
+		if hostname == domain || engine.subdomains && strings.HasSuffix(hostname, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// registrableDomainOf returns the domain a hostname was registered under, by the public suffix list,
+// e.g. example.com for docs.example.com and example.co.uk for www.example.co.uk
+// IP addresses and hostnames without a known public suffix are returned as they are
+func registrableDomainOf(hostname string) string {
+	if net.ParseIP(hostname) != nil {
+		return hostname
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(hostname)
+	if err != nil {
+		return hostname
+	}
+	return domain
+}
+
+// isValidScheme checks if the URL uses one of the accepted schemes (http and https by default)
+func (engine *Engine) isValidScheme(u *url.URL) bool {
+	return slices.Contains(engine.schemes, strings.ToLower(u.Scheme))
+}
+
+// output writes the analysis results to the specified output file or stdout, followed by the summary if requested
+// Split by type, the documents of each type are written to a file named by splitFileName
+// (none for types without documents) and the summary to a file of its own
+func (engine *Engine) output() error {
+	results := engine.results()
+	if engine.format == formatSqlite {
+		return engine.writeSqlite(engine.outputFileName, results)
+	}
+	if !engine.splitByType {
+		objects := make([]tJsonOutputter, len(results), len(results)+1)
+		for i, result := range results {
+			objects[i] = result.Metadata
+		}
+		if engine.wrap {
+			return engine.writeEnvelope(engine.outputFileName, objects, engine.wrappedSummary())
+		}
+		if engine.withSummary {
+			objects = append(objects, tSummaryRecord{Summary: engine.Summary()})
+		}
+		return engine.writeJSON(engine.outputFileName, objects)
+	}
+
+	var types []string
+	byType := make(map[string][]tJsonOutputter)
+	for _, result := range results {
+		st := result.Metadata.Type()
+		if _, ok := byType[st]; !ok {
+			types = append(types, st)
+		}
+		byType[st] = append(byType[st], result.Metadata)
+	}
+
+	var errs []error
+	for _, st := range types {
+		fileName := splitFileName(engine.outputFileName, st)
+		if engine.wrap {
+			// The summary is part of each envelope instead of a file of its own
+			errs = append(errs, engine.writeEnvelope(fileName, byType[st], engine.wrappedSummary()))
+			continue
+		}
+		errs = append(errs, engine.writeJSON(fileName, byType[st]))
+	}
+	if engine.withSummary && !engine.wrap {
+		summary := []tJsonOutputter{tSummaryRecord{Summary: engine.Summary()}}
+		errs = append(errs, engine.writeJSON(splitFileName(engine.outputFileName, "summary"), summary))
+	}
+	return errors.Join(errs...)
+}
+
+// splitFileName names the output file of one document type by inserting the type before
+// the extension of the output file name (output.pdf.json for output.json), or appending it
+func splitFileName(fileName string, st string) string {
+	ext := filepath.Ext(fileName)
+	return strings.TrimSuffix(fileName, ext) + "." + st + ext
+}
+
+// outputErrors writes the records of the documents that failed to the error output file or stdout
+func (engine *Engine) outputErrors() error {
+	records := engine.errorRecords()
+	objects := make([]tJsonOutputter, len(records))
+	for i, record := range records {
+		objects[i] = record
+	}
+	return engine.writeJSON(engine.errorFileName, objects)
+}
+
+// writeJSON writes the objects to the named file or stdout in the output format
+// Output is either a JSON array of the objects or, in ndjson format,
+// one JSON object per line without surrounding brackets (empty if there are none)
+func (engine *Engine) writeJSON(fileName string, objects []tJsonOutputter) error {
+	out, err := openOutput(fileName)
+	if err != nil {
+		return err
+	}
+
+	bufout := bufio.NewWriter(out)
+
+	ndjson := engine.format == formatNdjson
+	// NDJSON requires one object per line, so it is never indented
+	pretty := engine.pretty && !ndjson
+
+	// Start JSON array
+	if !ndjson {
+		bufout.WriteString("[")
+	}
+	isFirst := true
+
+	// Write each object as JSON
+	for _, object := range objects {
+		if !isFirst && !ndjson {
+			bufout.WriteString(",")
+		}
+		isFirst = false
+		if pretty {
+			bufout.WriteString("\n  ")
+			err = outIndentedJSON(bufout, object)
+		} else {
+			err = object.OutJSON(bufout)
+		}
+		if err != nil {
+			break
+		}
+		if ndjson {
+			bufout.WriteString("\n")
+		}
+	}
+
+	// Close JSON array, an empty indented array stays on one line
+	switch {
+	case ndjson:
+	case pretty && !isFirst:
+		bufout.WriteString("\n]\n")
+	case pretty:
+		bufout.WriteString("]\n")
+	default:
+		bufout.WriteString("]")
+	}
+
+	// Write errors are kept by the buffered writer and reported by Flush
+	if flushErr := bufout.Flush(); err == nil {
+		err = flushErr
+	}
+	if out != os.Stdout {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", out.Name(), err)
+	}
+	return nil
+}
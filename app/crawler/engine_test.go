@@ -0,0 +1,2500 @@
+package crawler
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineInit(t *testing.T) {
+	t.Run("Valid initialization", func(t *testing.T) {
+		opts := Config{
+			Site:    []string{"https://example.com"},
+			Type:    []string{"pdf", "docx"},
+			Output:  "output.json",
+			Paramax: 10,
+		}
+
+		engine, err := New(opts)
+
+		require.NoError(t, err, "Engine should initialize without error")
+		assert.NotNil(t, engine, "Engine should not be nil")
+		assert.Equal(t, "output.json", engine.outputFileName, "Output file name should be set")
+		assert.Equal(t, 10, engine.paramax, "Paramax should be set")
+		require.Len(t, engine.seeds, 1)
+		assert.Equal(t, "example.com", engine.seeds[0].Hostname(), "URL hostname should be parsed correctly")
+		assert.Len(t, engine.docTypes, 2, "DocTypes should have correct length")
+		assert.Contains(t, engine.docTypes, "pdf", "DocTypes should contain pdf")
+		assert.Contains(t, engine.docTypes, "docx", "DocTypes should contain docx")
+		assert.NotNil(t, engine.gate, "Politeness gate should be initialized")
+		assert.NotNil(t, engine.crawlClient, "Crawl client should be initialized")
+		assert.NotNil(t, engine.downloader, "Document downloader should be initialized")
+		assert.Equal(t, int64(100*1024*1024), engine.downloader.MaxFileSize, "Default size limit should be 100MB")
+	})
+
+	t.Run("Invalid URL", func(t *testing.T) {
+		opts := Config{
+			Site:    []string{"not a url"},
+			Type:    []string{"pdf"},
+			Output:  "output.json",
+			Paramax: 10,
+		}
+
+		engine, err := New(opts)
+
+		assert.Error(t, err, "Should return error for invalid URL")
+		assert.NotNil(t, engine, "Engine should be returned even with error")
+	})
+
+	t.Run("Several sites", func(t *testing.T) {
+		engine, err := New(Config{Site: []string{"https://example.com/docs/", "https://files.example.com/", "https://example.com/forms/"}, Paramax: 1})
+		require.NoError(t, err)
+		require.Len(t, engine.seeds, 3)
+		assert.Equal(t, "files.example.com", engine.seeds[1].Hostname())
+
+		_, err = New(Config{Site: []string{"https://example.com", "not a url"}, Paramax: 1})
+		assert.EqualError(t, err, `invalid URL "not a url"`, "Every site URL should be validated")
+
+		_, err = New(Config{Paramax: 1})
+		assert.Error(t, err, "At least one site URL is required")
+	})
+
+	t.Run("Invalid document type", func(t *testing.T) {
+		opts := Config{
+			Site:    []string{"https://example.com"},
+			Type:    []string{"pdf", "invalid"},
+			Output:  "output.json",
+			Paramax: 10,
+		}
+
+		engine, err := New(opts)
+
+		assert.Error(t, err, "Should return error for invalid document type")
+		assert.Nil(t, engine, "Engine should be nil")
+		assert.Contains(t, err.Error(), "unknown document format", "Error should mention unknown format")
+	})
+
+	t.Run("Progress", func(t *testing.T) {
+		engine, err := New(Config{Site: []string{"https://example.com"}, Paramax: 1, Progress: ProgressForce})
+		require.NoError(t, err)
+		assert.Equal(t, os.Stderr, engine.statusOut, "Forced progress should be reported to stderr")
+
+		_, err = New(Config{Site: []string{"https://example.com"}, Paramax: 1, Progress: "always"})
+		assert.ErrorContains(t, err, "unknown progress mode")
+	})
+}
+
+func TestParseHeaders(t *testing.T) {
+	t.Run("Valid headers", func(t *testing.T) {
+		header, err := parseHeaders([]string{
+			"X-Api-Key: key123",
+			"Cookie:session=abc",
+			"X-Multi: one",
+			"X-Multi: two",
+			"X-Url: https://example.com/a",
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "key123", header.Get("X-Api-Key"))
+		assert.Equal(t, "session=abc", header.Get("Cookie"), "Space after the colon should be optional")
+		assert.Equal(t, []string{"one", "two"}, header.Values("X-Multi"), "Repeated headers should keep all values")
+		assert.Equal(t, "https://example.com/a", header.Get("X-Url"), "Only the first colon should separate the name")
+	})
+
+	t.Run("Invalid headers", func(t *testing.T) {
+		for _, line := range []string{"NoColon", ": value", "Bad Name: value"} {
+			_, err := parseHeaders([]string{line})
+			assert.Error(t, err, "Header %q should be rejected", line)
+		}
+	})
+
+	t.Run("Invalid header fails engine initialization", func(t *testing.T) {
+		opts := Config{
+			Site:   []string{"https://example.com"},
+			Type:   []string{"pdf"},
+			Header: []string{"NoColon"},
+		}
+
+		_, err := New(opts)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid header")
+	})
+}
+
+func TestEngineCookie(t *testing.T) {
+	odt := buildTestOdt(t, "Members only")
+
+	// Pages and documents of the site are only served to the logged-in session
+	var mu sync.Mutex
+	var cookies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		cookies = append(cookies, strings.Join(r.Header.Values("Cookie"), "|"))
+		mu.Unlock()
+		if _, err := r.Cookie("session"); err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/members.odt">Members</a>`))
+		case "/members.odt":
+			w.Write(odt)
+		}
+	}))
+	defer ts.Close()
+
+	engine, err := New(Config{
+		Site:    []string{ts.URL},
+		Type:    []string{"odt"},
+		Paramax: 2,
+		Header:  []string{"Cookie: lang=en"},
+		Cookie:  []string{"session=abc; theme=dark", "consent=yes"},
+	})
+	require.NoError(t, err)
+	results, err := engine.Run(context.Background())
+	require.NoError(t, err)
+
+	assert.Len(t, results, 1, "Documents behind the session should be analysed")
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, cookies)
+	for _, cookie := range cookies {
+		assert.Equal(t, "lang=en; session=abc; theme=dark; consent=yes", cookie, "Cookies should be sent in a single header")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	testCases := []struct {
+		size     string
+		expected int64
+		hasError bool
+	}{
+		{size: "1048576", expected: 1048576},
+		{size: "0", expected: 0},
+		{size: "512b", expected: 512},
+		{size: "64K", expected: 64 * 1024},
+		{size: "250M", expected: 250 * 1024 * 1024},
+		{size: "250MB", expected: 250 * 1024 * 1024},
+		{size: "1.5gb", expected: 1536 * 1024 * 1024},
+		{size: " 10 MB ", expected: 10 * 1024 * 1024},
+		{size: "", hasError: true},
+		{size: "MB", hasError: true},
+		{size: "-1M", hasError: true},
+		{size: "ten", hasError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.size, func(t *testing.T) {
+			size, err := parseSize(tc.size)
+			if tc.hasError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, size)
+			}
+		})
+	}
+
+	t.Run("Engine uses the configured size", func(t *testing.T) {
+		opts := Config{Site: []string{"https://example.com"}, Type: []string{"pdf"}, MaxSize: "250M"}
+		engine, err := New(opts)
+		require.NoError(t, err)
+		assert.Equal(t, int64(250*1024*1024), engine.downloader.MaxFileSize)
+
+		opts.MaxSize = "huge"
+		_, err = New(opts)
+		assert.Error(t, err, "Invalid size should fail engine initialization")
+	})
+}
+
+func TestEngineTempDir(t *testing.T) {
+	dir := t.TempDir()
+	opts := Config{Site: []string{"https://example.com"}, Type: []string{"pdf"}, TempDir: dir}
+	engine, err := New(opts)
+	require.NoError(t, err)
+	assert.Equal(t, dir, engine.downloader.TempDir)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "The probe file should be removed")
+
+	opts.TempDir = filepath.Join(dir, "missing")
+	_, err = New(opts)
+	assert.Error(t, err, "Missing directory should fail engine initialization")
+
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+	opts.TempDir = file
+	_, err = New(opts)
+	assert.ErrorContains(t, err, "not a directory")
+}
+
+func TestIsValidScheme(t *testing.T) {
+	testCases := []struct {
+		name      string
+		url       string
+		scheme    []string
+		httpsOnly bool
+		expected  bool
+	}{
+		{
+			name:     "Valid HTTP URL",
+			url:      "http://example.com",
+			expected: true,
+		},
+		{
+			name:     "Valid HTTPS URL",
+			url:      "https://example.com",
+			expected: true,
+		},
+		{
+			name:     "Invalid FTP URL",
+			url:      "ftp://example.com",
+			expected: false,
+		},
+		{
+			name:     "Invalid File URL",
+			url:      "file:///path/to/file",
+			expected: false,
+		},
+		{
+			name:      "HTTP URL with HTTPS only",
+			url:       "http://example.com",
+			httpsOnly: true,
+			expected:  false,
+		},
+		{
+			name:     "Configured scheme",
+			url:      "mirror://example.com/doc.pdf",
+			scheme:   []string{"https", "MIRROR"},
+			expected: true,
+		},
+		{
+			name:     "HTTP URL not configured",
+			url:      "HTTP://example.com",
+			scheme:   []string{"https", "mirror"},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			engine, err := New(Config{Site: []string{"https://example.com"}, Scheme: tc.scheme, HTTPSOnly: tc.httpsOnly, Paramax: 1})
+			require.NoError(t, err)
+			u, err := url.Parse(tc.url)
+			require.NoError(t, err)
+
+			result := engine.isValidScheme(u)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+
+	t.Run("Site URL not accepted", func(t *testing.T) {
+		_, err := New(Config{Site: []string{"http://example.com"}, HTTPSOnly: true, Paramax: 1})
+		assert.Error(t, err)
+	})
+
+	t.Run("No scheme left", func(t *testing.T) {
+		_, err := New(Config{Site: []string{"https://example.com"}, Scheme: []string{"http"}, HTTPSOnly: true, Paramax: 1})
+		assert.ErrorContains(t, err, "no URL scheme accepted")
+	})
+}
+
+func TestEngineOutput(t *testing.T) {
+	// Create a temporary directory for test output
+	tempDir, err := os.MkdirTemp("", "engine-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	outputFile := filepath.Join(tempDir, "output.json")
+
+	t.Run("Output to file", func(t *testing.T) {
+		opts := Config{
+			Site:    []string{"https://example.com"},
+			Type:    []string{"pdf"},
+			Output:  outputFile,
+			Paramax: 1,
+		}
+
+		engine, err := New(opts)
+		require.NoError(t, err)
+
+		// Add a mock URL to the storage
+		testUrl, _ := url.Parse("https://example.com/test.pdf")
+		engine.urlStorage.add(testUrl)
+
+		// Add mock researcher result
+		mockResearcher := &MockResearcher{
+			url: "https://example.com/test.pdf",
+		}
+		engine.docStorage[testUrl.String()] = Result{Url: testUrl.String(), Type: "pdf", Metadata: mockResearcher}
+
+		// Run output
+		err = engine.output()
+		require.NoError(t, err)
+
+		// Check if file was created
+		fileContent, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+
+		// Check if output is valid JSON array
+		assert.Equal(t, "[{\"test\":\"value\"}]", string(fileContent))
+	})
+
+	t.Run("Output to stdout", func(t *testing.T) {
+		// Temporarily redirect stdout
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		opts := Config{
+			Site:    []string{"https://example.com"},
+			Type:    []string{"pdf"},
+			Output:  Stdout,
+			Paramax: 1,
+		}
+
+		engine, err := New(opts)
+		require.NoError(t, err)
+
+		// Add a mock URL to the storage
+		testUrl, _ := url.Parse("https://example.com/test.pdf")
+		engine.urlStorage.add(testUrl)
+
+		// Add mock researcher result
+		mockResearcher := &MockResearcher{
+			url: "https://example.com/test.pdf",
+		}
+		engine.docStorage[testUrl.String()] = Result{Url: testUrl.String(), Type: "pdf", Metadata: mockResearcher}
+
+		// Run output
+		err = engine.output()
+		require.NoError(t, err)
+
+		// Restore stdout and get output
+		w.Close()
+		os.Stdout = oldStdout
+
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+
+		// Check output
+		assert.Equal(t, "[{\"test\":\"value\"}]", buf.String())
+	})
+}
+
+func TestEngineOutputNdjson(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "output.ndjson")
+
+	opts := Config{
+		Site:    []string{"https://example.com"},
+		Type:    []string{"pdf"},
+		Output:  outputFile,
+		Format:  "ndjson",
+		Paramax: 1,
+	}
+
+	t.Run("One document per line", func(t *testing.T) {
+		engine, err := New(opts)
+		require.NoError(t, err)
+
+		for _, s := range []string{"https://example.com/a.pdf", "https://example.com/b.pdf"} {
+			u, _ := url.Parse(s)
+			engine.urlStorage.add(u)
+			engine.docStorage[u.String()] = Result{Url: u.String(), Type: "pdf", Metadata: &MockResearcher{url: s}}
+		}
+
+		require.NoError(t, engine.output())
+
+		fileContent, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		assert.Equal(t, "{\"test\":\"value\"}\n{\"test\":\"value\"}\n", string(fileContent))
+	})
+
+	t.Run("Empty result set produces an empty file", func(t *testing.T) {
+		engine, err := New(opts)
+		require.NoError(t, err)
+
+		require.NoError(t, engine.output())
+
+		fileContent, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		assert.Empty(t, fileContent)
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		badOpts := opts
+		badOpts.Format = "xml"
+
+		_, err := New(badOpts)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown output format")
+	})
+}
+
+// buildTestOdt creates a minimal OpenDocument file: a ZIP archive with meta.xml holding the title
+func buildTestOdt(t *testing.T, title string) []byte {
+	var odt bytes.Buffer
+	zw := zip.NewWriter(&odt)
+	w, err := zw.Create("meta.xml")
+	require.NoError(t, err)
+	w.Write([]byte(`<office:document-meta><office:meta><dc:title>` + title + `</dc:title></office:meta></office:document-meta>`))
+	require.NoError(t, zw.Close())
+	return odt.Bytes()
+}
+
+func TestEngineRunNdjsonStream(t *testing.T) {
+	odt := buildTestOdt(t, "Streamed")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/a.odt">A</a><a href="/b.odt">B</a><a href="/missing.odt">C</a>`))
+		case "/missing.odt":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Write(odt)
+		}
+	}))
+	defer ts.Close()
+
+	outputFile := filepath.Join(t.TempDir(), "output.ndjson")
+	opts := Config{
+		Site:    []string{ts.URL},
+		Type:    []string{"odt"},
+		Output:  outputFile,
+		Format:  "ndjson",
+		Paramax: 2,
+	}
+
+	engine, err := New(opts)
+	require.NoError(t, err)
+
+	_, err = engine.Run(context.Background())
+	require.ErrorAs(t, err, new(*DocumentsError))
+
+	fileContent, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(fileContent), "\n"), "\n")
+	require.Len(t, lines, 2, "Every analysed document should be streamed on its own line")
+	for _, line := range lines {
+		assert.Contains(t, line, `"title":"Streamed"`)
+	}
+
+	assert.Empty(t, engine.docStorage, "Streamed documents should not be kept in memory")
+	assert.Len(t, engine.errorStorage, 1, "Failed documents should still be recorded")
+}
+
+func TestEngineRunJsonStream(t *testing.T) {
+	odt := buildTestOdt(t, "Streamed")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/a.odt">A</a><a href="/b.odt">B</a><a href="/c.odt">C</a>`))
+			return
+		}
+		w.Write(odt)
+	}))
+	defer ts.Close()
+
+	outputFile := filepath.Join(t.TempDir(), "output.json")
+	engine, err := New(Config{
+		Site:        []string{ts.URL},
+		Type:        []string{"odt"},
+		Output:      outputFile,
+		Stream:      true,
+		WithSummary: true,
+		Paramax:     2,
+	})
+	require.NoError(t, err)
+
+	results, err := engine.Run(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, results, "Streamed documents should not be returned")
+	assert.Empty(t, engine.docStorage, "Streamed documents should not be kept in memory")
+
+	fileContent, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	var docs []json.RawMessage
+	require.NoError(t, json.Unmarshal(fileContent, &docs), "Streamed output should be a JSON array")
+	require.Len(t, docs, 4)
+	for _, doc := range docs[:3] {
+		assert.Contains(t, string(doc), `"title":"Streamed"`)
+	}
+	assert.Contains(t, string(docs[3]), `"_summary"`, "Summary should be the last element")
+
+	t.Run("Incompatible options", func(t *testing.T) {
+		for _, cfg := range []Config{
+			{Output: outputFile, SplitByType: true},
+			{Output: outputFile, Wrap: true},
+			{Output: outputFile, Format: "sqlite"},
+			{Output: outputFile, StateFile: filepath.Join(t.TempDir(), "state.json")},
+		} {
+			cfg.Site = []string{ts.URL}
+			cfg.Type = []string{"odt"}
+			cfg.Stream = true
+			cfg.Paramax = 1
+			_, err := New(cfg)
+			assert.Error(t, err)
+		}
+	})
+}
+
+func TestEngineSplitByType(t *testing.T) {
+	odt := buildTestOdt(t, "Split")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/a.odt">A</a><a href="/b.odt">B</a><a href="/sheet.ods">Sheet</a>`))
+			return
+		}
+		w.Write(odt)
+	}))
+	defer ts.Close()
+
+	for _, format := range []string{formatJson, formatNdjson} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			engine, err := New(Config{
+				Site:        []string{ts.URL},
+				Type:        []string{"odt", "ods", "odp"},
+				Paramax:     2,
+				Output:      filepath.Join(dir, "output."+format),
+				Format:      format,
+				SplitByType: true,
+				WithSummary: true,
+			})
+			require.NoError(t, err)
+			_, err = engine.Run(context.Background())
+			require.NoError(t, err)
+
+			odtContent, err := os.ReadFile(filepath.Join(dir, "output.odt."+format))
+			require.NoError(t, err)
+			assert.Equal(t, 2, strings.Count(string(odtContent), `"doc_type":"odt"`))
+			assert.NotContains(t, string(odtContent), `"doc_type":"ods"`)
+
+			odsContent, err := os.ReadFile(filepath.Join(dir, "output.ods."+format))
+			require.NoError(t, err)
+			assert.Equal(t, 1, strings.Count(string(odsContent), `"doc_type":"ods"`))
+
+			summaryContent, err := os.ReadFile(filepath.Join(dir, "output.summary."+format))
+			require.NoError(t, err)
+			assert.Contains(t, string(summaryContent), `"_summary"`)
+
+			assert.NoFileExists(t, filepath.Join(dir, "output.odp."+format), "No file should be written for types without documents")
+			assert.NoFileExists(t, filepath.Join(dir, "output."+format), "Nothing should be written to the output file itself")
+		})
+	}
+
+	t.Run("Output file required", func(t *testing.T) {
+		for _, output := range []string{"", Stdout} {
+			_, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Output: output, SplitByType: true})
+			assert.ErrorContains(t, err, "requires an output file")
+		}
+	})
+}
+
+func TestSplitFileName(t *testing.T) {
+	assert.Equal(t, "output.pdf.json", splitFileName("output.json", "pdf"))
+	assert.Equal(t, "out/docs.docx.ndjson", splitFileName("out/docs.ndjson", "docx"))
+	assert.Equal(t, "results.xlsx", splitFileName("results", "xlsx"))
+	assert.Equal(t, "v1.2/results.pdf", splitFileName("v1.2/results", "pdf"))
+}
+
+func TestEngineRunResults(t *testing.T) {
+	odt := buildTestOdt(t, "Library")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/b.odt">B</a><a href="/a.odt">A</a><a href="/missing.odt">C</a>`))
+		case "/missing.odt":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Write(odt)
+		}
+	}))
+	defer ts.Close()
+
+	t.Run("Results are returned without output", func(t *testing.T) {
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 2})
+		require.NoError(t, err)
+
+		results, err := engine.Run(context.Background())
+		var docErr *DocumentsError
+		require.ErrorAs(t, err, &docErr, "Failed documents should be reported by the error")
+		assert.Equal(t, 1, docErr.Failed)
+		require.Len(t, results, 2, "Failed documents should not be returned")
+		assert.Equal(t, ts.URL+"/a.odt", results[0].Url, "Results should be sorted by URL")
+		assert.Equal(t, ts.URL+"/b.odt", results[1].Url)
+		for _, result := range results {
+			assert.Equal(t, "odt", result.Type)
+
+			var buf bytes.Buffer
+			require.NoError(t, result.Metadata.OutJSON(&buf))
+			assert.Contains(t, buf.String(), `"title":"Library"`)
+		}
+	})
+
+	t.Run("Results are returned with output", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "output.json")
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 2, Output: outputFile})
+		require.NoError(t, err)
+
+		results, err := engine.Run(context.Background())
+		require.ErrorAs(t, err, new(*DocumentsError))
+		assert.Len(t, results, 2)
+
+		fileContent, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		assert.Equal(t, 2, strings.Count(string(fileContent), `"title":"Library"`))
+	})
+
+	t.Run("Output error", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "missing", "output.json")
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 2, Output: outputFile})
+		require.NoError(t, err)
+
+		results, err := engine.Run(context.Background())
+		assert.Error(t, err)
+		assert.Len(t, results, 2, "Results should be returned even if they cannot be written")
+	})
+}
+
+func TestEngineDedup(t *testing.T) {
+	odt := buildTestOdt(t, "Library")
+	other := buildTestOdt(t, "Annual report")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/a.odt">A</a><a href="/copy/a.odt">Copy</a><a href="/b.odt">B</a>`))
+		case "/b.odt":
+			w.Write(other)
+		default:
+			w.Write(odt)
+		}
+	}))
+	defer ts.Close()
+
+	run := func(t *testing.T, dedup bool) []Result {
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 1, Dedup: dedup})
+		require.NoError(t, err)
+		results, err := engine.Run(context.Background())
+		require.NoError(t, err)
+		return results
+	}
+
+	t.Run("Copies are skipped", func(t *testing.T) {
+		results := run(t, true)
+		require.Len(t, results, 2, "Only one URL of the same content should be reported")
+
+		var titles []string
+		for _, result := range results {
+			var buf bytes.Buffer
+			require.NoError(t, result.Metadata.OutJSON(&buf))
+			assert.Contains(t, buf.String(), `"content_hash":"`, "Results should carry their content hash")
+			if strings.Contains(buf.String(), `"title":"Library"`) {
+				titles = append(titles, "Library")
+			}
+		}
+		assert.Len(t, titles, 1)
+	})
+
+	t.Run("Copies are kept without dedup", func(t *testing.T) {
+		assert.Len(t, run(t, false), 3)
+	})
+}
+
+func TestEngineRunErrors(t *testing.T) {
+	t.Run("Site page failure", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer ts.Close()
+
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"pdf"}, Paramax: 2})
+		require.NoError(t, err)
+
+		_, err = engine.Run(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to crawl site page")
+		assert.Contains(t, err.Error(), "status code 500")
+		assert.NotErrorAs(t, err, new(*DocumentsError), "No documents were found to fail")
+	})
+
+	t.Run("Failures are joined", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/":
+				w.Write([]byte(`<a href="/missing.pdf">A</a>`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer ts.Close()
+
+		outputFile := filepath.Join(t.TempDir(), "missing", "output.json")
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"pdf"}, Paramax: 2, Output: outputFile})
+		require.NoError(t, err)
+
+		_, err = engine.Run(context.Background())
+		var docErr *DocumentsError
+		require.ErrorAs(t, err, &docErr, "Document failures should be reported")
+		assert.Equal(t, 1, docErr.Failed)
+		assert.ErrorIs(t, err, os.ErrNotExist, "Output failure should be reported alongside")
+	})
+
+	t.Run("Clean run", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`<a href="/page.html">A</a>`))
+		}))
+		defer ts.Close()
+
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"pdf"}, Paramax: 2})
+		require.NoError(t, err)
+
+		_, err = engine.Run(context.Background())
+		assert.NoError(t, err)
+	})
+}
+
+func TestEngineLogger(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/page.html">Page</a>`))
+		case "/page.html":
+			w.Write([]byte(`<a href="/missing.pdf">PDF</a><a href="https://example.com/">Other</a><a href="/private/a.pdf">Private</a>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	t.Run("Events reach the injected logger", func(t *testing.T) {
+		logger := &tRecordingLogger{}
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"pdf"}, Exclude: []string{"/private/"}, Paramax: 2, Logger: logger})
+		require.NoError(t, err)
+
+		_, _ = engine.Run(context.Background())
+
+		events := strings.Join(logger.events, "\n")
+		assert.Contains(t, events, "debug url discovered [url "+ts.URL+"/page.html")
+		assert.Contains(t, events, "info page fetched [url "+ts.URL+"/page.html")
+		assert.Contains(t, events, "warn document failed [url "+ts.URL+"/missing.pdf type pdf error")
+		assert.Contains(t, events, "debug url skipped [url https://example.com/ reason other host]")
+		assert.Contains(t, events, "debug url skipped [url "+ts.URL+"/private/a.pdf reason excluded by filter]")
+		assert.Contains(t, events, "debug url skipped [url "+ts.URL+"/page.html reason not a requested document]")
+		assert.Contains(t, events, "info run finished [urls 3 pages 2 documents 1 analysed 0 failed 1 elapsed")
+	})
+
+	t.Run("JSON log format", func(t *testing.T) {
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"pdf"}, Paramax: 2, LogLevel: LogInfo, LogFormat: LogJSON})
+		require.NoError(t, err)
+		assert.IsType(t, &slog.Logger{}, engine.logger)
+
+		_, err = New(Config{Site: []string{ts.URL}, Type: []string{"pdf"}, LogLevel: LogInfo, LogFormat: "xml"})
+		assert.ErrorContains(t, err, "unknown log format")
+	})
+
+	t.Run("Unknown log level", func(t *testing.T) {
+		_, err := New(Config{Site: []string{ts.URL}, Type: []string{"pdf"}, LogLevel: "verbose"})
+		assert.Error(t, err)
+	})
+}
+
+func TestEngineOutputPretty(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "output.json")
+
+	opts := Config{
+		Site:    []string{"https://example.com"},
+		Type:    []string{"pdf"},
+		Output:  outputFile,
+		Pretty:  true,
+		Paramax: 1,
+	}
+
+	t.Run("Indented array", func(t *testing.T) {
+		engine, err := New(opts)
+		require.NoError(t, err)
+
+		for _, s := range []string{"https://example.com/a.pdf", "https://example.com/b.pdf"} {
+			u, _ := url.Parse(s)
+			engine.urlStorage.add(u)
+			engine.docStorage[u.String()] = Result{Url: u.String(), Type: "pdf", Metadata: &MockResearcher{url: s}}
+		}
+
+		require.NoError(t, engine.output())
+
+		fileContent, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		assert.Equal(t, "[\n  {\n    \"test\": \"value\"\n  },\n  {\n    \"test\": \"value\"\n  }\n]\n", string(fileContent))
+	})
+
+	t.Run("Empty array", func(t *testing.T) {
+		engine, err := New(opts)
+		require.NoError(t, err)
+
+		require.NoError(t, engine.output())
+
+		fileContent, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		assert.Equal(t, "[]\n", string(fileContent))
+	})
+
+	t.Run("NDJSON is never indented", func(t *testing.T) {
+		ndjsonOpts := opts
+		ndjsonOpts.Format = "ndjson"
+		engine, err := New(ndjsonOpts)
+		require.NoError(t, err)
+
+		u, _ := url.Parse("https://example.com/a.pdf")
+		engine.urlStorage.add(u)
+		engine.docStorage[u.String()] = Result{Url: u.String(), Type: "pdf", Metadata: &MockResearcher{url: u.String()}}
+
+		require.NoError(t, engine.output())
+
+		fileContent, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		assert.Equal(t, "{\"test\":\"value\"}\n", string(fileContent))
+	})
+}
+
+func TestEngineErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.pdf" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("Not a real PDF"))
+	}))
+	defer ts.Close()
+
+	opts := Config{
+		Site:    []string{ts.URL},
+		Type:    []string{"pdf"},
+		Paramax: 2,
+	}
+
+	engine, err := New(opts)
+	require.NoError(t, err)
+
+	for _, path := range []string{"/missing.pdf", "/corrupt.pdf", "/page.html"} {
+		u, _ := url.Parse(ts.URL + path)
+		engine.urlStorage.add(u)
+	}
+
+	engine.analyser(context.Background())
+
+	assert.Len(t, engine.errorStorage, 2, "Both failed documents should be recorded")
+	assert.Contains(t, engine.errorStorage[ts.URL+"/missing.pdf"].Error, "status code 404")
+	assert.NotEmpty(t, engine.errorStorage[ts.URL+"/corrupt.pdf"].Error)
+	assert.NotContains(t, engine.errorStorage, ts.URL+"/page.html", "Non-documents should not be recorded")
+
+	var buf bytes.Buffer
+	engine.outErrors(&buf)
+	summary := buf.String()
+	assert.True(t, strings.HasPrefix(summary, "2 document(s) failed:\n"), "Summary should start with the failure count")
+	assert.Less(t, strings.Index(summary, "/corrupt.pdf"), strings.Index(summary, "/missing.pdf"), "Failures should be sorted by URL")
+}
+
+func TestEngineErrorOutput(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/missing.pdf">A</a><a href="/corrupt.pdf">B</a>`))
+		case "/missing.pdf":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+			w.Write([]byte("Not a real PDF"))
+		}
+	}))
+	defer ts.Close()
+
+	run := func(t *testing.T, format string) string {
+		dir := t.TempDir()
+		errorFile := filepath.Join(dir, "errors.json")
+		engine, err := New(Config{
+			Site:        []string{ts.URL},
+			Type:        []string{"pdf"},
+			Output:      filepath.Join(dir, "output.json"),
+			ErrorOutput: errorFile,
+			Format:      format,
+			Paramax:     2,
+		})
+		require.NoError(t, err)
+
+		_, err = engine.Run(context.Background())
+		var docErr *DocumentsError
+		require.ErrorAs(t, err, &docErr)
+		assert.Equal(t, 2, docErr.Failed)
+
+		fileContent, err := os.ReadFile(errorFile)
+		require.NoError(t, err)
+		return string(fileContent)
+	}
+
+	t.Run("JSON array", func(t *testing.T) {
+		var records []tErrorRecord
+		require.NoError(t, json.Unmarshal([]byte(run(t, "json")), &records))
+		require.Len(t, records, 2)
+		assert.Equal(t, ts.URL+"/corrupt.pdf", records[0].Url, "Records should be sorted by URL")
+		assert.NotEmpty(t, records[0].Error)
+		assert.EqualValues(t, len("Not a real PDF"), records[0].FileSize, "Downloaded file size should be recorded")
+		assert.Equal(t, "2015-10-21T07:28:00Z", records[0].HttpLastModified)
+		assert.Equal(t, ts.URL+"/missing.pdf", records[1].Url)
+		assert.Zero(t, records[1].FileSize, "Documents not downloaded have no file size")
+		assert.Equal(t, "pdf", records[1].Type)
+		assert.Contains(t, records[1].Error, "status code 404")
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSuffix(run(t, "ndjson"), "\n"), "\n")
+		require.Len(t, lines, 2, "Every record should be on its own line")
+		assert.Contains(t, lines[0], `"file_size":14`)
+		assert.NotContains(t, lines[1], `"file_size"`)
+		assert.Contains(t, lines[1], `"url":"`+ts.URL+`/missing.pdf"`)
+		assert.Contains(t, lines[1], `"error":"`)
+	})
+
+	t.Run("No failures", func(t *testing.T) {
+		engine, err := New(Config{Site: []string{"https://example.com"}, Type: []string{"pdf"}, ErrorOutput: filepath.Join(t.TempDir(), "errors.json")})
+		require.NoError(t, err)
+
+		require.NoError(t, engine.outputErrors())
+		fileContent, err := os.ReadFile(engine.errorFileName)
+		require.NoError(t, err)
+		assert.Equal(t, "[]", string(fileContent), "Error output should be an empty array")
+	})
+}
+
+func TestEngineOversized(t *testing.T) {
+	var downloads atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/big.pdf">Big</a>`))
+			return
+		}
+		if r.Method == http.MethodGet {
+			downloads.Add(1)
+		}
+		w.Header().Set("Content-Length", "2048")
+		w.Write(make([]byte, 2048))
+	}))
+	defer ts.Close()
+
+	// Only the site page is crawled, so the document is requested by the analysis alone
+	errorFile := filepath.Join(t.TempDir(), "errors.json")
+	engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"pdf"}, Paramax: 2, Depth: 1, MaxSize: "1K", ErrorOutput: errorFile})
+	require.NoError(t, err)
+	_, err = engine.Run(context.Background())
+	require.ErrorAs(t, err, new(*DocumentsError))
+	assert.Zero(t, downloads.Load(), "Oversized document should not be downloaded")
+
+	var records []tErrorRecord
+	data, err := os.ReadFile(errorFile)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &records))
+	require.Len(t, records, 1)
+	assert.Equal(t, "oversized", records[0].Reason)
+	assert.Contains(t, records[0].Error, "maximum allowed size of 1024 bytes")
+}
+
+// Mock implementation of Researcher interface for testing
+type MockResearcher struct {
+	url string
+}
+
+func (r *MockResearcher) OutJSON(writer io.Writer) error {
+	_, err := writer.Write([]byte(`{"test":"value"}`))
+	return err
+}
+
+func (r *MockResearcher) Do(ctx context.Context, url string) error {
+	r.url = url
+	return nil
+}
+
+func (r *MockResearcher) Type() string {
+	return "pdf"
+}
+
+// Testing the crawling functionality is more complex and would typically
+// require setting up a mock HTTP server with a complete website structure.
+// Here's a simplified version of what a crawl test might look like:
+
+func TestEngineCrawlSeeds(t *testing.T) {
+	// The second site is the loopback interface reached by another hostname
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/reports/":
+			w.Write([]byte(`<a href="/reports/archive.html">Archive</a>`))
+		case "/reports/archive.html":
+			w.Write([]byte(`<a href="/reports/2020.pdf">2020</a>`))
+		}
+	}))
+	defer other.Close()
+	otherUrl, err := url.Parse(other.URL)
+	require.NoError(t, err)
+	otherSite := "http://localhost:" + otherUrl.Port()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/":
+			w.Write([]byte(`<a href="/docs/manual.pdf">Manual</a>`))
+		case "/forms/":
+			w.Write([]byte(`<a href="/forms/form.pdf">Form</a>`))
+		}
+	}))
+	defer ts.Close()
+
+	engine, err := New(Config{
+		Site:    []string{ts.URL + "/docs/", ts.URL + "/forms/", otherSite + "/reports/", "http://127.0.0.1:1/missing/"},
+		Type:    []string{"pdf"},
+		Paramax: 2,
+	})
+	require.NoError(t, err)
+
+	err = engine.crawl(context.Background())
+	assert.ErrorContains(t, err, "failed to crawl site page http://127.0.0.1:1/missing/", "Failing site page should be reported")
+
+	for _, u := range []string{ts.URL + "/docs/manual.pdf", ts.URL + "/forms/form.pdf", otherSite + "/reports/2020.pdf"} {
+		parsed, err := url.Parse(u)
+		require.NoError(t, err)
+		exists, _ := engine.urlStorage.check(parsed)
+		assert.True(t, exists, "%s should be found from its site page", u)
+	}
+}
+
+func TestEngineOnSite(t *testing.T) {
+	sites := []string{"https://www.example.com/", "https://www.example.co.uk/", "http://127.0.0.1:8080/"}
+
+	testCases := []struct {
+		hostname   string
+		exact      bool // Crawled without subdomains
+		subdomains bool // Crawled with subdomains included
+	}{
+		{hostname: "www.example.com", exact: true, subdomains: true},
+		{hostname: "WWW.Example.com", exact: true, subdomains: true},
+		{hostname: "example.com", exact: false, subdomains: true},
+		{hostname: "docs.example.com", exact: false, subdomains: true},
+		{hostname: "files.eu.example.com", exact: false, subdomains: true},
+		{hostname: "notexample.com", exact: false, subdomains: false},
+		{hostname: "example.com.evil.org", exact: false, subdomains: false},
+		{hostname: "shop.example.co.uk", exact: false, subdomains: true},
+		{hostname: "other.co.uk", exact: false, subdomains: false},
+		{hostname: "127.0.0.1", exact: true, subdomains: true},
+		{hostname: "0.0.1", exact: false, subdomains: false},
+	}
+
+	exact, err := New(Config{Site: sites, Paramax: 1})
+	require.NoError(t, err)
+	subdomains, err := New(Config{Site: sites, Paramax: 1, IncludeSubdomains: true})
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		t.Run(tc.hostname, func(t *testing.T) {
+			assert.Equal(t, tc.exact, exact.onSite(tc.hostname), "Without subdomains")
+			assert.Equal(t, tc.subdomains, subdomains.onSite(tc.hostname), "With subdomains")
+		})
+	}
+}
+
+func TestEngineUrlFile(t *testing.T) {
+	// Every document is missing, so the documents analysed are those with error records
+	var mu sync.Mutex
+	fetched := make(map[string]bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched[r.URL.Path] = true
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/linked.pdf">Linked</a>`))
+		case "/page.html":
+			w.Write([]byte(`<a href="/from-page.pdf">From page</a>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	urlFile := filepath.Join(t.TempDir(), "urls.txt")
+	require.NoError(t, os.WriteFile(urlFile, []byte("# Curated\n"+ts.URL+"/listed.pdf\n"+ts.URL+"/page.html\n"), 0o644))
+
+	run := func(t *testing.T, site string, noCrawl bool) []string {
+		engine, err := New(Config{Site: []string{site}, Type: []string{"pdf"}, Paramax: 2, URLFile: urlFile, NoCrawl: noCrawl})
+		require.NoError(t, err)
+		_, _ = engine.Run(context.Background())
+
+		var analysed []string
+		for _, record := range engine.errorRecords() {
+			analysed = append(analysed, strings.TrimPrefix(record.Url, ts.URL))
+		}
+		return analysed
+	}
+
+	t.Run("Listed URLs are added to the crawl", func(t *testing.T) {
+		assert.Equal(t, []string{"/from-page.pdf", "/linked.pdf", "/listed.pdf"}, run(t, ts.URL, false),
+			"Links of listed pages should be followed too")
+	})
+
+	t.Run("No crawl", func(t *testing.T) {
+		mu.Lock()
+		fetched = make(map[string]bool)
+		mu.Unlock()
+		assert.Equal(t, []string{"/direct.pdf", "/listed.pdf"}, run(t, ts.URL+"/direct.pdf", true),
+			"Only the site and listed URLs should be analysed")
+		mu.Lock()
+		defer mu.Unlock()
+		assert.False(t, fetched["/page.html"], "No page should be crawled")
+	})
+
+	t.Run("Excluded listed URLs", func(t *testing.T) {
+		mu.Lock()
+		fetched = make(map[string]bool)
+		mu.Unlock()
+		engine, err := New(Config{Site: []string{ts.URL + "/direct.pdf"}, Type: []string{"pdf"}, Paramax: 2, URLFile: urlFile, NoCrawl: true, Exclude: []string{`glob:/listed.*`}})
+		require.NoError(t, err)
+		_, _ = engine.Run(context.Background())
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.False(t, fetched["/listed.pdf"], "Excluded listed URLs should not be fetched")
+		assert.Len(t, engine.errorRecords(), 1, "Only the site URL should be analysed")
+	})
+
+	t.Run("Invalid file", func(t *testing.T) {
+		_, err := New(Config{Site: []string{ts.URL}, URLFile: filepath.Join(t.TempDir(), "missing.txt")})
+		assert.Error(t, err)
+	})
+}
+
+func TestEngineCrawl(t *testing.T) {
+	t.Run("Basic crawl test", func(t *testing.T) {
+		// Create a test server with a simple HTML structure
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.Path
+
+			switch path {
+			case "/":
+				// Root page with links
+				w.Write([]byte(`
+					<!DOCTYPE html>
+					<html>
+					<body>
+						<a href="/page1.html">Page 1</a>
+						<a href="/page2.html">Page 2</a>
+						<a href="/document.pdf">PDF Document</a>
+					</body>
+					</html>
+				`))
+			case "/page1.html":
+				w.Write([]byte(`
+					<!DOCTYPE html>
+					<html>
+					<body>
+						<a href="/document2.pdf">Another PDF</a>
+					</body>
+					</html>
+				`))
+			case "/page2.html":
+				w.Write([]byte(`
+					<!DOCTYPE html>
+					<html>
+					<body>
+						<a href="/document3.docx">DOCX Document</a>
+					</body>
+					</html>
+				`))
+			default:
+				// For document requests, just send a small response
+				if strings.HasSuffix(path, ".pdf") || strings.HasSuffix(path, ".docx") {
+					w.Write([]byte("Mock document content"))
+				} else {
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}
+		}))
+		defer ts.Close()
+
+		// Create engine with the test server URL
+		opts := Config{
+			Site:    []string{ts.URL},
+			Type:    []string{"pdf", "docx"},
+			Output:  "",
+			Paramax: 2,
+		}
+
+		engine, err := New(opts)
+		require.NoError(t, err)
+
+		// Run crawl
+		engine.crawl(context.Background())
+
+		// Check collected URLs
+		urls := engine.urlStorage.getAllUrls()
+		urlStrings := []string{}
+		for _, u := range urls {
+			urlStrings = append(urlStrings, u.String())
+		}
+
+		// Verify expected URLs were collected
+		assert.Contains(t, urlStrings, ts.URL+"/page1.html")
+		assert.Contains(t, urlStrings, ts.URL+"/page2.html")
+		assert.Contains(t, urlStrings, ts.URL+"/document.pdf")
+		assert.Contains(t, urlStrings, ts.URL+"/document2.pdf")
+		assert.Contains(t, urlStrings, ts.URL+"/document3.docx")
+	})
+}
+
+// A full test of the analyser would also be complex as it requires actual document processing.
+// Here's a simplified example:
+
+func TestEngineAnalyser(t *testing.T) {
+	t.Run("Basic analyzer test", func(t *testing.T) {
+		// Create a test server serving mock documents
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Just return minimal content that won't actually be processed
+			// In a real test, you'd return valid PDF/DOCX content
+			w.Write([]byte("Mock document content"))
+		}))
+		defer ts.Close()
+
+		// Create engine
+		opts := Config{
+			Site:    []string{ts.URL},
+			Type:    []string{"pdf", "docx"},
+			Output:  "",
+			Paramax: 2,
+		}
+
+		engine, err := New(opts)
+		require.NoError(t, err)
+
+		// Add some URLs to analyze
+		pdfUrl, _ := url.Parse(ts.URL + "/test.pdf")
+		docxUrl, _ := url.Parse(ts.URL + "/test.docx")
+		htmlUrl, _ := url.Parse(ts.URL + "/test.html") // Not a document we care about
+
+		engine.urlStorage.add(pdfUrl)
+		engine.urlStorage.add(docxUrl)
+		engine.urlStorage.add(htmlUrl)
+
+		// Note: This will likely fail since the mock server doesn't serve real documents
+		// This is just to show how you'd structure the test
+		engine.analyser(context.Background())
+
+		// In a real test, you'd verify that engine.docStorage contains the expected entries
+		// Since we're using mock responses, this won't work correctly
+		// assert.Contains(t, engine.docStorage, pdfUrl.String())
+		// assert.Contains(t, engine.docStorage, docxUrl.String())
+		// assert.NotContains(t, engine.docStorage, htmlUrl.String())
+	})
+}
+
+// Finally, we'd have an integration test that tests the full run method,
+// but that would be very environment-dependent and is often done separately.
+
+func TestEngineProgress(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"pdf"}, Paramax: 4})
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		u, _ := url.Parse(fmt.Sprintf("%s/doc%d.pdf", ts.URL, i))
+		engine.urlStorage.add(u)
+	}
+	u, _ := url.Parse(ts.URL + "/page.html")
+	engine.urlStorage.add(u)
+
+	var calls [][2]int
+	engine.SetProgress(func(done, total int) {
+		// Not synchronized: the engine serializes the calls, the race detector checks it
+		calls = append(calls, [2]int{done, total})
+	})
+	engine.analyser(context.Background())
+
+	require.Len(t, calls, 11, "Progress should be reported for every URL")
+	for i, call := range calls {
+		assert.Equal(t, [2]int{i + 1, 11}, call, "Done should count up to the total")
+	}
+}
+
+func TestEngineCrawlMaxPages(t *testing.T) {
+	var mu sync.Mutex
+	fetched := 0
+
+	// Every page links to ten further pages, so the site is effectively endless
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched++
+		mu.Unlock()
+
+		for i := 0; i < 10; i++ {
+			fmt.Fprintf(w, `<a href="%s/%d">page</a>`, strings.TrimSuffix(r.URL.Path, "/"), i)
+		}
+	}))
+	defer ts.Close()
+
+	opts := Config{
+		Site:     []string{ts.URL},
+		Type:     []string{"pdf"},
+		Paramax:  4,
+		MaxPages: 5,
+	}
+
+	engine, err := New(opts)
+	require.NoError(t, err)
+
+	engine.crawl(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 5, fetched, "Crawl should fetch exactly max-pages pages")
+
+	total, _ := engine.urlStorage.count()
+	assert.Greater(t, total, 5, "URLs discovered on the fetched pages should still be kept")
+}
+
+func TestEngineCrawlMaxPagesCountsPages(t *testing.T) {
+	var mu sync.Mutex
+	var pages []string
+
+	// The documents are discovered before the pages, and the pages link to others beyond the depth limit
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, ".pdf"):
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write([]byte("%PDF-1.4 mock document"))
+			return
+		case r.URL.Path == "/":
+			w.Write([]byte(`<a href="/a.pdf">A</a><a href="/b.pdf">B</a><a href="/c.pdf">C</a><a href="/p1">1</a><a href="/p2">2</a><a href="/p3">3</a>`))
+		default:
+			fmt.Fprintf(w, `<a href="%s/deeper">Deeper</a>`, r.URL.Path)
+		}
+		mu.Lock()
+		pages = append(pages, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"pdf"}, Paramax: 2, Depth: 2, MaxPages: 4})
+	require.NoError(t, err)
+	require.NoError(t, engine.crawl(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.ElementsMatch(t, []string{"/", "/p1", "/p2", "/p3"}, pages, "Documents and URLs beyond the depth limit should not count as pages")
+	assert.Equal(t, int64(4), engine.stats.pagesCrawled.Load(), "Summary should count the pages as MaxPages does")
+}
+
+func TestEngineLowMemory(t *testing.T) {
+	odt := buildTestOdt(t, "Sample")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/a">A</a><a href="/b">B</a><a href="/top.odt">Top</a>`))
+		case "/a", "/b":
+			fmt.Fprintf(w, `<a href="/">Home</a><a href="%s.odt">Doc</a><a href="/a">A</a>`, r.URL.Path)
+		default:
+			w.Write(odt)
+		}
+	}))
+	defer ts.Close()
+
+	run := func(lowMemory bool) ([]string, Summary) {
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 2, LowMemory: lowMemory})
+		require.NoError(t, err)
+		results, err := engine.Run(context.Background())
+		require.NoError(t, err)
+		var urls []string
+		for _, result := range results {
+			urls = append(urls, result.Url)
+		}
+		return urls, engine.Summary()
+	}
+
+	full, fullSummary := run(false)
+	compact, compactSummary := run(true)
+	assert.Equal(t, []string{ts.URL + "/a.odt", ts.URL + "/b.odt", ts.URL + "/top.odt"}, compact)
+	assert.Equal(t, full, compact, "Low-memory mode should find the same documents")
+	assert.Equal(t, fullSummary.URLs, compactSummary.URLs)
+	assert.Equal(t, fullSummary.PagesCrawled, compactSummary.PagesCrawled)
+}
+
+func TestEngineMaxDocs(t *testing.T) {
+	odt := buildTestOdt(t, "Sample")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			for i := 0; i < 10; i++ {
+				fmt.Fprintf(w, `<a href="/%d.odt">Doc</a>`, i)
+			}
+			w.Write([]byte(`<a href="/missing.odt">Missing</a>`))
+			return
+		}
+		if r.URL.Path == "/missing.odt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(odt)
+	}))
+	defer ts.Close()
+
+	t.Run("Sequential analysis stops at the limit", func(t *testing.T) {
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 1, Depth: 1, MaxDocs: 3})
+		require.NoError(t, err)
+		results, _ := engine.Run(context.Background())
+		assert.Len(t, results, 3, "Failed documents should not count towards the limit")
+	})
+
+	t.Run("Concurrent analyses may pass the limit", func(t *testing.T) {
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 4, Depth: 1, MaxDocs: 3})
+		require.NoError(t, err)
+		results, _ := engine.Run(context.Background())
+		assert.GreaterOrEqual(t, len(results), 3)
+		assert.LessOrEqual(t, len(results), 3+4-1, "At most the analyses in flight should pass the limit")
+	})
+
+	t.Run("Unlimited by default", func(t *testing.T) {
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 4, Depth: 1})
+		require.NoError(t, err)
+		results, _ := engine.Run(context.Background())
+		assert.Len(t, results, 10)
+	})
+}
+
+func TestEngineMaxDocsSniff(t *testing.T) {
+	// An OpenDocument file starting with its mimetype entry, recognized by content
+	var odt bytes.Buffer
+	zw := zip.NewWriter(&odt)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	require.NoError(t, err)
+	w.Write([]byte("application/vnd.oasis.opendocument.text"))
+	w, err = zw.Create("meta.xml")
+	require.NoError(t, err)
+	w.Write([]byte(`<office:document-meta><office:meta><dc:title>Sniffed</dc:title></office:meta></office:document-meta>`))
+	require.NoError(t, zw.Close())
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			for i := 0; i < 10; i++ {
+				fmt.Fprintf(w, `<a href="/doc/%d">Doc</a>`, i)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(odt.Bytes())
+	}))
+	defer ts.Close()
+
+	tempDir := t.TempDir()
+	engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 4, Depth: 1, MaxDocs: 1, Sniff: true, TempDir: tempDir})
+	require.NoError(t, err)
+	engine.downloader.MaxMemory = 1 // Every download goes to a temporary file
+	results, err := engine.Run(context.Background())
+	require.NoError(t, err)
+	assert.Less(t, len(results), 10, "Analysis should stop at the limit")
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "Documents downloaded for sniffing but skipped at the limit should be removed")
+}
+
+func TestEngineMaxDuration(t *testing.T) {
+	odt := buildTestOdt(t, "Early")
+
+	// The slow page and document only answer once their request is aborted
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/a.odt">A</a><a href="/slow.odt">Slow</a>`))
+		case "/crawl":
+			w.Write([]byte(`<a href="/a.odt">A</a><a href="/slow-page">Slow</a>`))
+		case "/a.odt":
+			w.Write(odt)
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer ts.Close()
+
+	t.Run("Deadline during the analysis", func(t *testing.T) {
+		// Only the site page is crawled, the documents are fetched by the analysis alone
+		logger := &tRecordingLogger{}
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 2, Depth: 1, MaxDuration: 300 * time.Millisecond, Logger: logger})
+		require.NoError(t, err)
+
+		start := time.Now()
+		results, err := engine.Run(context.Background())
+		assert.Less(t, time.Since(start), 5*time.Second, "Run should end soon after the deadline")
+		assert.NotErrorIs(t, err, context.DeadlineExceeded, "The deadline is not an error of the run")
+
+		require.Len(t, results, 1, "Documents analysed before the deadline should be kept")
+		assert.Equal(t, ts.URL+"/a.odt", results[0].Url)
+		events := strings.Join(logger.events, "\n")
+		assert.Contains(t, events, "warn deadline reached [max_duration 300ms unprocessed 0]")
+	})
+
+	t.Run("Deadline during the crawl", func(t *testing.T) {
+		logger := &tRecordingLogger{}
+		engine, err := New(Config{Site: []string{ts.URL + "/crawl"}, Type: []string{"odt"}, Paramax: 2, MaxDuration: 300 * time.Millisecond, Logger: logger})
+		require.NoError(t, err)
+
+		results, err := engine.Run(context.Background())
+		assert.NoError(t, err)
+		assert.Empty(t, results, "No analysis should start after the deadline")
+		events := strings.Join(logger.events, "\n")
+		assert.Contains(t, events, "warn deadline reached [max_duration 300ms unprocessed 2]")
+	})
+}
+
+func TestEngineCrawlConcurrency(t *testing.T) {
+	const latency = 50 * time.Millisecond
+
+	var mu sync.Mutex
+	inFlight, peak, fetched := 0, 0, 0
+
+	// A tree of 1 + 4 + 16 pages; every page is slow to respond
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched++
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+
+		time.Sleep(latency)
+		if strings.Count(r.URL.Path, "/p") < 2 {
+			for i := 0; i < 4; i++ {
+				fmt.Fprintf(w, `<a href="%s/p%d">page</a>`, strings.TrimSuffix(r.URL.Path, "/"), i)
+			}
+		}
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	opts := Config{
+		Site:    []string{ts.URL},
+		Type:    []string{"pdf"},
+		Paramax: 3,
+	}
+
+	engine, err := New(opts)
+	require.NoError(t, err)
+
+	start := time.Now()
+	engine.crawl(context.Background())
+	elapsed := time.Since(start)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 21, fetched, "Crawl should fetch every page of the site")
+	assert.LessOrEqual(t, peak, 3, "No more than paramax pages should be fetched at once")
+	assert.Less(t, elapsed, time.Second, "Crawl should end as soon as the last worker finishes")
+}
+
+func TestEngineCrawlDeepChain(t *testing.T) {
+	const pages = 50
+
+	// Each page links only to the next one, so the queue is empty whenever a page is in flight;
+	// the last page links to a document
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(r.URL.Path, "/page%d", &n)
+		if n < pages-1 {
+			fmt.Fprintf(w, `<a href="/page%d">next</a>`, n+1)
+		} else {
+			w.Write([]byte(`<a href="/end.pdf">document</a>`))
+		}
+	}))
+	defer ts.Close()
+
+	engine, err := New(Config{Site: []string{ts.URL + "/page0"}, Type: []string{"pdf"}, Paramax: 4})
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, engine.crawl(context.Background()))
+	elapsed := time.Since(start)
+
+	urls := make(map[string]bool)
+	for _, u := range engine.urlStorage.getAllUrls() {
+		urls[u.String()] = true
+	}
+	for i := 1; i < pages; i++ {
+		assert.True(t, urls[fmt.Sprintf("%s/page%d", ts.URL, i)], "Page %d of the chain should be discovered", i)
+	}
+	assert.True(t, urls[ts.URL+"/end.pdf"], "Document at the end of the chain should be discovered")
+	assert.Less(t, elapsed, 2*time.Second, "Waiting for the only active worker should not stall the crawl")
+}
+
+func TestEngineSniff(t *testing.T) {
+	var mu sync.Mutex
+	heads := make(map[string]bool)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			mu.Lock()
+			heads[r.URL.Path] = true
+			mu.Unlock()
+		}
+		switch r.URL.Path {
+		case "/download":
+			w.Header().Set("Content-Type", "application/pdf")
+		case "/sheet":
+			w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		case "/blob":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte("%PDF-1.4 mock document"))
+			return
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+		w.Write([]byte("Mock document content"))
+	}))
+	defer ts.Close()
+
+	newSniffEngine := func(sniff bool) *Engine {
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"pdf"}, Paramax: 2, Sniff: sniff})
+		require.NoError(t, err)
+		for _, p := range []string{"/download?id=123", "/sheet?id=5", "/blob", "/page", "/report.docx"} {
+			u, _ := url.Parse(ts.URL + p)
+			engine.urlStorage.add(u)
+		}
+		return engine
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		engine := newSniffEngine(false)
+		engine.analyser(context.Background())
+
+		assert.Empty(t, engine.docStorage)
+		assert.Empty(t, engine.errorStorage)
+		assert.Empty(t, heads, "No HEAD requests should be made without --sniff")
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		engine := newSniffEngine(true)
+		engine.analyser(context.Background())
+
+		// The mock content is not a valid PDF, so the document ends up among the errors
+		_, analysed := engine.errorStorage[ts.URL+"/download?id=123"]
+		assert.True(t, analysed, "URL served as application/pdf should be analysed as PDF")
+		_, analysed = engine.errorStorage[ts.URL+"/blob"]
+		assert.True(t, analysed, "Octet-stream URL starting with %PDF should be analysed as PDF")
+		assert.Len(t, engine.errorStorage, 2, "Other types and HTML pages should not be analysed")
+		assert.Empty(t, engine.docStorage)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.True(t, heads["/page"], "URLs without an extension should be sniffed")
+		assert.False(t, heads["/report.docx"], "URLs with a known extension should not be sniffed")
+	})
+}
+
+func TestEngineSniffSingleHead(t *testing.T) {
+	var mu sync.Mutex
+	heads := make(map[string]int)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			mu.Lock()
+			heads[r.URL.Path]++
+			mu.Unlock()
+		}
+		w.Header().Set("Accept-Ranges", "bytes")
+		switch r.URL.Path {
+		case "/download":
+			w.Header().Set("Content-Type", "application/pdf")
+		case "/sheet":
+			w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		}
+		w.Write([]byte("Mock document content"))
+	}))
+	defer ts.Close()
+
+	// Type detection, the size check and the range probe all need the HEAD answer
+	engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"pdf", "xlsx"}, Paramax: 2, Sniff: true, MaxSize: "1M", RangeRequests: true})
+	require.NoError(t, err)
+	for _, p := range []string{"/download", "/sheet"} {
+		u, _ := url.Parse(ts.URL + p)
+		engine.urlStorage.add(u)
+	}
+	engine.analyser(context.Background())
+
+	assert.Len(t, engine.errorStorage, 2, "Both documents should be analysed")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]int{"/download": 1, "/sheet": 1}, heads, "Each document should get a single HEAD request")
+}
+
+func TestEngineDocTypeOf(t *testing.T) {
+	// A server that refuses HEAD, and one that qualifies the media type with parameters
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/nohead" && r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/nohead":
+			w.Write([]byte("%PDF-1.4 mock document"))
+		case r.URL.Path == "/params":
+			w.Header().Set("Content-Type", "Application/PDF; name=report.pdf")
+		case r.URL.Path == "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	// Documents detected by content stay downloaded for a researcher that never comes here
+	t.Setenv("TMPDIR", t.TempDir())
+
+	engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"pdf", "docx"}, Sniff: true})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/files/report.pdf", expected: "pdf"},
+		{path: "/files/report.docx", expected: "docx"},
+		{path: "/files/report.xlsx", expected: ""},
+		{path: "/files/REPORT.PDF", expected: "pdf"},
+		{path: "/files/report.pdf?token=abc", expected: "pdf"},
+		{path: "/files/report.docx#page=2", expected: "docx"},
+		{path: "/nohead", expected: "pdf"},
+		{path: "/params", expected: "pdf"},
+		{path: "/gone", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			u, _ := url.Parse(ts.URL + tc.path)
+			assert.Equal(t, tc.expected, engine.docTypeOf(context.Background(), u))
+		})
+	}
+}
+
+func TestExtensionOf(t *testing.T) {
+	testCases := []struct {
+		url      string
+		expected string
+	}{
+		{url: "https://example.com/report.pdf", expected: "pdf"},
+		{url: "https://example.com/REPORT.PDF", expected: "pdf"},
+		{url: "https://example.com/Report.Docx", expected: "docx"},
+		{url: "https://example.com/report.pdf?token=abc", expected: "pdf"},
+		{url: "https://example.com/report.pdf#page=2", expected: "pdf"},
+		{url: "https://example.com/download?file=report.pdf", expected: ""},
+		{url: "https://example.com/v1.2/files/report", expected: ""},
+		{url: "https://example.com/v1.2/files/report.xlsx", expected: "xlsx"},
+		{url: "https://example.com/archive.tar.gz", expected: "gz"},
+		{url: "https://example.com/docs/", expected: ""},
+		{url: "https://example.com", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, extensionOf(u))
+		})
+	}
+}
+
+func TestEngineExclude(t *testing.T) {
+	var mu sync.Mutex
+	fetched := make(map[string]bool)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched[r.URL.Path] = true
+		mu.Unlock()
+
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/docs">Docs</a><a href="/print/docs">Print</a><a href="/calendar/2024">Calendar</a><a href="/print/guide.pdf">PDF</a>`))
+		}
+	}))
+	defer ts.Close()
+
+	opts := Config{
+		Site:    []string{ts.URL},
+		Type:    []string{"pdf"},
+		Paramax: 2,
+		Exclude: []string{`/print/`, `/calendar/`},
+	}
+
+	engine, err := New(opts)
+	require.NoError(t, err)
+
+	engine.crawl(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, fetched["/docs"], "Other pages should be crawled")
+	assert.False(t, fetched["/print/docs"], "Excluded pages should not be crawled")
+	assert.False(t, fetched["/calendar/2024"], "Excluded pages should not be crawled")
+
+	total, _ := engine.urlStorage.count()
+	assert.Equal(t, 1, total, "Excluded URLs should not be stored, so they are not analysed either")
+
+	t.Run("Invalid pattern", func(t *testing.T) {
+		opts.Exclude = []string{"[a-"}
+		_, err := New(opts)
+		assert.Error(t, err, "Invalid pattern should fail engine initialization")
+		assert.Contains(t, err.Error(), "invalid exclude pattern")
+	})
+}
+
+func TestEngineInclude(t *testing.T) {
+	var mu sync.Mutex
+	fetched := make(map[string]bool)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched[r.URL.Path] = true
+		mu.Unlock()
+
+		if r.URL.Path == "/start" {
+			w.Write([]byte(`<a href="/v2/guide">Guide</a><a href="/v2/draft/notes">Draft</a><a href="/v1/guide">Old</a><a href="/blog">Blog</a>`))
+		}
+	}))
+	defer ts.Close()
+
+	opts := Config{
+		Site:    []string{ts.URL + "/start"},
+		Type:    []string{"pdf"},
+		Paramax: 2,
+		Include: []string{`/v2/`},
+		Exclude: []string{`/draft/`},
+	}
+
+	engine, err := New(opts)
+	require.NoError(t, err)
+
+	engine.crawl(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, fetched["/start"], "Seed should be crawled even if it matches no include pattern")
+	assert.True(t, fetched["/v2/guide"], "Included pages should be crawled")
+	assert.False(t, fetched["/v2/draft/notes"], "Exclusion should win over inclusion")
+	assert.False(t, fetched["/v1/guide"], "Pages matching no include pattern should not be crawled")
+	assert.False(t, fetched["/blog"], "Pages matching no include pattern should not be crawled")
+}
+
+func TestEnginePathPrefix(t *testing.T) {
+	var mu sync.Mutex
+	fetched := make(map[string]bool)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched[r.URL.Path] = true
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/docs/":
+			w.Write([]byte(`<a href="guide">Guide</a><a href="/blog">Blog</a><a href="/docs-old/a.pdf">Old</a><a href="/annual.pdf">Report</a>`))
+		case "/docs/guide":
+			w.Write([]byte(`<a href="manual.pdf">Manual</a>`))
+		}
+	}))
+	defer ts.Close()
+
+	opts := Config{Site: []string{ts.URL + "/docs/"}, Type: []string{"pdf"}, Paramax: 2, PathPrefix: "docs/"}
+	engine, err := New(opts)
+	require.NoError(t, err)
+	engine.crawl(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, fetched["/docs/guide"], "Pages under the prefix should be crawled")
+	assert.False(t, fetched["/blog"], "Pages outside the prefix should not be crawled")
+	urls := engine.urlStorage.getAllUrls()
+	var paths []string
+	for _, u := range urls {
+		paths = append(paths, u.Path)
+	}
+	assert.ElementsMatch(t, []string{"/docs/guide", "/docs/manual.pdf"}, paths, "URLs outside the prefix should not be stored, so they are not analysed either")
+
+	t.Run("Site page outside the prefix", func(t *testing.T) {
+		opts.Site = []string{ts.URL + "/blog"}
+		_, err := New(opts)
+		assert.ErrorContains(t, err, "not under the path prefix /docs/")
+	})
+}
+
+func TestEngineNoNormalize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/guide.pdf">Guide</a><a href="/guide.pdf?">Same</a><a href="/guide.pdf?utm_source=site">Tracked</a>`))
+		}
+	}))
+	defer ts.Close()
+
+	count := func(t *testing.T, noNormalize bool) int {
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"pdf"}, Paramax: 2, NoNormalize: noNormalize})
+		require.NoError(t, err)
+		require.NoError(t, engine.crawl(context.Background()))
+		total, _ := engine.urlStorage.count()
+		return total
+	}
+
+	assert.Equal(t, 1, count(t, false), "Spellings of the same document should be stored once")
+	assert.Equal(t, 3, count(t, true), "Every spelling should be stored without normalization")
+}
+
+func TestEngineUserAgent(t *testing.T) {
+	var mu sync.Mutex
+	userAgents := make(map[string]string)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		userAgents[r.URL.Path] = r.UserAgent()
+		mu.Unlock()
+
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/document.pdf">PDF</a>`))
+		}
+	}))
+	defer ts.Close()
+
+	userAgent := "docs-metadata-crawler/1.0 (+https://example.com/contact)"
+	opts := Config{
+		Site:      []string{ts.URL},
+		Type:      []string{"pdf"},
+		Paramax:   2,
+		UserAgent: userAgent,
+	}
+
+	engine, err := New(opts)
+	require.NoError(t, err)
+
+	engine.crawl(context.Background())
+	engine.analyser(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, userAgent, userAgents["/"], "Crawl requests should carry the configured User-Agent")
+	assert.Equal(t, userAgent, userAgents["/document.pdf"], "Document downloads should carry the configured User-Agent")
+}
+
+func TestEngineBasicAuth(t *testing.T) {
+	var mu sync.Mutex
+	authorized := make(map[string]bool)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		mu.Lock()
+		authorized[r.URL.Path] = ok && user == "alice" && password == "secret"
+		mu.Unlock()
+
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/document.pdf">PDF</a>`))
+		}
+	}))
+	defer ts.Close()
+
+	opts := Config{
+		Site:     []string{ts.URL},
+		Type:     []string{"pdf"},
+		Paramax:  2,
+		User:     "alice",
+		Password: "secret",
+	}
+
+	engine, err := New(opts)
+	require.NoError(t, err)
+
+	engine.crawl(context.Background())
+	engine.analyser(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, authorized["/"], "Crawl requests should be authenticated")
+	assert.True(t, authorized["/document.pdf"], "Document downloads should be authenticated")
+}
+
+func TestEngineExternalDocuments(t *testing.T) {
+	odt := buildTestOdt(t, "Partner")
+
+	// The other host is the loopback interface reached by another hostname
+	var otherPaths []string
+	var mu sync.Mutex
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		otherPaths = append(otherPaths, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/report.odt" {
+			w.Write(odt)
+			return
+		}
+		w.Write([]byte(`<a href="/hidden.odt">Hidden</a>`))
+	}))
+	defer other.Close()
+	otherUrl, err := url.Parse(other.URL)
+	require.NoError(t, err)
+	external := "http://localhost:" + otherUrl.Port()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<a href="%s/report.odt">Report</a><a href="%s/index.html">Partner</a>`, external, external)
+	}))
+	defer ts.Close()
+
+	engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 2})
+	require.NoError(t, err)
+	results, err := engine.Run(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, results, 1, "Documents linked on other hosts should be analysed")
+	assert.Equal(t, external+"/report.odt", results[0].Url)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.NotContains(t, otherPaths, "/index.html", "Pages on other hosts should not be crawled")
+	assert.NotContains(t, otherPaths, "/hidden.odt")
+}
+
+func TestEngineAnalyseExternal(t *testing.T) {
+	odt := buildTestOdt(t, "Partner")
+
+	// The other host is the loopback interface reached by another hostname
+	var otherPaths []string
+	var mu sync.Mutex
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		otherPaths = append(otherPaths, r.URL.Path)
+		mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, ".odt") {
+			w.Write(odt)
+			return
+		}
+		w.Write([]byte(`<a href="/files/hidden.odt">Hidden</a>`))
+	}))
+	defer other.Close()
+	otherUrl, err := url.Parse(other.URL)
+	require.NoError(t, err)
+	external := "http://localhost:" + otherUrl.Port()
+
+	// Only the /docs/ subtree of the site is crawled, the partner keeps its documents elsewhere
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<a href="%s/files/report.odt">Report</a><a href="%s/files/index.html">Partner</a>`, external, external)
+	}))
+	defer ts.Close()
+
+	run := func(t *testing.T, analyseExternal bool) []Result {
+		engine, err := New(Config{Site: []string{ts.URL + "/docs/"}, Type: []string{"odt"}, PathPrefix: "/docs/", AnalyseExternal: analyseExternal, Paramax: 2})
+		require.NoError(t, err)
+		results, err := engine.Run(context.Background())
+		require.NoError(t, err)
+		return results
+	}
+
+	t.Run("Path prefix applies by default", func(t *testing.T) {
+		assert.Empty(t, run(t, false), "Documents on other hosts outside the prefix should be skipped")
+	})
+
+	t.Run("Documents on other hosts kept whatever the prefix", func(t *testing.T) {
+		results := run(t, true)
+		require.Len(t, results, 1, "Documents linked on other hosts should be analysed")
+		assert.Equal(t, external+"/files/report.odt", results[0].Url)
+		mu.Lock()
+		defer mu.Unlock()
+		assert.NotContains(t, otherPaths, "/files/index.html", "Pages on other hosts should not be crawled")
+		assert.NotContains(t, otherPaths, "/files/hidden.odt")
+	})
+}
+
+func TestEngineExternalRedirect(t *testing.T) {
+	// The other host is the loopback interface reached by another hostname
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Not a real PDF"))
+	}))
+	defer other.Close()
+	otherUrl, err := url.Parse(other.URL)
+	require.NoError(t, err)
+	external := "http://localhost:" + otherUrl.Port() + "/document.pdf"
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/moved.pdf">PDF</a>`))
+		case "/moved.pdf":
+			http.Redirect(w, r, external, http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	run := func(t *testing.T, follow bool) []tErrorRecord {
+		engine, err := New(Config{
+			Site:                    []string{ts.URL},
+			Type:                    []string{"pdf"},
+			Paramax:                 2,
+			FollowExternalRedirects: follow,
+		})
+		require.NoError(t, err)
+
+		engine.crawl(context.Background())
+		engine.analyser(context.Background())
+		return engine.errorRecords()
+	}
+
+	t.Run("Refused by default", func(t *testing.T) {
+		records := run(t, false)
+		require.Len(t, records, 1)
+		assert.Equal(t, ts.URL+"/moved.pdf", records[0].Url)
+		assert.Contains(t, records[0].Error, "redirect to another host refused", "Record should name the reason")
+		assert.Contains(t, records[0].Error, external, "Record should name the redirect target")
+	})
+
+	t.Run("Followed when enabled", func(t *testing.T) {
+		records := run(t, true)
+		require.Len(t, records, 1, "Document on the other host is not a real PDF")
+		assert.NotContains(t, records[0].Error, "redirect")
+	})
+}
+
+// tRoundTripper answers requests with a function instead of a server
+type tRoundTripper func(req *http.Request) (*http.Response, error)
+
+func (rt tRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt(req)
+}
+
+func TestEngineHTTPClient(t *testing.T) {
+	pages := map[string]string{
+		"/":             `<a href="/document.pdf">PDF</a>`,
+		"/document.pdf": "Not a real PDF",
+	}
+
+	// The whole site is served by the transport of the injected client
+	var mu sync.Mutex
+	var requested []string
+	client := &http.Client{
+		Transport: tRoundTripper(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			requested = append(requested, req.Method+" "+req.URL.Path)
+			mu.Unlock()
+			body, ok := pages[req.URL.Path]
+			status := http.StatusOK
+			if !ok {
+				status = http.StatusNotFound
+			}
+			return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+		}),
+	}
+
+	engine, err := New(Config{Site: []string{"https://docs.example.com/"}, Type: []string{"pdf"}, Paramax: 2, HTTPClient: client})
+	require.NoError(t, err)
+
+	engine.crawl(context.Background())
+	engine.analyser(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, requested, "GET /", "Crawl requests should go through the injected client")
+	assert.Contains(t, requested, "HEAD /document.pdf", "Size check should go through the injected client")
+	assert.Equal(t, 2, strings.Count(strings.Join(requested, ","), "GET /document.pdf"),
+		"Document should be fetched through the injected client while crawling and for analysis")
+}
+
+func TestEngineSchemes(t *testing.T) {
+	// Documents are mirrored over a scheme of their own, served by the transport of the injected client
+	var mu sync.Mutex
+	var requested []string
+	client := &http.Client{
+		Transport: tRoundTripper(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			requested = append(requested, req.Method+" "+req.URL.Scheme+":"+req.URL.Path)
+			mu.Unlock()
+			body := "Not a real PDF"
+			if req.URL.Path == "/" {
+				body = `<a href="mirror://docs.example.com/a.pdf">A</a>
+					<a href="http://docs.example.com/b.pdf">B</a>
+					<a href="ftp://docs.example.com/c.pdf">C</a>`
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+		}),
+	}
+
+	engine, err := New(Config{Site: []string{"https://docs.example.com/"}, Type: []string{"pdf"}, Paramax: 2, Depth: 1,
+		Scheme: []string{"http", "https", "mirror"}, HTTPSOnly: true, HTTPClient: client})
+	require.NoError(t, err)
+
+	engine.crawl(context.Background())
+	engine.analyser(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, requested, "GET mirror:/a.pdf", "Documents over a configured scheme should be fetched")
+	for _, r := range requested {
+		assert.NotContains(t, r, "b.pdf", "Plain http should be rejected with HTTPS only")
+		assert.NotContains(t, r, "c.pdf", "Schemes not configured should be rejected")
+	}
+}
+
+func TestEngineProxy(t *testing.T) {
+	odt := buildTestOdt(t, "Proxied")
+
+	// The proxy serves the site itself, receiving the absolute URL of every request
+	var mu sync.Mutex
+	var requested []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.Method+" "+r.URL.String())
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/report.odt">Report</a>`))
+		case "/report.odt":
+			w.Write(odt)
+		}
+	}))
+	defer proxy.Close()
+
+	t.Setenv("HTTP_PROXY", "http://127.0.0.1:1")
+	engine, err := New(Config{Site: []string{"http://docs.example.invalid/"}, Type: []string{"odt"}, Paramax: 2, Proxy: proxy.URL})
+	require.NoError(t, err)
+	results, err := engine.Run(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, results, 1, "Requests should go through the proxy, not the one of the environment")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, requested, "GET http://docs.example.invalid/")
+	assert.Contains(t, requested, "GET http://docs.example.invalid/report.odt")
+
+	t.Run("Invalid proxy", func(t *testing.T) {
+		for _, proxy := range []string{"proxy.example.com", "ftp://proxy.example.com", "http://"} {
+			_, err := New(Config{Site: []string{"https://example.com"}, Type: []string{"pdf"}, Paramax: 1, Proxy: proxy})
+			assert.Error(t, err, proxy)
+		}
+	})
+
+	t.Run("Injected client", func(t *testing.T) {
+		client := &http.Client{Timeout: time.Minute}
+		withProxy, err := withProxy(client, "http://proxy.example.com:3128")
+		require.NoError(t, err)
+		assert.Equal(t, time.Minute, withProxy.Timeout, "Client should be copied")
+		assert.Nil(t, client.Transport, "Injected client should not be modified")
+
+		_, err = New(Config{Site: []string{"https://example.com"}, Type: []string{"pdf"}, Paramax: 1, Proxy: "http://proxy.example.com:3128",
+			HTTPClient: &http.Client{Transport: tRoundTripper(nil)}})
+		assert.ErrorContains(t, err, "proxy cannot be set")
+	})
+}
+
+func TestEngineCancellation(t *testing.T) {
+	// Every page except the root hangs until the client gives up
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			for i := 0; i < 10; i++ {
+				fmt.Fprintf(w, `<a href="/slow%d.html">page</a><a href="/slow%d.pdf">doc</a>`, i, i)
+			}
+			return
+		}
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	opts := Config{
+		Site:    []string{ts.URL},
+		Type:    []string{"pdf"},
+		Paramax: 4,
+	}
+
+	// runCancelled starts the phase, cancels it shortly after and returns how long it took to stop
+	runCancelled := func(phase func(ctx context.Context) error) time.Duration {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		start := time.Now()
+		go func() {
+			phase(ctx)
+			close(done)
+		}()
+
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+
+		select {
+		case <-done:
+			return time.Since(start)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Phase did not return after cancellation")
+			return 0
+		}
+	}
+
+	t.Run("Crawl returns promptly", func(t *testing.T) {
+		engine, err := New(opts)
+		require.NoError(t, err)
+
+		elapsed := runCancelled(engine.crawl)
+		assert.Less(t, elapsed, 2*time.Second, "Crawl should stop soon after cancellation")
+	})
+
+	t.Run("Analyser returns promptly", func(t *testing.T) {
+		engine, err := New(opts)
+		require.NoError(t, err)
+
+		for i := 0; i < 20; i++ {
+			u, _ := url.Parse(fmt.Sprintf("%s/slow%d.pdf", ts.URL, i))
+			engine.urlStorage.add(u)
+		}
+
+		elapsed := runCancelled(engine.analyser)
+		assert.Less(t, elapsed, 2*time.Second, "Analyser should stop soon after cancellation")
+		assert.Empty(t, engine.docStorage, "Aborted downloads should not produce results")
+	})
+}
+
+func TestEngineRunCancelled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<a href="/document.pdf">PDF</a>`))
+	}))
+	defer ts.Close()
+
+	outputFile := filepath.Join(t.TempDir(), "output.json")
+	opts := Config{
+		Site:    []string{ts.URL},
+		Type:    []string{"pdf"},
+		Output:  outputFile,
+		Paramax: 2,
+	}
+
+	engine, err := New(opts)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = engine.Run(ctx)
+	assert.ErrorIs(t, err, context.Canceled, "Cancelled run should report the cancellation")
+
+	// Output should still be written as valid JSON
+	fileContent, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.JSONEq(t, "[]", string(fileContent), "Cancelled run should still write a valid JSON array")
+}
+
+func TestEngineAnalyserConcurrency(t *testing.T) {
+	const (
+		documents = 8
+		latency   = 200 * time.Millisecond
+	)
+
+	// Every download takes the same time, so serial processing would need documents*latency
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(latency)
+		w.Write([]byte("Mock document content"))
+	}))
+	defer ts.Close()
+
+	opts := Config{
+		Site:    []string{ts.URL},
+		Type:    []string{"pdf"},
+		Paramax: documents,
+	}
+
+	engine, err := New(opts)
+	require.NoError(t, err)
+
+	for i := 0; i < documents; i++ {
+		u, _ := url.Parse(fmt.Sprintf("%s/doc%d.pdf", ts.URL, i))
+		engine.urlStorage.add(u)
+	}
+
+	start := time.Now()
+	engine.analyser(context.Background())
+	elapsed := time.Since(start)
+
+	assert.Less(t, elapsed, documents*latency/2, "Downloads should run in parallel up to paramax")
+}
+
+func TestEngineTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.Write([]byte(`<a href="/document.pdf">PDF</a>`))
+	}))
+	defer ts.Close()
+
+	opts := Config{
+		Site:    []string{ts.URL},
+		Type:    []string{"pdf"},
+		Paramax: 1,
+		Timeout: 100 * time.Millisecond,
+	}
+
+	engine, err := New(opts)
+	require.NoError(t, err)
+
+	t.Run("Crawl requests time out", func(t *testing.T) {
+		start := time.Now()
+		_, err := engine.crawlClient.Get(context.Background(), ts.URL)
+		assert.Error(t, err, "Slow page should time out")
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("Document downloads time out", func(t *testing.T) {
+		start := time.Now()
+		_, err := engine.downloader.Client.Get(context.Background(), ts.URL+"/document.pdf")
+		assert.Error(t, err, "Slow document should time out")
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+}
+
+// BenchmarkEngineAnalyser shows how analysis time shrinks as paramax grows
+// Run with: go test ./app -run '^$' -bench EngineAnalyser
+func BenchmarkEngineAnalyser(b *testing.B) {
+	const (
+		documents = 16
+		latency   = 10 * time.Millisecond
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(latency)
+		w.Write([]byte("Mock document content"))
+	}))
+	defer ts.Close()
+
+	for _, paramax := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("paramax=%d", paramax), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"pdf"}, Paramax: paramax})
+				require.NoError(b, err)
+				for j := 0; j < documents; j++ {
+					u, _ := url.Parse(fmt.Sprintf("%s/doc%d.pdf", ts.URL, j))
+					engine.urlStorage.add(u)
+				}
+				b.StartTimer()
+
+				engine.analyser(context.Background())
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// tEnvelope is the object the documents are written in with --wrap, instead of a bare array
+type tEnvelope struct {
+	Site      string          `json:"site"`            // First site page of the crawl
+	Sites     []string        `json:"sites,omitempty"` // Every site page, when the crawl started from several
+	CrawledAt string          `json:"crawled_at"`      // Start of the run, RFC 3339
+	Count     int             `json:"count"`           // Number of documents
+	Documents json.RawMessage `json:"documents"`       // Metadata of the documents as written by their researchers
+	Summary   *Summary        `json:"summary,omitempty"`
+}
+
+// newEnvelope wraps the documents, already serialized as a JSON array, with the details of the run
+func (engine *Engine) newEnvelope(count int, documents []byte, summary *Summary) tEnvelope {
+	envelope := tEnvelope{
+		CrawledAt: engine.started.Format(time.RFC3339),
+		Count:     count,
+		Documents: documents,
+		Summary:   summary,
+	}
+	if len(engine.seeds) > 0 {
+		envelope.Site = engine.seeds[0].String()
+	}
+	if len(engine.seeds) > 1 {
+		for _, seed := range engine.seeds {
+			envelope.Sites = append(envelope.Sites, seed.String())
+		}
+	}
+	return envelope
+}
+
+// wrappedSummary returns the summary of the run to include in the envelope, nil without --with-summary
+func (engine *Engine) wrappedSummary() *Summary {
+	if !engine.withSummary {
+		return nil
+	}
+	summary := engine.Summary()
+	return &summary
+}
+
+// writeEnvelope writes the objects to the named file or stdout wrapped in an envelope
+// The metadata JSON of each object is written by its OutJSON and nested as it is,
+// the whole envelope being indented with --pretty
+func (engine *Engine) writeEnvelope(fileName string, objects []tJsonOutputter, summary *Summary) error {
+	var documents bytes.Buffer
+	documents.WriteString("[")
+	for i, object := range objects {
+		if i > 0 {
+			documents.WriteString(",")
+		}
+		err := object.OutJSON(&documents)
+		if err != nil {
+			return err
+		}
+	}
+	documents.WriteString("]")
+
+	envelope := engine.newEnvelope(len(objects), documents.Bytes(), summary)
+	var data []byte
+	var err error
+	if engine.pretty {
+		data, err = json.MarshalIndent(envelope, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = json.Marshal(envelope)
+	}
+	if err != nil {
+		return err
+	}
+
+	out, err := openOutput(fileName)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	if out != os.Stdout {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", out.Name(), err)
+	}
+	return nil
+}
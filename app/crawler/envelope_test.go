@@ -0,0 +1,80 @@
+package crawler
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineOutputWrap(t *testing.T) {
+	newEngine := func(t *testing.T, cfg Config) *Engine {
+		cfg.Type = []string{"pdf"}
+		cfg.Wrap = true
+		cfg.Paramax = 1
+		engine, err := New(cfg)
+		require.NoError(t, err)
+		engine.started = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		for _, s := range []string{"https://example.com/a.pdf", "https://example.com/b.pdf"} {
+			u, _ := url.Parse(s)
+			engine.docStorage[s] = Result{Url: u.String(), Type: "pdf", Metadata: &MockResearcher{url: s}}
+		}
+		return engine
+	}
+
+	t.Run("Documents nested in the envelope", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "output.json")
+		engine := newEngine(t, Config{Site: []string{"https://example.com"}, Output: outputFile})
+		require.NoError(t, engine.output())
+
+		data, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		assert.Equal(t, `{"site":"https://example.com","crawled_at":"2024-05-01T12:00:00Z","count":2,"documents":[{"test":"value"},{"test":"value"}]}`, string(data),
+			"Metadata should be nested as JSON, not encoded as strings")
+	})
+
+	t.Run("Several sites and summary", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "output.json")
+		engine := newEngine(t, Config{Site: []string{"https://example.com", "https://example.org"}, Output: outputFile, WithSummary: true, Pretty: true})
+		require.NoError(t, engine.output())
+
+		data, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		var envelope struct {
+			Site      string              `json:"site"`
+			Sites     []string            `json:"sites"`
+			Count     int                 `json:"count"`
+			Documents []map[string]string `json:"documents"`
+			Summary   *Summary            `json:"summary"`
+		}
+		require.NoError(t, json.Unmarshal(data, &envelope))
+		assert.Equal(t, "https://example.com", envelope.Site)
+		assert.Equal(t, []string{"https://example.com", "https://example.org"}, envelope.Sites)
+		assert.Equal(t, 2, envelope.Count)
+		assert.Len(t, envelope.Documents, 2)
+		assert.NotNil(t, envelope.Summary, "Summary should be part of the envelope")
+		assert.Contains(t, string(data), "\n  \"documents\": [\n    {\n      \"test\": \"value\"", "Envelope should be indented as a whole")
+	})
+
+	t.Run("Split by type", func(t *testing.T) {
+		dir := t.TempDir()
+		engine := newEngine(t, Config{Site: []string{"https://example.com"}, Output: filepath.Join(dir, "output.json"), SplitByType: true, WithSummary: true})
+		require.NoError(t, engine.output())
+
+		data, err := os.ReadFile(filepath.Join(dir, "output.pdf.json"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"count":2`)
+		assert.Contains(t, string(data), `"summary":{`)
+		assert.NoFileExists(t, filepath.Join(dir, "output.summary.json"), "Summary should be in each envelope instead")
+	})
+
+	t.Run("Not in ndjson format", func(t *testing.T) {
+		_, err := New(Config{Site: []string{"https://example.com"}, Type: []string{"pdf"}, Format: "ndjson", Wrap: true, Paramax: 1})
+		assert.Error(t, err)
+	})
+}
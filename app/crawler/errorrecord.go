@@ -0,0 +1,39 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DocumentsError reports that some of the documents found failed to be analysed
+// The documents and the reasons are written to the error output, if one is configured
+type DocumentsError struct {
+	Failed int // Number of documents that failed
+}
+
+// Error returns the number of failed documents as the error message
+func (err *DocumentsError) Error() string {
+	return fmt.Sprintf("%d document(s) failed to be analysed", err.Failed)
+}
+
+// tErrorRecord describes a document that failed to be analysed
+// The file information is known when the document was downloaded but its content could not be read
+type tErrorRecord struct {
+	Url              string `json:"url"`                          // Document URL
+	Type             string `json:"doc_type"`                     // Document type / file name extension
+	Error            string `json:"error"`                        // Reason of the failure
+	Reason           string `json:"reason,omitempty"`             // Why the document was skipped without being analysed, e.g. oversized
+	FileSize         int64  `json:"file_size,omitempty"`          // Size of the downloaded file in bytes
+	HttpLastModified string `json:"http_last_modified,omitempty"` // Last-Modified header of the downloaded file
+}
+
+// OutJSON serializes the error record to JSON and writes it to the provided writer
+func (record tErrorRecord) OutJSON(writer io.Writer) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
@@ -0,0 +1,17 @@
+package crawler
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorRecordOutJSON(t *testing.T) {
+	record := tErrorRecord{Url: "https://example.com/a.pdf", Type: "pdf", Error: "failed to download file: status code 404"}
+
+	var buf bytes.Buffer
+	require.NoError(t, record.OutJSON(&buf))
+	assert.JSONEq(t, `{"url":"https://example.com/a.pdf","doc_type":"pdf","error":"failed to download file: status code 404"}`, buf.String())
+}
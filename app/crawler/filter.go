@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Prefix of the patterns written as globs over the URL path instead of regular expressions
+const globPrefix = "glob:"
+
+// tUrlFilter decides which discovered URLs are kept for crawling and analysis
+// A URL is kept when it matches no exclude pattern and, if include patterns are set,
+// at least one of them; exclusion wins when both match. A nil filter allows every URL
+// With a path prefix, URLs must also have a path under it, on whichever host, unless anyPath exempts them
+type tUrlFilter struct {
+	include    []*regexp.Regexp    // URLs must match one of these patterns (any URL if empty)
+	exclude    []*regexp.Regexp    // URLs matching any of these patterns are dropped
+	pathPrefix string              // URLs must have a path starting with it (any path if empty)
+	anyPath    func(*url.URL) bool // Reports URLs kept whatever the path prefix (none if nil)
+}
+
+// newUrlFilter compiles the include and exclude patterns into a filter
+// Returns an error naming the first pattern that fails to compile
+func newUrlFilter(include []string, exclude []string) (*tUrlFilter, error) {
+	var err error
+	filter := new(tUrlFilter)
+	filter.include, err = compilePatterns("include", include)
+	if err != nil {
+		return nil, err
+	}
+	filter.exclude, err = compilePatterns("exclude", exclude)
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// compilePatterns compiles the regular expressions of one kind of filter
+// Patterns starting with "glob:" are globs, converted by globToRegexp
+func compilePatterns(kind string, patterns []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, pattern := range patterns {
+		expr := pattern
+		if glob, ok := strings.CutPrefix(pattern, globPrefix); ok {
+			expr = globToRegexp(glob)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %w", kind, pattern, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// globToRegexp converts a glob matching the whole URL path into a regular expression
+// matching URLs with that path, whatever their scheme, host and query
+// "*" matches within a path segment, "**" across segments and "?" a single character
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	sb.WriteString(`^[a-zA-Z][a-zA-Z0-9+.-]*://[^/?#]*`)
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(`.*`)
+			i++
+		case glob[i] == '*':
+			sb.WriteString(`[^/]*`)
+		case glob[i] == '?':
+			sb.WriteString(`[^/]`)
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	sb.WriteString(`(\?.*)?$`)
+	return sb.String()
+}
+
+// allow reports whether the URL passes the filter
+func (filter *tUrlFilter) allow(u *url.URL) bool {
+	if filter == nil {
+		return true
+	}
+	if !filter.underPrefix(u) && (filter.anyPath == nil || !filter.anyPath(u)) {
+		return false
+	}
+	st := u.String()
+	for _, re := range filter.exclude {
+		if re.MatchString(st) {
+			return false
+		}
+	}
+	if len(filter.include) == 0 {
+		return true
+	}
+	for _, re := range filter.include {
+		if re.MatchString(st) {
+			return true
+		}
+	}
+	return false
+}
+
+// underPrefix reports whether the path of the URL starts with the path prefix of the filter
+// The directory named by a prefix ending in a slash is under it too, e.g. /docs for /docs/
+func (filter *tUrlFilter) underPrefix(u *url.URL) bool {
+	if filter.pathPrefix == "" {
+		return true
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	return strings.HasPrefix(path, filter.pathPrefix) || path+"/" == filter.pathPrefix
+}
@@ -0,0 +1,141 @@
+package crawler
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUrlFilter(t *testing.T) {
+	t.Run("Exclude patterns", func(t *testing.T) {
+		filter, err := newUrlFilter(nil, []string{`/print/`, `^https://example\.com/calendar/`})
+		require.NoError(t, err)
+
+		testCases := []struct {
+			url     string
+			allowed bool
+		}{
+			{url: "https://example.com/docs/guide.pdf", allowed: true},
+			{url: "https://example.com/print/docs/guide", allowed: false},
+			{url: "https://example.com/calendar/2024/01", allowed: false},
+			{url: "https://example.com/events/calendar/", allowed: true},
+		}
+
+		for _, tc := range testCases {
+			u, _ := url.Parse(tc.url)
+			assert.Equal(t, tc.allowed, filter.allow(u), tc.url)
+		}
+	})
+
+	t.Run("Include patterns", func(t *testing.T) {
+		filter, err := newUrlFilter([]string{`^https://docs\.example\.com/v2/`, `\.pdf$`}, []string{`/draft/`})
+		require.NoError(t, err)
+
+		testCases := []struct {
+			url     string
+			allowed bool
+		}{
+			{url: "https://docs.example.com/v2/guide", allowed: true},
+			{url: "https://docs.example.com/v1/guide", allowed: false},
+			{url: "https://docs.example.com/v1/guide.pdf", allowed: true},
+			{url: "https://docs.example.com/v2/draft/notes", allowed: false},
+			{url: "https://example.com/blog", allowed: false},
+		}
+
+		for _, tc := range testCases {
+			u, _ := url.Parse(tc.url)
+			assert.Equal(t, tc.allowed, filter.allow(u), tc.url)
+		}
+	})
+
+	t.Run("Overlapping patterns", func(t *testing.T) {
+		filter, err := newUrlFilter([]string{`/reports/`, `\.pdf$`}, []string{`/reports/archive/`, `/drafts/.*\.pdf$`})
+		require.NoError(t, err)
+
+		testCases := []struct {
+			url     string
+			allowed bool
+		}{
+			{url: "https://example.com/reports/2024.pdf", allowed: true},
+			{url: "https://example.com/reports/archive/2010.pdf", allowed: false},
+			{url: "https://example.com/reports/archive/", allowed: false},
+			{url: "https://example.com/drafts/plan.pdf", allowed: false},
+			{url: "https://example.com/drafts/plan.odt", allowed: false},
+			{url: "https://example.com/files/plan.pdf", allowed: true},
+		}
+
+		for _, tc := range testCases {
+			u, _ := url.Parse(tc.url)
+			assert.Equal(t, tc.allowed, filter.allow(u), tc.url)
+		}
+	})
+
+	t.Run("Glob patterns", func(t *testing.T) {
+		filter, err := newUrlFilter([]string{`glob:/reports/**`}, []string{`glob:/reports/archive/**`, `glob:/reports/*.tmp`})
+		require.NoError(t, err)
+
+		testCases := []struct {
+			url     string
+			allowed bool
+		}{
+			{url: "https://example.com/reports/2024/q1.pdf", allowed: true},
+			{url: "https://example.com/reports/q1.pdf?download=1", allowed: true},
+			{url: "https://example.com/reports/archive/2010.pdf", allowed: false},
+			{url: "https://example.com/reports/q1.tmp", allowed: false},
+			{url: "https://example.com/reports/2024/q1.tmp", allowed: true},
+			{url: "https://example.com/old/reports/q1.pdf", allowed: false},
+			{url: "https://example.com/reports.pdf", allowed: false},
+		}
+
+		for _, tc := range testCases {
+			u, _ := url.Parse(tc.url)
+			assert.Equal(t, tc.allowed, filter.allow(u), tc.url)
+		}
+	})
+
+	t.Run("No patterns allow everything", func(t *testing.T) {
+		filter, err := newUrlFilter(nil, nil)
+		require.NoError(t, err)
+
+		u, _ := url.Parse("https://example.com/print/")
+		assert.True(t, filter.allow(u))
+
+		var nilFilter *tUrlFilter
+		assert.True(t, nilFilter.allow(u), "Nil filter should allow every URL")
+	})
+
+	t.Run("Path prefix", func(t *testing.T) {
+		filter, err := newUrlFilter(nil, []string{`\.tmp$`})
+		require.NoError(t, err)
+		filter.pathPrefix = "/docs/"
+
+		tests := []struct {
+			url     string
+			allowed bool
+		}{
+			{"https://example.com/docs/guide.pdf", true},
+			{"https://docs.example.com/docs/v2/", true},
+			{"https://example.com/docs", true},
+			{"https://example.com/docs/draft.tmp", false},
+			{"https://example.com/docs-old/guide.pdf", false},
+			{"https://example.com/", false},
+			{"https://example.com", false},
+		}
+		for _, tt := range tests {
+			u, _ := url.Parse(tt.url)
+			assert.Equal(t, tt.allowed, filter.allow(u), tt.url)
+		}
+	})
+
+	t.Run("Invalid pattern", func(t *testing.T) {
+		_, err := newUrlFilter(nil, []string{`/docs/`, `(unclosed`})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid exclude pattern "(unclosed"`)
+
+		_, err = newUrlFilter([]string{`*docs`}, nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid include pattern "*docs"`)
+	})
+}
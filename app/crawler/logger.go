@@ -0,0 +1,71 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Log levels, from the most to the least verbose
+const (
+	LogDebug = "debug" // Every URL discovered
+	LogInfo  = "info"  // Pages fetched and documents analysed
+	LogWarn  = "warn"  // Pages, sitemaps and documents that failed
+	LogError = "error" // Failures of the whole crawl
+	LogQuiet = "quiet" // Nothing
+)
+
+// Log formats
+const (
+	LogText = "text" // key=value pairs
+	LogJSON = "json" // JSON object per event
+)
+
+// Logger receives the events of a crawl
+// Messages are followed by alternating keys and values, so a *slog.Logger can be used as is
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// NewLogger creates a logger writing the events of the given level and above to the writer
+// in the given format, one line per event
+// An empty level is quiet, an empty format is text
+func NewLogger(writer io.Writer, level, format string) (Logger, error) {
+	var newHandler func(io.Writer, *slog.HandlerOptions) slog.Handler
+	switch format {
+	case "", LogText:
+		newHandler = func(w io.Writer, opts *slog.HandlerOptions) slog.Handler { return slog.NewTextHandler(w, opts) }
+	case LogJSON:
+		newHandler = func(w io.Writer, opts *slog.HandlerOptions) slog.Handler { return slog.NewJSONHandler(w, opts) }
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+
+	var slogLevel slog.Level
+	switch level {
+	case LogDebug:
+		slogLevel = slog.LevelDebug
+	case LogInfo:
+		slogLevel = slog.LevelInfo
+	case LogWarn:
+		slogLevel = slog.LevelWarn
+	case LogError:
+		slogLevel = slog.LevelError
+	case "", LogQuiet:
+		return tQuietLogger{}, nil
+	default:
+		return nil, fmt.Errorf("unknown log level %q", level)
+	}
+	return slog.New(newHandler(writer, &slog.HandlerOptions{Level: slogLevel})), nil
+}
+
+// tQuietLogger discards every event
+type tQuietLogger struct{}
+
+func (tQuietLogger) Debug(msg string, args ...any) {}
+func (tQuietLogger) Info(msg string, args ...any)  {}
+func (tQuietLogger) Warn(msg string, args ...any)  {}
+func (tQuietLogger) Error(msg string, args ...any) {}
@@ -0,0 +1,95 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tRecordingLogger keeps the events logged as "level msg" lines
+type tRecordingLogger struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *tRecordingLogger) record(level, msg string, args []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, fmt.Sprint(level, " ", msg, " ", args))
+}
+
+func (l *tRecordingLogger) Debug(msg string, args ...any) { l.record(LogDebug, msg, args) }
+func (l *tRecordingLogger) Info(msg string, args ...any)  { l.record(LogInfo, msg, args) }
+func (l *tRecordingLogger) Warn(msg string, args ...any)  { l.record(LogWarn, msg, args) }
+func (l *tRecordingLogger) Error(msg string, args ...any) { l.record(LogError, msg, args) }
+
+func TestNewLogger(t *testing.T) {
+	logAll := func(logger Logger) {
+		logger.Debug("debug event")
+		logger.Info("info event", "url", "https://example.com")
+		logger.Warn("warn event")
+		logger.Error("error event")
+	}
+
+	t.Run("Level and above", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, err := NewLogger(&buf, LogWarn, LogText)
+		require.NoError(t, err)
+
+		logAll(logger)
+		assert.NotContains(t, buf.String(), "debug event")
+		assert.NotContains(t, buf.String(), "info event")
+		assert.Contains(t, buf.String(), "level=WARN msg=\"warn event\"")
+		assert.Contains(t, buf.String(), "level=ERROR msg=\"error event\"")
+	})
+
+	t.Run("Key value pairs", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, err := NewLogger(&buf, LogDebug, LogText)
+		require.NoError(t, err)
+
+		logAll(logger)
+		assert.Contains(t, buf.String(), "msg=\"info event\" url=https://example.com")
+		assert.Equal(t, 4, bytes.Count(buf.Bytes(), []byte("\n")), "Every event should be logged at debug level")
+	})
+
+	for _, level := range []string{"", LogQuiet} {
+		t.Run("Quiet "+level, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := NewLogger(&buf, level, LogText)
+			require.NoError(t, err)
+
+			logAll(logger)
+			assert.Empty(t, buf.String())
+		})
+	}
+
+	t.Run("JSON format", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, err := NewLogger(&buf, LogInfo, LogJSON)
+		require.NoError(t, err)
+
+		logger.Info("info event", "url", "https://example.com", "depth", 2)
+		var event map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &event), "Event should be a JSON object")
+		assert.Equal(t, "INFO", event["level"])
+		assert.Equal(t, "info event", event["msg"])
+		assert.Equal(t, "https://example.com", event["url"])
+		assert.Equal(t, 2.0, event["depth"])
+	})
+
+	t.Run("Unknown level", func(t *testing.T) {
+		_, err := NewLogger(&bytes.Buffer{}, "verbose", LogText)
+		assert.EqualError(t, err, `unknown log level "verbose"`)
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		_, err := NewLogger(&bytes.Buffer{}, LogQuiet, "xml")
+		assert.EqualError(t, err, `unknown log format "xml"`, "Format should be checked even if nothing is logged")
+	})
+}
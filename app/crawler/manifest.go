@@ -0,0 +1,94 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Version of the manifest format, increased on every incompatible change
+// Manifests of other versions are ignored
+const manifestVersion = 1
+
+// tManifest records the documents analysed by a run, with the validators they were served with,
+// so the next run asks the server whether they changed instead of downloading them again
+type tManifest struct {
+	Version   int              `json:"version"`
+	Documents []tStateDocument `json:"documents"` // Documents analysed, sorted by URL
+}
+
+// loadManifest reads the manifest of the earlier run: the downloads of its documents become
+// conditional, and the documents the server reports unchanged keep their recorded metadata
+// A missing manifest starts afresh, as does an unreadable one, which is logged
+func (engine *Engine) loadManifest() {
+	data, err := os.ReadFile(engine.manifestFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	var manifest tManifest
+	if err == nil {
+		err = json.Unmarshal(data, &manifest)
+	}
+	if err == nil && manifest.Version != manifestVersion {
+		err = fmt.Errorf("unsupported version %d", manifest.Version)
+	}
+	if err != nil {
+		engine.logger.Warn("manifest ignored", "file", engine.manifestFile, "error", err)
+		return
+	}
+
+	for _, doc := range manifest.Documents {
+		// Documents restored from the state file are already known
+		if _, restored := engine.restored[doc.Url]; !restored {
+			engine.addPrior(doc.Url, doc.restore())
+		}
+	}
+	engine.logger.Info("manifest loaded", "file", engine.manifestFile, "documents", len(manifest.Documents))
+}
+
+// saveManifest records the documents of the run in the manifest, replacing the earlier one,
+// the documents carried forward from it included
+// A run cut short also keeps the documents of the earlier manifest it did not get to,
+// a completed run drops those no longer found
+func (engine *Engine) saveManifest(interrupted bool) error {
+	manifest := tManifest{Version: manifestVersion, Documents: []tStateDocument{}}
+	engine.mutex.Lock()
+	for _, result := range engine.docStorage {
+		doc, err := newStateDocument(result)
+		if err != nil {
+			continue
+		}
+		manifest.Documents = append(manifest.Documents, doc)
+	}
+	if interrupted {
+		for u, prior := range engine.prior {
+			_, analysed := engine.docStorage[u]
+			_, failed := engine.errorStorage[u]
+			if analysed || failed {
+				continue
+			}
+			doc, err := newStateDocument(Result{Url: u, Type: prior.docType, Metadata: prior})
+			if err != nil {
+				continue
+			}
+			manifest.Documents = append(manifest.Documents, doc)
+		}
+	}
+	engine.mutex.Unlock()
+	slices.SortFunc(manifest.Documents, func(a, b tStateDocument) int {
+		return strings.Compare(a.Url, b.Url)
+	})
+
+	data, err := json.Marshal(manifest)
+	if err == nil {
+		err = writeFileAtomic(engine.manifestFile, data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", engine.manifestFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package crawler
+
+import (
+	"context"
+	"docscrawler/app/researchers"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineManifest(t *testing.T) {
+	// Each document is served with an ETag of its version, answering 304 to a request for the same version
+	var mu sync.Mutex
+	versions := map[string]string{"/a.odt": "v1", "/b.odt": "v1"}
+	requests := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/a.odt">A</a><a href="/b.odt">B</a>`))
+			return
+		}
+		mu.Lock()
+		version := versions[r.URL.Path]
+		if r.Method == http.MethodGet {
+			requests[r.URL.Path]++
+		}
+		mu.Unlock()
+		etag := `"` + version + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write(buildTestOdt(t, "Title "+version))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	manifestFile := filepath.Join(dir, "manifest.json")
+	cfg := Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 2, Depth: 1, Manifest: manifestFile}
+	titles := func(results []Result) map[string]string {
+		titles := make(map[string]string)
+		for _, result := range results {
+			titles[result.Url] = result.Metadata.(researchers.Describer).DocTitle()
+		}
+		return titles
+	}
+
+	t.Run("First run records the documents", func(t *testing.T) {
+		engine, err := New(cfg)
+		require.NoError(t, err)
+		_, err = engine.Run(context.Background())
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(manifestFile)
+		require.NoError(t, err)
+		var manifest tManifest
+		require.NoError(t, json.Unmarshal(data, &manifest))
+		assert.Equal(t, manifestVersion, manifest.Version)
+		require.Len(t, manifest.Documents, 2)
+		assert.Equal(t, ts.URL+"/a.odt", manifest.Documents[0].Url, "Documents should be sorted by URL")
+		assert.Equal(t, `"v1"`, manifest.Documents[0].ETag)
+		assert.Contains(t, string(manifest.Documents[0].Metadata), "Title v1")
+	})
+
+	t.Run("Next run downloads only the changed documents", func(t *testing.T) {
+		mu.Lock()
+		versions["/b.odt"] = "v2"
+		clear(requests)
+		mu.Unlock()
+
+		engine, err := New(cfg)
+		require.NoError(t, err)
+		results, err := engine.Run(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]string{ts.URL + "/a.odt": "Title v1", ts.URL + "/b.odt": "Title v2"}, titles(results),
+			"Unchanged document should keep its recorded metadata")
+		assert.Equal(t, map[string]int{"/a.odt": 1, "/b.odt": 1}, requests, "Each document should be requested once, conditionally")
+		assert.EqualValues(t, 2, engine.Summary().Analysed)
+
+		data, err := os.ReadFile(manifestFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"etag":"\"v2\""`, "Manifest should record the new version")
+		assert.Contains(t, string(data), "Title v1", "Manifest should carry the unchanged document forward")
+	})
+
+	t.Run("Corrupt manifest ignored", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(manifestFile, []byte(`{"version": 1, "documents": [`), 0o644))
+		mu.Lock()
+		clear(requests)
+		mu.Unlock()
+
+		engine, err := New(cfg)
+		require.NoError(t, err)
+		results, err := engine.Run(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+		_, isRestored := results[0].Metadata.(*tRestored)
+		assert.False(t, isRestored, "Documents should be analysed afresh")
+	})
+
+	t.Run("Not with a streamed output", func(t *testing.T) {
+		streamed := cfg
+		streamed.Output = filepath.Join(dir, "output.json")
+		streamed.Stream = true
+		_, err := New(streamed)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,103 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Progress modes
+const (
+	ProgressAuto  = "auto"  // Report progress when stderr is a terminal
+	ProgressForce = "force" // Report progress even when stderr is not a terminal
+)
+
+// Interval between refreshes of the status line
+const statusInterval = time.Second
+
+// tStats counts the work of a run, updated concurrently by the crawl and analysis workers
+type tStats struct {
+	pagesCrawled atomic.Int64 // HTML pages fetched while crawling, as counted by MaxPages
+	docsFound    atomic.Int64 // URLs recognized as documents of the requested types
+	docsAnalysed atomic.Int64 // Documents analysed successfully
+	docsFailed   atomic.Int64 // Documents that failed to be analysed
+	urlsDone     atomic.Int64 // Discovered URLs the analyser finished with, documents or not
+
+	docsByType map[string]*atomic.Int64 // Documents found, by requested type
+}
+
+// processed returns the number of pages and documents finished with
+func (stats *tStats) processed() int64 {
+	return stats.pagesCrawled.Load() + stats.docsAnalysed.Load() + stats.docsFailed.Load()
+}
+
+// isTerminal reports whether the file is a terminal rather than a pipe or a regular file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// status formats the one-line status of the run
+func (engine *Engine) status(elapsed time.Duration, rate float64) string {
+	discovered, _ := engine.urlStorage.count()
+	return fmt.Sprintf("URLs: %d discovered, %d crawled | documents: %d found, %d analysed, %d failed | %s, %.1f/s",
+		discovered,
+		engine.stats.pagesCrawled.Load(),
+		engine.stats.docsFound.Load(),
+		engine.stats.docsAnalysed.Load(),
+		engine.stats.docsFailed.Load(),
+		elapsed.Truncate(time.Second),
+		rate,
+	)
+}
+
+// startStatus refreshes the status line on the writer every statusInterval, overwriting it with \r
+// The rate is the number of pages and documents finished with per second since the last refresh
+// Returns a function stopping the refreshes, which writes the final status with the average rate
+// and ends the line, so that output written after it starts on a line of its own
+func (engine *Engine) startStatus(writer io.Writer) (stop func()) {
+	start := time.Now()
+	quit := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(statusInterval)
+		defer ticker.Stop()
+
+		// A shorter line is padded with spaces to overwrite the previous one
+		width := 0
+		write := func(line, end string) {
+			width = max(width, len(line))
+			fmt.Fprintf(writer, "\r%-*s%s", width, line, end)
+		}
+
+		last, lastTime := int64(0), start
+		for {
+			select {
+			case <-quit:
+				elapsed := time.Since(start)
+				rate := float64(engine.stats.processed()) / elapsed.Seconds()
+				write(engine.status(elapsed, rate), "\n")
+				return
+			case now := <-ticker.C:
+				processed := engine.stats.processed()
+				rate := float64(processed-last) / now.Sub(lastTime).Seconds()
+				last, lastTime = processed, now
+				write(engine.status(now.Sub(start), rate), "")
+			}
+		}
+	}()
+
+	stopped := false
+	return func() {
+		if stopped {
+			return
+		}
+		stopped = true
+		close(quit)
+		<-finished
+	}
+}
@@ -0,0 +1,71 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineStatus(t *testing.T) {
+	engine, err := New(Config{Site: []string{"https://example.com"}, Paramax: 1})
+	require.NoError(t, err)
+
+	for _, u := range []string{"https://example.com/", "https://example.com/a.pdf", "https://example.com/b.pdf"} {
+		parsed, err := url.Parse(u)
+		require.NoError(t, err)
+		engine.urlStorage.add(parsed)
+	}
+	engine.stats.pagesCrawled.Add(1)
+	engine.stats.docsFound.Add(2)
+	engine.stats.docsAnalysed.Add(1)
+	engine.stats.docsFailed.Add(1)
+
+	assert.Equal(t,
+		"URLs: 3 discovered, 1 crawled | documents: 2 found, 1 analysed, 1 failed | 1m5s, 2.5/s",
+		engine.status(65*time.Second+300*time.Millisecond, 2.5))
+}
+
+func TestEngineProgressStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/missing.pdf">PDF</a>`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	engine, err := New(Config{
+		Site:    []string{ts.URL},
+		Type:    []string{"pdf"},
+		Output:  dir + "/output.json",
+		Paramax: 2,
+	})
+	require.NoError(t, err)
+	var status bytes.Buffer
+	engine.statusOut = &status
+
+	_, err = engine.Run(context.Background())
+	var docErr *DocumentsError
+	require.ErrorAs(t, err, &docErr)
+
+	// The run is shorter than a refresh, only the final status is written
+	// The link is discovered on the site page and fetched while crawling, but only the site page is an HTML page
+	assert.True(t, strings.HasPrefix(status.String(), "\r"), "Status line should overwrite the line")
+	assert.True(t, strings.HasSuffix(status.String(), "\n"), "Final status should end the line")
+	assert.Contains(t, status.String(), "URLs: 1 discovered, 1 crawled | documents: 1 found, 0 analysed, 1 failed")
+
+	output, err := os.ReadFile(dir + "/output.json")
+	require.NoError(t, err)
+	assert.NotContains(t, string(output), "URLs:", "Status should not be written to the output")
+}
@@ -0,0 +1,75 @@
+package crawler
+
+import (
+	"bytes"
+	"cmp"
+	"docscrawler/app/researchers"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Fields the results are sorted by
+const (
+	SortByUrl      = "url"      // Document URL, the default
+	SortByTitle    = "title"    // Document title, case-insensitive
+	SortByModified = "modified" // Last modification date, oldest first
+)
+
+// Result is the metadata of an analysed document
+type Result struct {
+	Url      string                 // Document URL
+	Type     string                 // Document type / file name extension
+	Metadata researchers.Researcher // Researcher holding the extracted metadata
+}
+
+// MarshalJSON serializes the result as the metadata JSON object of its researcher
+func (result Result) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	err := result.Metadata.OutJSON(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sortResults sorts the results by the given field, then by URL
+// Documents missing the field, or whose researcher does not expose it, come after the others
+// Modification dates that are not RFC 3339 count as missing
+func sortResults(results []Result, by string) {
+	key := func(result Result) (string, bool) {
+		describer, ok := result.Metadata.(researchers.Describer)
+		if !ok {
+			return "", false
+		}
+		switch by {
+		case SortByTitle:
+			title := strings.ToLower(describer.DocTitle())
+			return title, title != ""
+		case SortByModified:
+			t, err := time.Parse(time.RFC3339, describer.DocModified())
+			if err != nil {
+				return "", false
+			}
+			// Same width in UTC, so the strings sort as the dates do
+			return t.UTC().Format("2006-01-02T15:04:05Z"), true
+		}
+		return "", false
+	}
+
+	slices.SortStableFunc(results, func(a, b Result) int {
+		if by != SortByUrl {
+			ka, oka := key(a)
+			kb, okb := key(b)
+			switch {
+			case oka && !okb:
+				return -1
+			case !oka && okb:
+				return 1
+			case oka && okb && ka != kb:
+				return cmp.Compare(ka, kb)
+			}
+		}
+		return cmp.Compare(a.Url, b.Url)
+	})
+}
@@ -0,0 +1,66 @@
+package crawler
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultMarshalJSON(t *testing.T) {
+	results := []Result{
+		{Url: "https://example.com/a.pdf", Type: "pdf", Metadata: &MockResearcher{}},
+		{Url: "https://example.com/b.pdf", Type: "pdf", Metadata: &MockResearcher{}},
+	}
+
+	data, err := json.Marshal(results)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"test":"value"},{"test":"value"}]`, string(data), "Results should serialize as the metadata of their researchers")
+}
+
+// tDescribedResearcher is a researcher with a title and modification date
+type tDescribedResearcher struct {
+	MockResearcher
+	title    string
+	modified string
+}
+
+func (r *tDescribedResearcher) DocTitle() string    { return r.title }
+func (r *tDescribedResearcher) DocAuthor() string   { return "" }
+func (r *tDescribedResearcher) DocCreated() string  { return "" }
+func (r *tDescribedResearcher) DocModified() string { return r.modified }
+
+func TestSortResults(t *testing.T) {
+	results := []Result{
+		{Url: "https://example.com/e.pdf", Metadata: &MockResearcher{}},
+		{Url: "https://example.com/d.pdf", Metadata: &tDescribedResearcher{title: "beta", modified: "2024-01-01T00:00:00+02:00"}},
+		{Url: "https://example.com/c.pdf", Metadata: &tDescribedResearcher{title: "Alpha", modified: "2023-12-31T23:00:00Z"}},
+		{Url: "https://example.com/b.pdf", Metadata: &tDescribedResearcher{modified: "D:20240101"}},
+		{Url: "https://example.com/a.pdf", Metadata: &tDescribedResearcher{title: "beta"}},
+	}
+	urls := func(results []Result) []string {
+		var names []string
+		for _, result := range results {
+			names = append(names, result.Url[len("https://example.com/"):])
+		}
+		return names
+	}
+
+	tests := []struct {
+		by       string
+		expected []string
+	}{
+		{SortByUrl, []string{"a.pdf", "b.pdf", "c.pdf", "d.pdf", "e.pdf"}},
+		{SortByTitle, []string{"c.pdf", "a.pdf", "d.pdf", "b.pdf", "e.pdf"}},
+		{SortByModified, []string{"d.pdf", "c.pdf", "a.pdf", "b.pdf", "e.pdf"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.by, func(t *testing.T) {
+			sorted := slices.Clone(results)
+			sortResults(sorted, tt.by)
+			assert.Equal(t, tt.expected, urls(sorted))
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package crawler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"docscrawler/app/fetch"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Constants limiting sitemap processing
+const (
+	sitemapMaxNesting = 5                // Maximum nesting level of sitemap indexes
+	sitemapMaxSize    = 50 * 1024 * 1024 // Maximum uncompressed sitemap size (protocol limit is 50MB)
+)
+
+// tSitemap represents both a <urlset> sitemap and a <sitemapindex>
+// Only one of the slices is filled depending on the root element
+type tSitemap struct {
+	XMLName  xml.Name      // Root element name (urlset or sitemapindex)
+	Urls     []tSitemapLoc `xml:"url"`     // Page entries of a urlset
+	Sitemaps []tSitemapLoc `xml:"sitemap"` // Child sitemap entries of a sitemap index
+}
+
+// tSitemapLoc is a single sitemap entry, only the location is used
+type tSitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// seedSitemap fetches /sitemap.xml of the site and adds every listed page to the URL storage
+// Nested sitemap indexes and gzip-compressed sitemaps are followed; malformed sitemaps
+// and entries are skipped with a warning; pages rejected by the filter are left out
+// Returns the number of URLs added
+func seedSitemap(ctx context.Context, client *fetch.Client, site *url.URL, urlStorage *tUrlStorage, filter *tUrlFilter, logger Logger) int {
+	sitemapUrl := site.ResolveReference(&url.URL{Path: "/sitemap.xml"})
+	visited := make(map[string]bool)
+	return walkSitemap(ctx, client, sitemapUrl, urlStorage, filter, logger, visited, 0)
+}
+
+// walkSitemap processes one sitemap and recursively the child sitemaps it refers to
+func walkSitemap(ctx context.Context, client *fetch.Client, sitemapUrl *url.URL, urlStorage *tUrlStorage, filter *tUrlFilter, logger Logger, visited map[string]bool, nesting int) int {
+	if visited[sitemapUrl.String()] {
+		return 0
+	}
+	visited[sitemapUrl.String()] = true
+
+	sitemap, err := fetchSitemap(ctx, client, sitemapUrl)
+	if err != nil {
+		logger.Warn("sitemap skipped", "url", sitemapUrl, "error", err)
+		return 0
+	}
+
+	added := 0
+	for _, entry := range sitemap.Urls {
+		u, err := parseSitemapLoc(entry.Loc)
+		if err != nil {
+			logger.Warn("malformed sitemap entry skipped", "sitemap", sitemapUrl, "error", err)
+			continue
+		}
+		if filter.allow(u) && urlStorage.add(u) {
+			logger.Debug("url discovered", "url", u, "sitemap", sitemapUrl)
+			added++
+		}
+	}
+
+	for _, entry := range sitemap.Sitemaps {
+		u, err := parseSitemapLoc(entry.Loc)
+		if err != nil {
+			logger.Warn("malformed child sitemap skipped", "sitemap", sitemapUrl, "error", err)
+			continue
+		}
+		if nesting >= sitemapMaxNesting {
+			logger.Warn("sitemap skipped, indexes nested too deeply", "url", u)
+			continue
+		}
+		added += walkSitemap(ctx, client, u, urlStorage, filter, logger, visited, nesting+1)
+	}
+
+	return added
+}
+
+// fetchSitemap downloads and decodes a sitemap, transparently handling gzip compression
+func fetchSitemap(ctx context.Context, client *fetch.Client, sitemapUrl *url.URL) (*tSitemap, error) {
+	resp, err := client.Get(ctx, sitemapUrl.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	// .xml.gz files are served as gzip data rather than with Content-Encoding,
+	// so detect compression by the gzip magic bytes
+	var body io.Reader = bufio.NewReader(resp.Body)
+	if magic, err := body.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	sitemap := new(tSitemap)
+	err = xml.NewDecoder(io.LimitReader(body, sitemapMaxSize)).Decode(sitemap)
+	if err != nil {
+		return nil, err
+	}
+	return sitemap, nil
+}
+
+// parseSitemapLoc validates a <loc> value, which must be an absolute http(s) URL
+// as the sitemap protocol requires; the accepted schemes are checked when the page is crawled
+func parseSitemapLoc(loc string) (*url.URL, error) {
+	loc = strings.TrimSpace(loc)
+	u, err := url.Parse(loc)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("not an absolute http(s) URL: %q", loc)
+	}
+	return u, nil
+}
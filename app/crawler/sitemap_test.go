@@ -0,0 +1,90 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"docscrawler/app/fetch"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeedSitemap(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			// Sitemap index pointing to a plain, a compressed and a missing child sitemap
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+				<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+					<sitemap><loc>` + ts.URL + `/sitemap-pages.xml</loc></sitemap>
+					<sitemap><loc>` + ts.URL + `/sitemap-docs.xml.gz</loc></sitemap>
+					<sitemap><loc>` + ts.URL + `/sitemap-missing.xml</loc></sitemap>
+					<sitemap><loc>` + ts.URL + `/sitemap.xml</loc></sitemap>
+				</sitemapindex>`))
+		case "/sitemap-pages.xml":
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+				<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+					<url><loc>` + ts.URL + `/hidden.html</loc></url>
+					<url><loc>not a url</loc></url>
+					<url><loc>ftp://example.com/file.pdf</loc></url>
+				</urlset>`))
+		case "/sitemap-docs.xml.gz":
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			gz.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+				<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+					<url><loc>` + ts.URL + `/report.pdf</loc></url>
+				</urlset>`))
+			gz.Close()
+			w.Write(buf.Bytes())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	site, err := url.Parse(ts.URL + "/start/")
+	require.NoError(t, err)
+
+	urlStorage := newUrlStorage()
+	added := seedSitemap(context.Background(), fetch.NewClient(fetch.Options{Timeout: crawlHttpTimeout}), site, urlStorage, nil, tQuietLogger{})
+
+	assert.Equal(t, 2, added, "Only valid entries should be added")
+
+	hidden, _ := url.Parse(ts.URL + "/hidden.html")
+	exists, _ := urlStorage.check(hidden)
+	assert.True(t, exists, "Page from the plain child sitemap should be added")
+
+	report, _ := url.Parse(ts.URL + "/report.pdf")
+	exists, _ = urlStorage.check(report)
+	assert.True(t, exists, "Document from the gzip-compressed child sitemap should be added")
+}
+
+func TestSeedSitemapMissing(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	site, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	urlStorage := newUrlStorage()
+	assert.Equal(t, 0, seedSitemap(context.Background(), fetch.NewClient(fetch.Options{Timeout: crawlHttpTimeout}), site, urlStorage, nil, tQuietLogger{}), "Missing sitemap should add nothing")
+}
+
+func TestParseSitemapLoc(t *testing.T) {
+	u, err := parseSitemapLoc("  https://example.com/page.html\n")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/page.html", u.String(), "Surrounding whitespace should be trimmed")
+
+	_, err = parseSitemapLoc("/relative/page.html")
+	assert.Error(t, err, "Relative locations should be rejected")
+
+	_, err = parseSitemapLoc("mailto:someone@example.com")
+	assert.Error(t, err, "Non-http locations should be rejected")
+}
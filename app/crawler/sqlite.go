@@ -0,0 +1,101 @@
+package crawler
+
+import (
+	"bytes"
+	"database/sql"
+	"docscrawler/app/researchers"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure Go SQLite driver, registered as "sqlite"
+)
+
+// Schema of the SQLite output, created unless the database already has it
+// Properties common to all document types have columns of their own, the metadata is kept whole as JSON
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS documents (
+	url        TEXT PRIMARY KEY,
+	type       TEXT NOT NULL,
+	title      TEXT,
+	author     TEXT,
+	created    TEXT,
+	modified   TEXT,
+	metadata   TEXT NOT NULL,
+	crawled_at TEXT NOT NULL
+)`
+
+// Upsert of a document, so a new run over the same site updates the rows of the documents found again
+const sqliteUpsert = `INSERT INTO documents (url, type, title, author, created, modified, metadata, crawled_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(url) DO UPDATE SET
+	type = excluded.type,
+	title = excluded.title,
+	author = excluded.author,
+	created = excluded.created,
+	modified = excluded.modified,
+	metadata = excluded.metadata,
+	crawled_at = excluded.crawled_at`
+
+// writeSqlite inserts the results into the documents table of the SQLite database file,
+// creating the file and the table if needed and replacing the rows of the same URLs
+// Rows of documents not found again are kept, the database accumulating the runs
+func (engine *Engine) writeSqlite(fileName string, results []Result) (err error) {
+	db, err := sql.Open("sqlite", fileName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := db.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to write %s: %w", fileName, err)
+		}
+	}()
+
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		return err
+	}
+
+	// All rows of the run are written in one transaction, or none
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	stmt, err := tx.Prepare(sqliteUpsert)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	crawledAt := engine.started.Format(time.RFC3339)
+	for _, result := range results {
+		var metadata bytes.Buffer
+		err = result.Metadata.OutJSON(&metadata)
+		if err != nil {
+			return err
+		}
+		var title, author, created, modified sql.NullString
+		if describer, ok := result.Metadata.(researchers.Describer); ok {
+			title = nullString(describer.DocTitle())
+			author = nullString(describer.DocAuthor())
+			created = nullString(describer.DocCreated())
+			modified = nullString(describer.DocModified())
+		}
+		_, err = stmt.Exec(result.Url, result.Type, title, author, created, modified, metadata.String(), crawledAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// nullString converts an empty property to NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
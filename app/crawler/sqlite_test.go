@@ -0,0 +1,103 @@
+package crawler
+
+import (
+	"database/sql"
+	"docscrawler/app/researchers"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineOutputSqlite(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "results.db")
+	newEngine := func(t *testing.T, started time.Time, results map[string]researchers.Researcher) *Engine {
+		engine, err := New(Config{Site: []string{"https://example.com"}, Type: []string{"pdf"}, Format: "sqlite", Output: dbFile, Paramax: 1})
+		require.NoError(t, err)
+		engine.started = started
+		for u, metadata := range results {
+			engine.docStorage[u] = Result{Url: u, Type: "pdf", Metadata: metadata}
+		}
+		return engine
+	}
+	type tRow struct {
+		Type, Metadata, CrawledAt string
+		Title, Author, Modified   sql.NullString
+	}
+	rows := func(t *testing.T) map[string]tRow {
+		db, err := sql.Open("sqlite", dbFile)
+		require.NoError(t, err)
+		defer db.Close()
+		query, err := db.Query("SELECT url, type, title, author, modified, metadata, crawled_at FROM documents")
+		require.NoError(t, err)
+		defer query.Close()
+		rows := make(map[string]tRow)
+		for query.Next() {
+			var u string
+			var row tRow
+			require.NoError(t, query.Scan(&u, &row.Type, &row.Title, &row.Author, &row.Modified, &row.Metadata, &row.CrawledAt))
+			rows[u] = row
+		}
+		require.NoError(t, query.Err())
+		return rows
+	}
+
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	engine := newEngine(t, first, map[string]researchers.Researcher{
+		"https://example.com/a.pdf": &tDescribedResearcher{title: "Report", modified: "2024-01-01T00:00:00Z"},
+		"https://example.com/b.pdf": &MockResearcher{},
+	})
+	require.NoError(t, engine.output())
+
+	got := rows(t)
+	require.Len(t, got, 2)
+	assert.Equal(t, tRow{
+		Type:      "pdf",
+		Metadata:  `{"test":"value"}`,
+		CrawledAt: "2024-05-01T12:00:00Z",
+		Title:     sql.NullString{String: "Report", Valid: true},
+		Modified:  sql.NullString{String: "2024-01-01T00:00:00Z", Valid: true},
+	}, got["https://example.com/a.pdf"], "Missing author should be NULL")
+	assert.False(t, got["https://example.com/b.pdf"].Title.Valid, "Documents without properties should have NULL columns")
+
+	// A second run updates the documents found again and keeps the others
+	second := first.Add(24 * time.Hour)
+	engine = newEngine(t, second, map[string]researchers.Researcher{
+		"https://example.com/a.pdf": &tDescribedResearcher{title: "Report, revised"},
+		"https://example.com/c.pdf": &MockResearcher{},
+	})
+	require.NoError(t, engine.output())
+
+	got = rows(t)
+	require.Len(t, got, 3)
+	assert.Equal(t, "Report, revised", got["https://example.com/a.pdf"].Title.String)
+	assert.False(t, got["https://example.com/a.pdf"].Modified.Valid)
+	assert.Equal(t, "2024-05-02T12:00:00Z", got["https://example.com/a.pdf"].CrawledAt)
+	assert.Equal(t, "2024-05-01T12:00:00Z", got["https://example.com/b.pdf"].CrawledAt)
+}
+
+func TestEngineSqliteConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"No output file", Config{}},
+		{"Stdout", Config{Output: Stdout}},
+		{"Split by type", Config{Output: "results.db", SplitByType: true}},
+		{"Wrapped", Config{Output: "results.db", Wrap: true}},
+		{"With summary", Config{Output: "results.db", WithSummary: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Site = []string{"https://example.com"}
+			cfg.Type = []string{"pdf"}
+			cfg.Format = "sqlite"
+			cfg.Paramax = 1
+			_, err := New(cfg)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"docscrawler/app/researchers"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Version of the state file format, increased on every incompatible change
+// State files of other versions are ignored
+const stateVersion = 1
+
+// Default interval between two saves of the state during a run
+const stateInterval = 30 * time.Second
+
+// tState is the progress of a run saved to the state file, from which an interrupted run resumes
+type tState struct {
+	Version   int              `json:"version"`
+	Urls      []tStateUrl      `json:"urls"`      // URLs discovered
+	Documents []tStateDocument `json:"documents"` // Documents analysed successfully
+}
+
+// tStateUrl is a discovered URL of the state
+type tStateUrl struct {
+	Url   string `json:"url"`
+	Depth int    `json:"depth"`
+	Used  bool   `json:"used,omitempty"` // Crawled, or skipped by the crawl
+}
+
+// tStateDocument is an analysed document of the state or the manifest, its metadata as written to the output
+type tStateDocument struct {
+	Url      string          `json:"url"`
+	Type     string          `json:"doc_type"`
+	Hash     string          `json:"hash,omitempty"`          // Content hash, for --dedup
+	Title    string          `json:"title,omitempty"`         // Title, for --sort-by
+	Author   string          `json:"author,omitempty"`        // Author, for the SQLite output
+	Created  string          `json:"created,omitempty"`       // Creation date, for the SQLite output
+	Modified string          `json:"modified,omitempty"`      // Last modification date, for --sort-by
+	ETag     string          `json:"etag,omitempty"`          // ETag header, for revalidation
+	LastMod  string          `json:"last_modified,omitempty"` // Last-Modified header, for revalidation
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// tRestored is the researcher of a document analysed in an earlier run, holding its metadata as saved
+type tRestored struct {
+	docType  string
+	hash     string
+	title    string
+	author   string
+	created  string
+	modified string
+	valid    researchers.Validators
+	metadata json.RawMessage
+}
+
+// OutJSON writes the metadata saved in the state file
+func (restored *tRestored) OutJSON(writer io.Writer) error {
+	_, err := writer.Write(restored.metadata)
+	return err
+}
+
+// Do fails, the document was analysed in an earlier run
+func (restored *tRestored) Do(ctx context.Context, url string) error {
+	return errors.New("document restored from the state file cannot be analysed again")
+}
+
+// Type returns the file type of the document
+func (restored *tRestored) Type() string {
+	return restored.docType
+}
+
+// Hash returns the content hash of the document, "" if unknown
+func (restored *tRestored) Hash() string {
+	return restored.hash
+}
+
+// DocTitle returns the title of the document
+func (restored *tRestored) DocTitle() string {
+	return restored.title
+}
+
+// DocAuthor returns the author of the document
+func (restored *tRestored) DocAuthor() string {
+	return restored.author
+}
+
+// DocCreated returns the creation date of the document
+func (restored *tRestored) DocCreated() string {
+	return restored.created
+}
+
+// DocModified returns the last modification date of the document
+func (restored *tRestored) DocModified() string {
+	return restored.modified
+}
+
+// Validators returns the validators of the document as served in the earlier run
+func (restored *tRestored) Validators() researchers.Validators {
+	return restored.valid
+}
+
+// newStateDocument records the document of the result with the properties its researcher exposes
+func newStateDocument(result Result) (tStateDocument, error) {
+	var buf bytes.Buffer
+	err := result.Metadata.OutJSON(&buf)
+	if err != nil {
+		return tStateDocument{}, err
+	}
+	doc := tStateDocument{Url: result.Url, Type: result.Type, Metadata: buf.Bytes()}
+	if hasher, ok := result.Metadata.(researchers.ContentHasher); ok {
+		doc.Hash = hasher.Hash()
+	}
+	if describer, ok := result.Metadata.(researchers.Describer); ok {
+		doc.Title, doc.Author = describer.DocTitle(), describer.DocAuthor()
+		doc.Created, doc.Modified = describer.DocCreated(), describer.DocModified()
+	}
+	if versioned, ok := result.Metadata.(researchers.Versioned); ok {
+		valid := versioned.Validators()
+		doc.ETag, doc.LastMod = valid.ETag, valid.LastModified
+	}
+	return doc, nil
+}
+
+// restore returns the researcher of the recorded document, holding its metadata as recorded
+func (doc tStateDocument) restore() *tRestored {
+	return &tRestored{
+		docType:  doc.Type,
+		hash:     doc.Hash,
+		title:    doc.Title,
+		author:   doc.Author,
+		created:  doc.Created,
+		modified: doc.Modified,
+		valid:    researchers.Validators{ETag: doc.ETag, LastModified: doc.LastMod},
+		metadata: doc.Metadata,
+	}
+}
+
+// addPrior keeps the document of an earlier run at the URL for revalidation, if it has validators:
+// its download becomes conditional, and its metadata is reused if the server reports it unchanged
+func (engine *Engine) addPrior(u string, restored *tRestored) {
+	if restored.valid == (researchers.Validators{}) {
+		return
+	}
+	if engine.downloader.Prior == nil {
+		engine.downloader.Prior = make(map[string]researchers.Validators)
+	}
+	engine.downloader.Prior[u] = restored.valid
+	engine.prior[u] = restored
+}
+
+// writeFileAtomic replaces the named file with the data at once,
+// so an interruption while writing leaves the previous file
+func writeFileAtomic(fileName string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fileName)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// loadState resumes from the state file: the discovered URLs are stored and the documents
+// analysed are kept, so the crawl goes on where it stopped and the documents are not downloaded again
+// Documents with validators are revalidated by a conditional request instead, their metadata kept
+// if the server reports them unchanged
+// A missing state file starts the run afresh, as does an unreadable one, which is logged
+func (engine *Engine) loadState() {
+	data, err := os.ReadFile(engine.stateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	var state tState
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	if err == nil && state.Version != stateVersion {
+		err = fmt.Errorf("unsupported version %d", state.Version)
+	}
+	if err != nil {
+		engine.logger.Warn("state file ignored", "file", engine.stateFile, "error", err)
+		return
+	}
+
+	for _, su := range state.Urls {
+		u, err := url.Parse(su.Url)
+		if err != nil {
+			continue
+		}
+		engine.urlStorage.restore(u, su.Depth, su.Used)
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	for _, doc := range state.Documents {
+		engine.restored[doc.Url] = doc.Type
+		restored := doc.restore()
+		engine.docStorage[doc.Url] = Result{Url: doc.Url, Type: doc.Type, Metadata: restored}
+		engine.addPrior(doc.Url, restored)
+		if doc.Hash != "" {
+			engine.hashes[doc.Hash] = doc.Url
+		}
+	}
+	engine.logger.Info("state restored", "file", engine.stateFile, "urls", len(state.Urls), "documents", len(state.Documents))
+}
+
+// saveState writes the progress of the run to the state file
+// The file is replaced at once, so an interruption while saving leaves the previous state
+func (engine *Engine) saveState() error {
+	state := tState{Version: stateVersion, Urls: engine.urlStorage.snapshot()}
+
+	engine.mutex.Lock()
+	for i, su := range state.Urls {
+		// A page whose harvest did not finish is crawled again
+		if engine.unfinished[su.Url] {
+			state.Urls[i].Used = false
+		}
+	}
+	for _, result := range engine.docStorage {
+		doc, err := newStateDocument(result)
+		if err != nil {
+			continue
+		}
+		state.Documents = append(state.Documents, doc)
+	}
+	engine.mutex.Unlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(engine.stateFile, data)
+}
+
+// startSavingState saves the state at every interval until the returned function is called
+func (engine *Engine) startSavingState(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := engine.saveState()
+				if err != nil {
+					engine.logger.Warn("state not saved", "file", engine.stateFile, "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// finishState saves the state if the run was interrupted, so the next run resumes from it,
+// and removes the state file of a completed run, so the next run starts afresh
+func (engine *Engine) finishState(interrupted bool) {
+	var err error
+	if interrupted {
+		err = engine.saveState()
+	} else {
+		err = os.Remove(engine.stateFile)
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	}
+	if err != nil {
+		engine.logger.Warn("state not saved", "file", engine.stateFile, "error", err)
+	}
+}
+
+// setUnfinished records whether the harvest of the page is yet to finish
+func (engine *Engine) setUnfinished(u *url.URL, unfinished bool) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	if unfinished {
+		engine.unfinished[u.String()] = true
+	} else {
+		delete(engine.unfinished, u.String())
+	}
+}
+
+// restore stores a URL of the state file with its depth and status
+// URLs already stored are left as they are
+func (us *tUrlStorage) restore(u *url.URL, depth int, used bool) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	key := us.keyOf(u)
+	if _, exists := us.urlStatus[key]; exists {
+		return
+	}
+	urlCopy := *u
+	us.urlObjects[key] = &urlCopy
+	us.urlStatus[key] = used
+	us.urlDepth[key] = depth
+	if !used {
+		us.queue = append(us.queue, key)
+	}
+}
+
+// snapshot returns the stored URLs with their depth and status
+func (us *tUrlStorage) snapshot() []tStateUrl {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	urls := make([]tStateUrl, 0, len(us.urlObjects))
+	for key, u := range us.urlObjects {
+		urls = append(urls, tStateUrl{Url: u.String(), Depth: us.urlDepth[key], Used: us.urlStatus[key]})
+	}
+	return urls
+}
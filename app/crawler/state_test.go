@@ -0,0 +1,211 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineStateFile(t *testing.T) {
+	odt := buildTestOdt(t, "Resumed")
+
+	// In the first run the slow document only answers once its request is aborted
+	var slow atomic.Bool
+	var fastRequests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/a.odt">A</a><a href="/b.odt">B</a>`))
+		case "/a.odt":
+			if r.Method == http.MethodGet {
+				fastRequests.Add(1)
+			}
+			w.Write(odt)
+		case "/b.odt":
+			if slow.Load() {
+				<-r.Context().Done()
+				return
+			}
+			w.Write(odt)
+		}
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, "state.json")
+	output := filepath.Join(dir, "output.json")
+	cfg := Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 2, Depth: 1, Output: output, StateFile: stateFile}
+
+	t.Run("Interrupted run saves the state", func(t *testing.T) {
+		slow.Store(true)
+		interrupted := cfg
+		interrupted.MaxDuration = 500 * time.Millisecond
+		engine, err := New(interrupted)
+		require.NoError(t, err)
+		engine.Run(context.Background())
+
+		data, err := os.ReadFile(stateFile)
+		require.NoError(t, err, "State file should be written")
+		var state tState
+		require.NoError(t, json.Unmarshal(data, &state))
+		assert.Equal(t, stateVersion, state.Version)
+		require.Len(t, state.Documents, 1, "Only the analysed document should be saved")
+		assert.Equal(t, ts.URL+"/a.odt", state.Documents[0].Url)
+		assert.Equal(t, "odt", state.Documents[0].Type)
+		assert.Contains(t, string(state.Documents[0].Metadata), "Resumed")
+		assert.Len(t, state.Urls, 2, "Discovered URLs should be saved")
+	})
+
+	t.Run("Next run resumes", func(t *testing.T) {
+		slow.Store(false)
+		fastRequests.Store(0)
+		engine, err := New(cfg)
+		require.NoError(t, err)
+		results, err := engine.Run(context.Background())
+		require.NoError(t, err)
+
+		assert.Len(t, results, 2)
+		assert.Zero(t, fastRequests.Load(), "Restored document should not be downloaded again")
+		data, err := os.ReadFile(output)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), ts.URL+"/a.odt", "Restored document should be written to the output")
+		assert.Contains(t, string(data), ts.URL+"/b.odt")
+
+		_, err = os.Stat(stateFile)
+		assert.ErrorIs(t, err, os.ErrNotExist, "State file of a completed run should be removed")
+	})
+}
+
+func TestEngineStateFileRevalidation(t *testing.T) {
+	odt := buildTestOdt(t, "Revised")
+	etags := map[string]string{"/a.odt": `"v1"`, "/b.odt": `"v2"`, "/c.odt": `"v1"`}
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/a.odt">A</a><a href="/b.odt">B</a><a href="/c.odt">C</a>`))
+			return
+		}
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("ETag", etags[r.URL.Path])
+		if r.Header.Get("If-None-Match") == etags[r.URL.Path] {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write(odt)
+	}))
+	defer ts.Close()
+
+	// a.odt is unchanged, b.odt changed and c.odt has no validators to revalidate it with
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	state := `{"version": 1, "urls": [], "documents": [
+		{"url": "` + ts.URL + `/a.odt", "doc_type": "odt", "etag": "\"v1\"", "metadata": {"title": "Earlier"}},
+		{"url": "` + ts.URL + `/b.odt", "doc_type": "odt", "etag": "\"v1\"", "metadata": {"title": "Earlier"}},
+		{"url": "` + ts.URL + `/c.odt", "doc_type": "odt", "metadata": {"title": "Earlier"}}
+	]}`
+	require.NoError(t, os.WriteFile(stateFile, []byte(state), 0o644))
+
+	engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 2, Depth: 1, StateFile: stateFile, Dedup: true})
+	require.NoError(t, err)
+	results, err := engine.Run(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	metadata := make(map[string]string)
+	for _, result := range results {
+		var buf bytes.Buffer
+		require.NoError(t, result.Metadata.OutJSON(&buf))
+		metadata[result.Url] = buf.String()
+	}
+	assert.Contains(t, metadata[ts.URL+"/a.odt"], "Earlier", "Metadata of an unchanged document should be kept")
+	assert.Contains(t, metadata[ts.URL+"/b.odt"], "Revised", "Changed document should be analysed again")
+	assert.Contains(t, metadata[ts.URL+"/c.odt"], "Earlier")
+	assert.Equal(t, map[string]int{"/a.odt": 1, "/b.odt": 1}, requests, "Only documents with validators should be requested again")
+	assert.EqualValues(t, 3, engine.Summary().Analysed)
+}
+
+func TestEngineStateFileIgnored(t *testing.T) {
+	odt := buildTestOdt(t, "Fresh")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/a.odt">A</a>`))
+			return
+		}
+		w.Write(odt)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"Corrupt file", `{"version": 1, "urls": [`},
+		{"Other version", `{"version": 99, "urls": [], "documents": [{"url": "` + ts.URL + `/a.odt", "doc_type": "odt", "metadata": {}}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stateFile := filepath.Join(t.TempDir(), "state.json")
+			require.NoError(t, os.WriteFile(stateFile, []byte(tt.content), 0o644))
+
+			engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 2, Depth: 1, StateFile: stateFile})
+			require.NoError(t, err)
+			results, err := engine.Run(context.Background())
+			require.NoError(t, err)
+			require.Len(t, results, 1, "Run should start afresh")
+			_, isRestored := results[0].Metadata.(*tRestored)
+			assert.False(t, isRestored, "Document should be analysed again")
+		})
+	}
+}
+
+func TestEngineStateFileLowMemory(t *testing.T) {
+	_, err := New(Config{Site: []string{"https://example.com"}, Type: []string{"pdf"}, Paramax: 1, StateFile: "state.json", LowMemory: true})
+	assert.Error(t, err)
+}
+
+func TestSaveStateUnfinished(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	engine, err := New(Config{Site: []string{"https://example.com"}, Type: []string{"pdf"}, Paramax: 1, StateFile: stateFile})
+	require.NoError(t, err)
+
+	for _, raw := range []string{"https://example.com/done", "https://example.com/unfinished"} {
+		u, _ := url.Parse(raw)
+		engine.urlStorage.add(u)
+	}
+	for {
+		u, ok := engine.urlStorage.use()
+		if !ok {
+			break
+		}
+		engine.setUnfinished(u, true)
+		if u.Path == "/done" {
+			engine.setUnfinished(u, false)
+		}
+	}
+	require.NoError(t, engine.saveState())
+
+	data, err := os.ReadFile(stateFile)
+	require.NoError(t, err)
+	var state tState
+	require.NoError(t, json.Unmarshal(data, &state))
+	used := make(map[string]bool)
+	for _, su := range state.Urls {
+		used[su.Url] = su.Used
+	}
+	assert.True(t, used["https://example.com/done"])
+	assert.False(t, used["https://example.com/unfinished"], "Page whose harvest did not finish should be crawled again")
+}
@@ -0,0 +1,112 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// Documents serialized by the analyser workers and waiting for the writer
+const streamQueueSize = 100
+
+// tStream writes analysed documents while the analysis is still running, as NDJSON or
+// as the elements of a JSON array, in the order they are analysed
+// The analyser workers serialize the documents and queue them, a single writer goroutine
+// writes them with the separators of the format and flushes each one at once, so the
+// output can be consumed incrementally and no document is kept in memory
+type tStream struct {
+	out    *bufio.Writer // Buffered output destination
+	ndjson bool          // One document per line instead of a JSON array
+	pretty bool          // Indent the documents of the JSON array
+	queue  chan []byte   // Serialized documents waiting to be written
+	done   chan struct{} // Closed when the writer has finished
+	count  int           // Documents written, only accessed by the writer
+	err    error         // First write error, the documents after it are dropped
+}
+
+// newStream creates a stream writing to the given writer in the given format until close is called
+// The opening bracket of a JSON array is written at once
+func newStream(writer io.Writer, ndjson bool, pretty bool) *tStream {
+	stream := &tStream{
+		out:    bufio.NewWriter(writer),
+		ndjson: ndjson,
+		pretty: pretty && !ndjson, // NDJSON requires one object per line, so it is never indented
+		queue:  make(chan []byte, streamQueueSize),
+		done:   make(chan struct{}),
+	}
+	if !ndjson {
+		stream.out.WriteString("[")
+	}
+	go func() {
+		defer close(stream.done)
+		for data := range stream.queue {
+			if stream.err == nil {
+				stream.err = stream.writeDocument(data)
+			}
+		}
+	}()
+	return stream
+}
+
+// send serializes the document and queues it for writing, waiting while the queue is full
+// Returns the serialization error of the document; write errors are reported by close
+func (stream *tStream) send(rr tJsonOutputter) error {
+	var buf bytes.Buffer
+	err := rr.OutJSON(&buf)
+	if err != nil {
+		return err
+	}
+	if stream.pretty {
+		var indented bytes.Buffer
+		err = json.Indent(&indented, buf.Bytes(), "  ", "  ")
+		if err != nil {
+			return err
+		}
+		buf = indented
+	}
+	stream.queue <- buf.Bytes()
+	return nil
+}
+
+// writeDocument writes a serialized document with its separator and flushes it
+func (stream *tStream) writeDocument(data []byte) error {
+	switch {
+	case stream.ndjson:
+	case stream.count > 0 && stream.pretty:
+		stream.out.WriteString(",\n  ")
+	case stream.pretty:
+		stream.out.WriteString("\n  ")
+	case stream.count > 0:
+		stream.out.WriteString(",")
+	}
+	stream.out.Write(data)
+	if stream.ndjson {
+		stream.out.WriteString("\n")
+	}
+	stream.count++
+	// Write errors are kept by the buffered writer and reported by Flush
+	return stream.out.Flush()
+}
+
+// close waits until the queued documents are written, then closes the JSON array
+// Returns the first write error
+func (stream *tStream) close() error {
+	close(stream.queue)
+	<-stream.done
+	if stream.err != nil {
+		return stream.err
+	}
+
+	// Close JSON array, an empty indented array stays on one line
+	switch {
+	case stream.ndjson:
+	case stream.pretty && stream.count > 0:
+		stream.out.WriteString("\n]\n")
+	case stream.pretty:
+		stream.out.WriteString("]\n")
+	default:
+		stream.out.WriteString("]")
+	}
+	return stream.out.Flush()
+}
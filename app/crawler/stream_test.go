@@ -0,0 +1,129 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkyResearcher writes its JSON in many small pieces to provoke interleaving
+type chunkyResearcher struct {
+	id string
+}
+
+func (r *chunkyResearcher) OutJSON(writer io.Writer) error {
+	for _, piece := range []string{`{"id":"`, r.id, `","padding":"`, strings.Repeat("x", 5000), `"}`} {
+		if _, err := writer.Write([]byte(piece)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *chunkyResearcher) Do(ctx context.Context, url string) error {
+	return nil
+}
+
+func TestStream(t *testing.T) {
+	const writers = 50
+
+	for _, ndjson := range []bool{true, false} {
+		t.Run(map[bool]string{true: "ndjson", false: "json"}[ndjson], func(t *testing.T) {
+			var buf bytes.Buffer
+			stream := newStream(&buf, ndjson, false)
+
+			var wg sync.WaitGroup
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					assert.NoError(t, stream.send(&chunkyResearcher{id: strings.Repeat(string(rune('a'+i%26)), 3)}))
+				}(i)
+			}
+			wg.Wait()
+			require.NoError(t, stream.close())
+
+			var docs []map[string]string
+			if ndjson {
+				lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+				require.Len(t, lines, writers, "Every document should be on its own line")
+				for _, line := range lines {
+					var doc map[string]string
+					require.NoError(t, json.Unmarshal([]byte(line), &doc), "Lines should not interleave")
+					docs = append(docs, doc)
+				}
+			} else {
+				require.NoError(t, json.Unmarshal(buf.Bytes(), &docs), "Output should be a single JSON array")
+				require.Len(t, docs, writers)
+			}
+			for _, doc := range docs {
+				assert.Len(t, doc["id"], 3)
+				assert.Equal(t, strings.Repeat("x", 5000), doc["padding"])
+			}
+		})
+	}
+}
+
+func TestStreamFlushesEachDocument(t *testing.T) {
+	// Each document should be readable before the next one is sent
+	r, w := io.Pipe()
+	defer r.Close()
+	stream := newStream(w, true, false)
+	reader := bufio.NewReader(r)
+
+	for range 2 {
+		require.NoError(t, stream.send(&MockResearcher{}))
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "{\"test\":\"value\"}\n", line, "Line should be visible without waiting for the end")
+	}
+	require.NoError(t, stream.close())
+}
+
+func TestStreamJsonArray(t *testing.T) {
+	tests := []struct {
+		name     string
+		docs     int
+		pretty   bool
+		expected string
+	}{
+		{"Empty", 0, false, "[]"},
+		{"Compact", 2, false, `[{"test":"value"},{"test":"value"}]`},
+		{"Empty pretty", 0, true, "[]\n"},
+		{"Pretty", 2, true, "[\n  {\n    \"test\": \"value\"\n  },\n  {\n    \"test\": \"value\"\n  }\n]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			stream := newStream(&buf, false, tt.pretty)
+			for range tt.docs {
+				require.NoError(t, stream.send(&MockResearcher{}))
+			}
+			require.NoError(t, stream.close())
+			assert.Equal(t, tt.expected, buf.String(), "Stream should write what the buffered output does")
+		})
+	}
+}
+
+// tFailingWriter fails every write
+type tFailingWriter struct{}
+
+func (tFailingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestStreamWriteError(t *testing.T) {
+	stream := newStream(tFailingWriter{}, false, false)
+	require.NoError(t, stream.send(&MockResearcher{}), "Write errors should not fail the document")
+	require.NoError(t, stream.send(&MockResearcher{}))
+	assert.ErrorContains(t, stream.close(), "disk full")
+}
@@ -0,0 +1,110 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Summary is the account of a run, accumulated by the engine while crawling and analysing
+type Summary struct {
+	URLs            int              `json:"urls_discovered"`    // URLs discovered, documents included
+	PagesCrawled    int64            `json:"pages_crawled"`      // HTML pages fetched while crawling, as counted by MaxPages
+	DocumentsFound  map[string]int64 `json:"documents_found"`    // Documents of the requested types found, by type
+	Analysed        int64            `json:"documents_analysed"` // Documents analysed successfully
+	Failed          int64            `json:"documents_failed"`   // Documents that failed to be analysed
+	BytesDownloaded int64            `json:"bytes_downloaded"`   // Bytes of the pages and documents downloaded
+	Elapsed         float64          `json:"elapsed_seconds"`    // Duration of the crawl and analysis in seconds
+}
+
+// tSummaryRecord is the summary written as the last element of the output, under a "_summary" key
+type tSummaryRecord struct {
+	Summary Summary `json:"_summary"`
+}
+
+// OutJSON serializes the summary record to JSON and writes it to the provided writer
+func (record tSummaryRecord) OutJSON(writer io.Writer) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+// outputSummary writes the summary as a JSON object to the summary file or stdout,
+// whatever the output format; indented with --pretty
+func (engine *Engine) outputSummary(summary Summary) error {
+	var data []byte
+	var err error
+	if engine.pretty {
+		data, err = json.MarshalIndent(summary, "", "  ")
+	} else {
+		data, err = json.Marshal(summary)
+	}
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	out, err := openOutput(engine.summaryFile)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	if out != os.Stdout {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", out.Name(), err)
+	}
+	return nil
+}
+
+// newDocsByType creates a found-documents counter for each of the requested types
+// The map is not modified afterwards, so the workers update the counters without locking
+func newDocsByType(docTypes []string) map[string]*atomic.Int64 {
+	docsByType := make(map[string]*atomic.Int64, len(docTypes))
+	for _, st := range docTypes {
+		docsByType[st] = new(atomic.Int64)
+	}
+	return docsByType
+}
+
+// countFound counts a document of the given type found by the analyser
+func (stats *tStats) countFound(st string) {
+	stats.docsFound.Add(1)
+	if n, ok := stats.docsByType[st]; ok {
+		n.Add(1)
+	}
+}
+
+// summarize takes a snapshot of the counters of the run
+func (engine *Engine) summarize(elapsed time.Duration) Summary {
+	discovered, _ := engine.urlStorage.count()
+	found := make(map[string]int64, len(engine.stats.docsByType))
+	for st, n := range engine.stats.docsByType {
+		found[st] = n.Load()
+	}
+	return Summary{
+		URLs:            discovered,
+		PagesCrawled:    engine.stats.pagesCrawled.Load(),
+		DocumentsFound:  found,
+		Analysed:        engine.stats.docsAnalysed.Load(),
+		Failed:          engine.stats.docsFailed.Load(),
+		BytesDownloaded: engine.crawlClient.BytesRead() + engine.downloader.Client.BytesRead(),
+		Elapsed:         elapsed.Seconds(),
+	}
+}
+
+// Summary returns the account of the last run, the zero Summary before the first one
+func (engine *Engine) Summary() Summary {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	return engine.summary
+}
@@ -0,0 +1,114 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineSummary(t *testing.T) {
+	odt := buildTestOdt(t, "Library")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/a.odt">A</a><a href="/b.odt">B</a><a href="/missing.pdf">C</a><a href="/page">Page</a>`))
+		case "/a.odt", "/b.odt":
+			w.Write(odt)
+		case "/page":
+			w.Write([]byte(`<p>No links</p>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	checkSummary := func(t *testing.T, summary Summary) {
+		assert.Equal(t, 4, summary.URLs)
+		assert.Equal(t, int64(2), summary.PagesCrawled, "Only HTML pages should be counted, not the documents fetched while crawling")
+		assert.Equal(t, map[string]int64{"odt": 2, "pdf": 1}, summary.DocumentsFound)
+		assert.Equal(t, int64(2), summary.Analysed)
+		assert.Equal(t, int64(1), summary.Failed)
+		assert.Greater(t, summary.BytesDownloaded, int64(2*len(odt)), "Pages and documents should be counted")
+		assert.Positive(t, summary.Elapsed)
+	}
+
+	t.Run("Summary of the run", func(t *testing.T) {
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt", "pdf"}, Paramax: 2})
+		require.NoError(t, err)
+		assert.Zero(t, engine.Summary().URLs, "No summary before the run")
+
+		_, err = engine.Run(context.Background())
+		require.ErrorAs(t, err, new(*DocumentsError))
+		checkSummary(t, engine.Summary())
+	})
+
+	t.Run("Summary in the JSON output", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "output.json")
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt", "pdf"}, Paramax: 2, Output: outputFile, WithSummary: true})
+		require.NoError(t, err)
+		_, _ = engine.Run(context.Background())
+
+		data, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		var elements []map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(data, &elements))
+		require.Len(t, elements, 3, "Summary should follow the documents")
+
+		var summary Summary
+		require.NoError(t, json.Unmarshal(elements[2]["_summary"], &summary))
+		checkSummary(t, summary)
+	})
+
+	t.Run("Summary in the NDJSON output", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "output.ndjson")
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt", "pdf"}, Paramax: 2, Output: outputFile, Format: formatNdjson, WithSummary: true})
+		require.NoError(t, err)
+		_, _ = engine.Run(context.Background())
+
+		data, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		require.Len(t, lines, 3)
+		assert.True(t, strings.HasPrefix(lines[2], `{"_summary":{"urls_discovered":4,`), "Summary should be the last line")
+	})
+
+	t.Run("Summary file", func(t *testing.T) {
+		dir := t.TempDir()
+		outputFile := filepath.Join(dir, "output.json")
+		summaryFile := filepath.Join(dir, "summary.json")
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt", "pdf"}, Paramax: 2, Output: outputFile, SummaryFile: summaryFile, Pretty: true})
+		require.NoError(t, err)
+		_, _ = engine.Run(context.Background())
+
+		data, err := os.ReadFile(summaryFile)
+		require.NoError(t, err)
+		var summary Summary
+		require.NoError(t, json.Unmarshal(data, &summary), "Summary file should hold a single JSON object")
+		checkSummary(t, summary)
+		assert.True(t, strings.HasPrefix(string(data), "{\n  \"urls_discovered\": 4,"), "Summary should be indented with --pretty")
+
+		data, err = os.ReadFile(outputFile)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "_summary", "Output should stay a bare array of documents")
+	})
+
+	t.Run("No summary by default", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "output.json")
+		engine, err := New(Config{Site: []string{ts.URL}, Type: []string{"odt"}, Paramax: 2, Output: outputFile})
+		require.NoError(t, err)
+		_, _ = engine.Run(context.Background())
+
+		data, err := os.ReadFile(outputFile)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "_summary")
+	})
+}
@@ -0,0 +1,38 @@
+package crawler
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// readUrlFile reads the URLs listed in a file, one per line
+// Blank lines and lines starting with # are ignored, surrounding spaces are trimmed
+// Returns an error naming the line of the first URL that is not absolute
+func readUrlFile(fileName string) ([]*url.URL, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var urls []*url.URL
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		u, err := url.ParseRequestURI(text)
+		if err != nil || !u.IsAbs() {
+			return nil, fmt.Errorf("%s:%d: invalid URL %q", fileName, line, text)
+		}
+		urls = append(urls, u)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", fileName, err)
+	}
+	return urls, nil
+}
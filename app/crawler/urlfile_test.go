@@ -0,0 +1,45 @@
+package crawler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadUrlFile(t *testing.T) {
+	write := func(t *testing.T, content string) string {
+		fileName := filepath.Join(t.TempDir(), "urls.txt")
+		require.NoError(t, os.WriteFile(fileName, []byte(content), 0o644))
+		return fileName
+	}
+
+	t.Run("Comments and blank lines", func(t *testing.T) {
+		fileName := write(t, "# Annual reports\nhttps://example.com/2023.pdf\n\n  https://example.com/2024.pdf  \r\n#https://example.com/draft.pdf\n")
+
+		urls, err := readUrlFile(fileName)
+		require.NoError(t, err)
+		require.Len(t, urls, 2)
+		assert.Equal(t, "https://example.com/2023.pdf", urls[0].String())
+		assert.Equal(t, "https://example.com/2024.pdf", urls[1].String(), "Spaces and CR should be trimmed")
+	})
+
+	t.Run("Empty file", func(t *testing.T) {
+		urls, err := readUrlFile(write(t, ""))
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+	})
+
+	t.Run("Invalid URL", func(t *testing.T) {
+		fileName := write(t, "https://example.com/a.pdf\n/relative/b.pdf\n")
+		_, err := readUrlFile(fileName)
+		assert.EqualError(t, err, fileName+`:2: invalid URL "/relative/b.pdf"`, "Error should name the line")
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := readUrlFile(filepath.Join(t.TempDir(), "missing.txt"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -0,0 +1,280 @@
+package crawler
+
+import (
+	"hash/fnv"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Default ports dropped from URL keys by normalizeUrl
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// normalizeUrl returns the key identifying a URL in the storage, so that spellings of the same
+// page are stored once: the fragment is dropped, scheme and host are lowercased, the default
+// port is removed, "." and ".." path segments are resolved and trailing slashes are dropped
+// An empty query and utm_ tracking parameters are dropped and the parameters sorted by name
+func normalizeUrl(u *url.URL) string {
+	n := *u
+	n.Fragment = ""
+	n.RawFragment = ""
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	if port := n.Port(); port != "" && defaultPorts[n.Scheme] == port {
+		n.Host = strings.TrimSuffix(n.Host, ":"+port)
+	}
+
+	switch {
+	case n.Host != "":
+		n.Path = path.Clean("/" + n.Path)
+	case n.Path != "":
+		n.Path = path.Clean(n.Path)
+	}
+	n.RawPath = ""
+
+	n.ForceQuery = false
+	if n.RawQuery != "" {
+		if query, err := url.ParseQuery(n.RawQuery); err == nil {
+			for name := range query {
+				if strings.HasPrefix(strings.ToLower(name), "utm_") {
+					delete(query, name)
+				}
+			}
+			n.RawQuery = query.Encode() // Sorted by name
+		}
+	}
+
+	return n.String()
+}
+
+// tUrlStorage manages URL collection, status tracking, and processing queue
+// with thread-safe operations using RWMutex for concurrent access control
+// URLs are keyed by their normalized form, the URL as first seen is kept for crawling and output
+// In low-memory mode only a hash of the key is kept for each URL, the URL itself being dropped
+// once used unless keep accepts it; getAllUrls then returns the kept URLs alone
+type tUrlStorage struct {
+	mu         sync.RWMutex          // RWMutex for concurrent access control
+	keyOf      func(*url.URL) string // Key of a URL in the maps, normalizeUrl unless set otherwise
+	urlStatus  map[string]bool       // URL status map (true = used/processed)
+	urlObjects map[string]*url.URL   // Map of string keys to URL objects
+	urlDepth   map[string]int        // Link depth of each URL relative to the seed
+	queue      []string              // Queue of URLs to be processed
+
+	lowMemory bool                 // Whether the compact structures below are used instead of the maps
+	keep      func(*url.URL) bool  // URLs kept for getAllUrls in low-memory mode
+	visited   map[uint64]tUrlVisit // Status and depth of each URL by the hash of its key
+	pending   []*url.URL           // URLs to be processed
+	kept      []*url.URL           // URLs accepted by keep
+}
+
+// tUrlVisit is the status and link depth of a URL in low-memory mode
+type tUrlVisit struct {
+	depth int32
+	used  bool
+}
+
+// newUrlStorage creates and initializes a new URL storage instance
+func newUrlStorage() *tUrlStorage {
+	return &tUrlStorage{
+		keyOf:      normalizeUrl,
+		urlStatus:  make(map[string]bool),
+		urlObjects: make(map[string]*url.URL),
+		urlDepth:   make(map[string]int),
+		queue:      make([]string, 0, 100),
+	}
+}
+
+// newLowMemoryUrlStorage creates a URL storage in low-memory mode, keeping the URLs accepted by keep
+// Keys are hashed to 64 bits, so two URLs may collide, with a negligible chance even for millions of URLs
+func newLowMemoryUrlStorage(keep func(*url.URL) bool) *tUrlStorage {
+	return &tUrlStorage{
+		keyOf:     normalizeUrl,
+		lowMemory: true,
+		keep:      keep,
+		visited:   make(map[uint64]tUrlVisit),
+		pending:   make([]*url.URL, 0, 100),
+	}
+}
+
+// hashOf returns the hash of the key of a URL, which identifies it in low-memory mode
+func (us *tUrlStorage) hashOf(u *url.URL) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(us.keyOf(u)))
+	return h.Sum64()
+}
+
+// Add adds a new URL to the storage at depth 0 if it doesn't already exist
+// Returns true if URL was added, false if it already existed or is nil
+func (us *tUrlStorage) add(u *url.URL) bool {
+	return us.addDepth(u, 0)
+}
+
+// AddDepth adds a new URL found at the given link depth from the seed
+// Returns true if URL was added, false if it already existed or is nil
+func (us *tUrlStorage) addDepth(u *url.URL, depth int) bool {
+	if u == nil {
+		return false
+	}
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	if us.lowMemory {
+		hash := us.hashOf(u)
+		if _, exists := us.visited[hash]; exists {
+			return false
+		}
+		urlCopy := *u
+		us.visited[hash] = tUrlVisit{depth: int32(depth)}
+		us.pending = append(us.pending, &urlCopy)
+		if us.keep(&urlCopy) {
+			us.kept = append(us.kept, &urlCopy)
+		}
+		return true
+	}
+
+	key := us.keyOf(u)
+
+	// Check if URL already exists
+	if _, exists := us.urlStatus[key]; exists {
+		return false
+	}
+
+	// Store a copy of the URL
+	urlCopy := *u // Create a copy of the URL structure
+	us.urlObjects[key] = &urlCopy
+	us.urlStatus[key] = false // false = unused
+	us.urlDepth[key] = depth
+	us.queue = append(us.queue, key)
+
+	return true
+}
+
+// Use returns an unused URL and marks it as used
+// Returns the URL and true if successful, nil and false if no unused URLs exist
+func (us *tUrlStorage) use() (*url.URL, bool) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	// Pending URLs are all unused, the storage forgets them once handed out
+	if us.lowMemory {
+		if len(us.pending) == 0 {
+			return nil, false
+		}
+		u := us.pending[0]
+		us.pending[0] = us.pending[len(us.pending)-1]
+		us.pending[len(us.pending)-1] = nil
+		us.pending = us.pending[:len(us.pending)-1]
+		hash := us.hashOf(u)
+		visit := us.visited[hash]
+		visit.used = true
+		us.visited[hash] = visit
+		return u, true
+	}
+
+	// Find an unused URL in the queue
+	for i := 0; i < len(us.queue); i++ {
+		key := us.queue[i]
+
+		if !us.urlStatus[key] {
+			// Mark as used
+			us.urlStatus[key] = true
+
+			// Remove from queue (fast removal without preserving order)
+			us.queue[i] = us.queue[len(us.queue)-1]
+			us.queue = us.queue[:len(us.queue)-1]
+
+			return us.urlObjects[key], true
+		}
+	}
+
+	return nil, false
+}
+
+// GetAllURLs returns all URLs stored in the storage, only those accepted by keep in low-memory mode
+func (us *tUrlStorage) getAllUrls() []*url.URL {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+
+	if us.lowMemory {
+		return slices.Clone(us.kept)
+	}
+
+	result := make([]*url.URL, 0, len(us.urlObjects))
+
+	for _, urlObj := range us.urlObjects {
+		// Important: return the stored pointers, not creating new ones
+		result = append(result, urlObj)
+	}
+
+	return result
+}
+
+// Check verifies if a URL exists in storage and whether it's already used
+// Returns (exists, used) as booleans
+func (us *tUrlStorage) check(u *url.URL) (exists bool, used bool) {
+	if u == nil {
+		return false, false
+	}
+
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+
+	if us.lowMemory {
+		visit, exists := us.visited[us.hashOf(u)]
+		return exists, visit.used
+	}
+
+	key := us.keyOf(u)
+	used, exists = us.urlStatus[key]
+	return exists, used
+}
+
+// Depth returns the link depth of a stored URL relative to the seed
+// Returns (depth, true) if the URL exists, (0, false) otherwise
+func (us *tUrlStorage) depth(u *url.URL) (int, bool) {
+	if u == nil {
+		return 0, false
+	}
+
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+
+	if us.lowMemory {
+		visit, exists := us.visited[us.hashOf(u)]
+		return int(visit.depth), exists
+	}
+
+	d, exists := us.urlDepth[us.keyOf(u)]
+	return d, exists
+}
+
+// Count returns the total number of URLs in storage and how many are used
+func (us *tUrlStorage) count() (total int, used int) {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+
+	if us.lowMemory {
+		for _, visit := range us.visited {
+			if visit.used {
+				used++
+			}
+		}
+		return len(us.visited), used
+	}
+
+	total = len(us.urlStatus)
+
+	for _, isUsed := range us.urlStatus {
+		if isUsed {
+			used++
+		}
+	}
+
+	return total, used
+}
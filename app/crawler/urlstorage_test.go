@@ -1,4 +1,4 @@
-package main
+package crawler
 
 import (
 	"fmt"
@@ -129,6 +129,187 @@ func TestUrlStorage(t *testing.T) {
 	})
 }
 
+func TestNormalizeUrl(t *testing.T) {
+	testCases := []struct {
+		name       string
+		urls       []string
+		normalized string
+	}{
+		{
+			name:       "Fragments",
+			urls:       []string{"https://example.com/a", "https://example.com/a#frag", "https://example.com/a#"},
+			normalized: "https://example.com/a",
+		},
+		{
+			name:       "Trailing slash",
+			urls:       []string{"https://example.com/docs/", "https://example.com/docs", "https://example.com/docs//"},
+			normalized: "https://example.com/docs",
+		},
+		{
+			name:       "Host case and default port",
+			urls:       []string{"HTTPS://Example.COM/a", "https://example.com:443/a", "https://EXAMPLE.com:443/a#x"},
+			normalized: "https://example.com/a",
+		},
+		{
+			name:       "Dot segments",
+			urls:       []string{"https://example.com/a/./b/../c", "https://example.com/a/c", "https://example.com/x/../a/c/"},
+			normalized: "https://example.com/a/c",
+		},
+		{
+			name:       "Root",
+			urls:       []string{"https://example.com", "https://example.com/", "https://example.com/."},
+			normalized: "https://example.com/",
+		},
+		{
+			name:       "Query is kept",
+			urls:       []string{"https://example.com/a?b=1#top", "https://example.com/a/?b=1"},
+			normalized: "https://example.com/a?b=1",
+		},
+		{
+			name:       "Empty query",
+			urls:       []string{"https://example.com/a?", "https://example.com/a", "https://example.com/a/?#x"},
+			normalized: "https://example.com/a",
+		},
+		{
+			name:       "Parameter order",
+			urls:       []string{"https://example.com/a?b=1&c=2", "https://example.com/a?c=2&b=1"},
+			normalized: "https://example.com/a?b=1&c=2",
+		},
+		{
+			name:       "Tracking parameters",
+			urls:       []string{"https://example.com/a?b=1&utm_source=news", "https://example.com/a?UTM_Campaign=x&b=1&utm_medium=email"},
+			normalized: "https://example.com/a?b=1",
+		},
+		{
+			name:       "Non-default port is kept",
+			urls:       []string{"http://example.com:8080/a/"},
+			normalized: "http://example.com:8080/a",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, raw := range tc.urls {
+				u, err := url.Parse(raw)
+				require.NoError(t, err)
+				assert.Equal(t, tc.normalized, normalizeUrl(u), "Normalized form of %s", raw)
+			}
+		})
+	}
+
+	t.Run("Path case and distinct queries differ", func(t *testing.T) {
+		a, _ := url.Parse("https://example.com/A")
+		b, _ := url.Parse("https://example.com/a")
+		c, _ := url.Parse("https://example.com/a?b=2")
+		assert.NotEqual(t, normalizeUrl(a), normalizeUrl(b))
+		assert.NotEqual(t, normalizeUrl(b), normalizeUrl(c))
+	})
+}
+
+func TestUrlStorageNormalization(t *testing.T) {
+	storage := newUrlStorage()
+
+	first, _ := url.Parse("https://example.com/a#intro")
+	assert.True(t, storage.add(first), "First spelling should be added")
+
+	for _, raw := range []string{"https://example.com/a", "https://EXAMPLE.com/a/", "https://example.com/b/../a"} {
+		u, _ := url.Parse(raw)
+		assert.False(t, storage.add(u), "%s should be recognized as already stored", raw)
+		exists, _ := storage.check(u)
+		assert.True(t, exists)
+	}
+
+	total, _ := storage.count()
+	assert.Equal(t, 1, total, "Equivalent URLs should be stored once")
+
+	u, ok := storage.use()
+	require.True(t, ok)
+	assert.Equal(t, "https://example.com/a#intro", u.String(), "URL should be kept as first seen")
+}
+
+func TestUrlStorageExactKeys(t *testing.T) {
+	storage := newUrlStorage()
+	storage.keyOf = (*url.URL).String
+
+	for _, raw := range []string{"https://example.com/a", "https://example.com/a#intro", "https://example.com/a?"} {
+		u, _ := url.Parse(raw)
+		assert.True(t, storage.add(u), "%s should be stored on its own", raw)
+	}
+	u, _ := url.Parse("https://example.com/a#intro")
+	assert.False(t, storage.add(u), "Same spelling should be recognized as already stored")
+
+	total, _ := storage.count()
+	assert.Equal(t, 3, total)
+}
+
+func TestUrlStorageDepth(t *testing.T) {
+	storage := newUrlStorage()
+
+	seed, _ := url.Parse("https://example.com/")
+	child, _ := url.Parse("https://example.com/child")
+	unknown, _ := url.Parse("https://example.com/unknown")
+
+	assert.True(t, storage.add(seed), "Seed should be added")
+	assert.True(t, storage.addDepth(child, 2), "Child should be added")
+	assert.False(t, storage.addDepth(child, 1), "Existing URL should not be re-added with another depth")
+
+	d, exists := storage.depth(seed)
+	assert.True(t, exists)
+	assert.Equal(t, 0, d, "URLs added without depth should be at depth 0")
+
+	d, exists = storage.depth(child)
+	assert.True(t, exists)
+	assert.Equal(t, 2, d, "First recorded depth should be kept")
+
+	_, exists = storage.depth(unknown)
+	assert.False(t, exists, "Unknown URL should have no depth")
+
+	_, exists = storage.depth(nil)
+	assert.False(t, exists, "Nil URL should have no depth")
+}
+
+func TestLowMemoryUrlStorage(t *testing.T) {
+	isPdf := func(u *url.URL) bool { return extensionOf(u) == "pdf" }
+	storage := newLowMemoryUrlStorage(isPdf)
+	parse := func(raw string) *url.URL {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		return u
+	}
+
+	assert.True(t, storage.addDepth(parse("https://example.com/page"), 1))
+	assert.True(t, storage.addDepth(parse("https://example.com/doc.pdf"), 2))
+	assert.False(t, storage.add(parse("https://EXAMPLE.com/page#top")), "Keys should still be normalized")
+	assert.False(t, storage.add(nil))
+
+	exists, used := storage.check(parse("https://example.com/page"))
+	assert.True(t, exists)
+	assert.False(t, used)
+	depth, ok := storage.depth(parse("https://example.com/doc.pdf"))
+	assert.True(t, ok)
+	assert.Equal(t, 2, depth)
+
+	var handedOut []string
+	for u, ok := storage.use(); ok; u, ok = storage.use() {
+		handedOut = append(handedOut, u.String())
+	}
+	assert.ElementsMatch(t, []string{"https://example.com/page", "https://example.com/doc.pdf"}, handedOut)
+	assert.Empty(t, storage.pending, "Used URLs should be dropped")
+
+	exists, used = storage.check(parse("https://example.com/page"))
+	assert.True(t, exists)
+	assert.True(t, used)
+	assert.False(t, storage.add(parse("https://example.com/page")), "Used URLs should not be added again")
+
+	total, usedCount := storage.count()
+	assert.Equal(t, 2, total)
+	assert.Equal(t, 2, usedCount)
+
+	all := storage.getAllUrls()
+	require.Len(t, all, 1, "Only the URLs accepted by keep should be returned")
+	assert.Equal(t, "https://example.com/doc.pdf", all[0].String())
+}
+
 func TestUrlStorage_Add_Concurrency(t *testing.T) {
 	us := newUrlStorage()
 	numGoroutines := 100
@@ -0,0 +1,98 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"docscrawler/app/fetch"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// Defaults of the webhook delivery
+const (
+	webhookConcurrency = 4   // Default number of concurrent POST requests to the webhook
+	webhookQueueSize   = 100 // Documents waiting to be posted before the analysis waits for the webhook
+)
+
+// tWebhook posts the metadata of each analysed document to a webhook as it is produced
+// Documents are queued and posted by a pool of workers, so a slow webhook only holds the
+// analysis back once the queue is full
+type tWebhook struct {
+	client *fetch.Client
+	url    string
+	logger Logger
+	queue  chan tWebhookDoc
+	wg     sync.WaitGroup
+	failed atomic.Int64 // Documents that could not be posted
+}
+
+// tWebhookDoc is a document waiting to be posted
+type tWebhookDoc struct {
+	url  string // Document URL, for the log
+	data []byte // Metadata JSON of the document
+}
+
+// newWebhook starts the given number of workers posting the queued documents to the webhook URL
+// until stop is called; the context aborts the requests in progress
+func newWebhook(ctx context.Context, client *fetch.Client, url string, concurrency int, logger Logger) *tWebhook {
+	webhook := &tWebhook{
+		client: client,
+		url:    url,
+		logger: logger,
+		queue:  make(chan tWebhookDoc, webhookQueueSize),
+	}
+	for range concurrency {
+		webhook.wg.Add(1)
+		go func() {
+			defer webhook.wg.Done()
+			for doc := range webhook.queue {
+				webhook.post(ctx, doc)
+			}
+		}()
+	}
+	return webhook
+}
+
+// send queues the metadata of the document at the URL for posting, waiting while the queue is full
+func (webhook *tWebhook) send(url string, researcher tJsonOutputter) error {
+	var buf bytes.Buffer
+	err := researcher.OutJSON(&buf)
+	if err != nil {
+		return err
+	}
+	webhook.queue <- tWebhookDoc{url: url, data: buf.Bytes()}
+	return nil
+}
+
+// post sends a document to the webhook, the client retrying network errors and 5xx responses
+// Failures are logged and counted, they do not fail the document
+func (webhook *tWebhook) post(ctx context.Context, doc tWebhookDoc) {
+	resp, err := webhook.client.Post(ctx, webhook.url, "application/json", doc.data)
+	if err == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+			err = fmt.Errorf("status code %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		webhook.failed.Add(1)
+		webhook.logger.Warn("webhook failed", "url", doc.url, "error", err)
+		return
+	}
+	webhook.logger.Debug("document posted", "url", doc.url)
+}
+
+// stop waits until the queued documents are posted and the workers have finished
+// Returns an error counting the documents that could not be posted
+func (webhook *tWebhook) stop() error {
+	close(webhook.queue)
+	webhook.wg.Wait()
+	if n := webhook.failed.Load(); n > 0 {
+		return fmt.Errorf("%d documents could not be posted to the webhook", n)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineWebhook(t *testing.T) {
+	odt := buildTestOdt(t, "Posted")
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			for i := 0; i < 5; i++ {
+				fmt.Fprintf(w, `<a href="/%d.odt">Doc</a>`, i)
+			}
+			return
+		}
+		w.Write(odt)
+	}))
+	defer site.Close()
+
+	// The webhook fails the first attempt of every document
+	var mu sync.Mutex
+	var posted []map[string]any
+	attempted := make(map[string]bool)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Empty(t, r.Header.Get("Authorization"), "Credentials of the site should not be sent to the webhook")
+		var doc map[string]any
+		body, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &doc))
+
+		mu.Lock()
+		defer mu.Unlock()
+		docUrl, _ := doc["url"].(string)
+		if !attempted[docUrl] {
+			attempted[docUrl] = true
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		posted = append(posted, doc)
+	}))
+	defer webhook.Close()
+
+	cfg := Config{
+		Site:      []string{site.URL},
+		Type:      []string{"odt"},
+		Paramax:   2,
+		Depth:     1,
+		User:      "alice",
+		Password:  "secret",
+		Retries:   2,
+		RetryWait: time.Millisecond,
+		Webhook:   webhook.URL,
+	}
+
+	t.Run("Documents posted and written", func(t *testing.T) {
+		posted, attempted = nil, make(map[string]bool)
+		cfg.Output = filepath.Join(t.TempDir(), "output.json")
+		engine, err := New(cfg)
+		require.NoError(t, err)
+		results, err := engine.Run(context.Background())
+		require.NoError(t, err)
+
+		assert.Len(t, results, 5)
+		require.Len(t, posted, 5, "Every document should be posted, 5xx responses retried")
+		assert.Equal(t, "odt", posted[0]["doc_type"])
+		assert.FileExists(t, cfg.Output, "Output should still be written")
+	})
+
+	t.Run("Webhook only", func(t *testing.T) {
+		posted, attempted = nil, make(map[string]bool)
+		only := cfg
+		only.Output = filepath.Join(t.TempDir(), "output.json")
+		only.WebhookOnly = true
+		engine, err := New(only)
+		require.NoError(t, err)
+		_, err = engine.Run(context.Background())
+		require.NoError(t, err)
+
+		assert.Len(t, posted, 5)
+		_, err = os.Stat(only.Output)
+		assert.ErrorIs(t, err, os.ErrNotExist, "Output should not be written")
+	})
+
+	t.Run("Failures reported", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer failing.Close()
+
+		failed := cfg
+		failed.Output = ""
+		failed.Webhook = failing.URL
+		engine, err := New(failed)
+		require.NoError(t, err)
+		results, err := engine.Run(context.Background())
+		assert.Len(t, results, 5, "Documents should not fail with the webhook")
+		assert.ErrorContains(t, err, "5 documents could not be posted to the webhook")
+	})
+
+	t.Run("Webhook only requires a webhook", func(t *testing.T) {
+		_, err := New(Config{Site: []string{site.URL}, Type: []string{"odt"}, Paramax: 1, WebhookOnly: true})
+		assert.Error(t, err)
+	})
+}
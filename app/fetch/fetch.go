@@ -0,0 +1,237 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultUserAgent identifies the crawler when no User-Agent is configured
+const DefaultUserAgent = "docs-metadata-crawler/1.0"
+
+// ErrExternalRedirect is returned for requests to the site hosts redirected to another host,
+// unless external redirects are followed
+var ErrExternalRedirect = errors.New("redirect to another host refused")
+
+// Options defines the request policy of a Client
+type Options struct {
+	Timeout   time.Duration // Request timeout (no timeout if zero)
+	Gate      *Gate         // Politeness gate (no delay if nil)
+	UserAgent string        // User-Agent header value (DefaultUserAgent if empty)
+	Hosts     []string      // Hosts of the site credentials and headers are sent to, matched whatever the port and case
+	User      string        // Basic authentication user name (no authentication if empty)
+	Password  string        // Basic authentication password
+	Header    http.Header   // Additional headers sent to the host
+	Retries   int           // Number of retries after a network error or 5xx response
+	RetryWait time.Duration // Base wait before the first retry, doubled for every next one
+
+	FollowExternalRedirects bool // Follow redirects from the site hosts to other hosts (refused if false)
+
+	// Base client providing the transport, proxy and cookie jar (a plain client if nil)
+	// It is copied, not modified; Timeout replaces its timeout unless zero,
+	// and its redirect policy is consulted after the external redirect check
+	HTTPClient *http.Client
+}
+
+// Client performs HTTP requests for the crawler and the researchers
+// All requests made through clients sharing a Gate respect its per-host delay
+type Client struct {
+	http *http.Client // Underlying HTTP client
+	opts Options      // Request policy
+	read atomic.Int64 // Bytes read from response bodies
+}
+
+// NewClient creates a client applying the given request policy
+func NewClient(opts Options) *Client {
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+	opts.Header = opts.Header.Clone()
+	opts.Hosts = slices.Clone(opts.Hosts)
+
+	httpClient := &http.Client{}
+	if opts.HTTPClient != nil {
+		*httpClient = *opts.HTTPClient
+	}
+	if opts.Timeout != 0 {
+		httpClient.Timeout = opts.Timeout
+	}
+
+	c := &Client{
+		http: httpClient,
+		opts: opts,
+	}
+	c.http.CheckRedirect = c.checkRedirect
+	return c
+}
+
+// checkRedirect refuses redirects from the site hosts to other hosts, unless external redirects
+// are followed; requests that started on another host are redirected freely
+// The credentials and additional headers of the site are removed from requests redirected off it,
+// net/http only removing Authorization and Cookie, and only for some hosts
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if !c.isSiteHostname(req.URL.Hostname()) {
+		if c.isSiteHostname(via[0].URL.Hostname()) && !c.opts.FollowExternalRedirects {
+			return fmt.Errorf("%w: %s", ErrExternalRedirect, req.URL)
+		}
+		for name := range c.opts.Header {
+			req.Header.Del(name)
+		}
+		if c.opts.User != "" {
+			req.Header.Del("Authorization")
+		}
+	}
+	if c.opts.HTTPClient != nil && c.opts.HTTPClient.CheckRedirect != nil {
+		return c.opts.HTTPClient.CheckRedirect(req, via)
+	}
+	// Default policy of http.Client
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return nil
+}
+
+// isSiteHostname reports whether the hostname is one of the site hosts, whatever the port and case
+func (c *Client) isSiteHostname(hostname string) bool {
+	return slices.ContainsFunc(c.opts.Hosts, func(host string) bool {
+		return strings.EqualFold((&url.URL{Host: host}).Hostname(), hostname)
+	})
+}
+
+// BytesRead returns the number of bytes read so far from the bodies of the responses of the client,
+// after decoding of the transfer by the transport, discarded bodies of retried responses included
+func (c *Client) BytesRead() int64 {
+	return c.read.Load()
+}
+
+// tCountingBody is a response body adding the bytes read from it to the counter of its client
+type tCountingBody struct {
+	io.ReadCloser
+	read *atomic.Int64
+}
+
+// Read reads from the body and counts the bytes read
+func (body *tCountingBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	body.read.Add(int64(n))
+	return n, err
+}
+
+// Get issues a GET request to the URL once the politeness gate lets it through
+// Network errors and 5xx responses are retried with exponential backoff, 4xx responses are not
+// The request is aborted when the context is cancelled
+func (c *Client) Get(ctx context.Context, rawUrl string) (*http.Response, error) {
+	return c.do(ctx, http.MethodGet, rawUrl, nil, nil)
+}
+
+// Head issues a HEAD request to the URL under the same policy as Get
+func (c *Client) Head(ctx context.Context, rawUrl string) (*http.Response, error) {
+	return c.do(ctx, http.MethodHead, rawUrl, nil, nil)
+}
+
+// GetRange issues a GET request for length bytes of the resource starting at offset,
+// under the same policy as Get
+// A server supporting ranges answers 206 Partial Content, one ignoring them 200 with the whole resource
+func (c *Client) GetRange(ctx context.Context, rawUrl string, offset, length int64) (*http.Response, error) {
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)}}
+	return c.do(ctx, http.MethodGet, rawUrl, header, nil)
+}
+
+// GetConditional issues a GET request under the same policy as Get, sending the ETag as If-None-Match
+// and the Last-Modified date as If-Modified-Since (neither if empty)
+// A server holding the same version of the resource answers 304 Not Modified without a body
+func (c *Client) GetConditional(ctx context.Context, rawUrl string, etag, lastModified string) (*http.Response, error) {
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		header.Set("If-Modified-Since", lastModified)
+	}
+	return c.do(ctx, http.MethodGet, rawUrl, header, nil)
+}
+
+// Post issues a POST request of the body with the given content type to the URL,
+// under the same policy as Get; the body is sent again with every retry
+func (c *Client) Post(ctx context.Context, rawUrl string, contentType string, body []byte) (*http.Response, error) {
+	header := http.Header{"Content-Type": {contentType}}
+	return c.do(ctx, http.MethodPost, rawUrl, header, body)
+}
+
+// do issues a request with the given method, request-specific headers and body (none if nil),
+// retrying it as described for Get
+func (c *Client) do(ctx context.Context, method string, rawUrl string, header http.Header, body []byte) (*http.Response, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, u, header, body)
+
+		retry := attempt < c.opts.Retries && ctx.Err() == nil && !errors.Is(err, ErrExternalRedirect) &&
+			(err != nil || resp.StatusCode >= http.StatusInternalServerError)
+		if !retry {
+			if err != nil && attempt > 0 {
+				return nil, fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+			}
+			return resp, err
+		}
+
+		// Discard the failed response so the connection can be reused
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		err = sleep(ctx, backoff(c.opts.RetryWait, attempt))
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// send performs a single request applying headers, credentials and the politeness gate
+// The request-specific headers take precedence over the configured ones
+func (c *Client) send(ctx context.Context, method string, u *url.URL, header http.Header, body []byte) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+
+	// Credentials and additional headers never leave the site hosts
+	if c.isSiteHostname(u.Hostname()) {
+		for name, values := range c.opts.Header {
+			req.Header[name] = values
+		}
+		if c.opts.User != "" {
+			req.SetBasicAuth(c.opts.User, c.opts.Password)
+		}
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	err = c.opts.Gate.Wait(ctx, u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if resp != nil {
+		resp.Body = &tCountingBody{ReadCloser: resp.Body, read: &c.read}
+	}
+	return resp, err
+}
@@ -0,0 +1,372 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientGet(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	t.Run("Successful request", func(t *testing.T) {
+		client := NewClient(Options{Timeout: time.Second})
+
+		resp, err := client.Get(context.Background(), ts.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Bytes read", func(t *testing.T) {
+		client := NewClient(Options{Timeout: time.Second})
+		for i := 0; i < 2; i++ {
+			resp, err := client.Get(context.Background(), ts.URL)
+			require.NoError(t, err)
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		assert.Equal(t, int64(4), client.BytesRead(), "Bodies of every response should be counted")
+	})
+
+	t.Run("HEAD request", func(t *testing.T) {
+		var method string
+		headServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method = r.Method
+			w.Header().Set("Content-Type", "application/pdf")
+		}))
+		defer headServer.Close()
+
+		resp, err := NewClient(Options{Timeout: time.Second}).Head(context.Background(), headServer.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.MethodHead, method)
+		assert.Equal(t, "application/pdf", resp.Header.Get("Content-Type"))
+	})
+
+	t.Run("Conditional request", func(t *testing.T) {
+		var header http.Header
+		conditionalServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header
+			w.WriteHeader(http.StatusNotModified)
+		}))
+		defer conditionalServer.Close()
+
+		client := NewClient(Options{Timeout: time.Second})
+		resp, err := client.GetConditional(context.Background(), conditionalServer.URL, `"v1"`, "Wed, 21 Oct 2015 07:28:00 GMT")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+		assert.Equal(t, `"v1"`, header.Get("If-None-Match"))
+		assert.Equal(t, "Wed, 21 Oct 2015 07:28:00 GMT", header.Get("If-Modified-Since"))
+
+		resp, err = client.GetConditional(context.Background(), conditionalServer.URL, "", "")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.NotContains(t, header, "If-None-Match", "Empty validators should not be sent")
+		assert.NotContains(t, header, "If-Modified-Since")
+	})
+
+	t.Run("Range request", func(t *testing.T) {
+		rangeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.ServeContent(w, r, "doc.bin", time.Time{}, strings.NewReader("0123456789"))
+		}))
+		defer rangeServer.Close()
+
+		resp, err := NewClient(Options{Timeout: time.Second}).GetRange(context.Background(), rangeServer.URL, 2, 3)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "234", string(data))
+	})
+
+	t.Run("Post request retried with its body", func(t *testing.T) {
+		var attempts atomic.Int64
+		postServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.Equal(t, `{"a":1}`, string(body), "Every attempt should send the whole body")
+			if attempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer postServer.Close()
+
+		client := NewClient(Options{Timeout: time.Second, Retries: 1})
+		resp, err := client.Post(context.Background(), postServer.URL, "application/json", []byte(`{"a":1}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int64(2), attempts.Load())
+	})
+
+	t.Run("Requests respect the gate", func(t *testing.T) {
+		interval := 50 * time.Millisecond
+		client := NewClient(Options{Timeout: time.Second, Gate: NewGate(interval)})
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			resp, err := client.Get(context.Background(), ts.URL)
+			require.NoError(t, err)
+			resp.Body.Close()
+		}
+
+		assert.GreaterOrEqual(t, time.Since(start), 2*interval, "Requests to the same host should be spaced out")
+	})
+
+	t.Run("User-Agent header", func(t *testing.T) {
+		var userAgent string
+		uaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userAgent = r.UserAgent()
+		}))
+		defer uaServer.Close()
+
+		resp, err := NewClient(Options{}).Get(context.Background(), uaServer.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, DefaultUserAgent, userAgent, "Default User-Agent should identify the crawler")
+
+		resp, err = NewClient(Options{UserAgent: "my-bot/2.0 (+https://example.com/bot)"}).Get(context.Background(), uaServer.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, "my-bot/2.0 (+https://example.com/bot)", userAgent, "Configured User-Agent should be sent")
+	})
+
+	t.Run("Basic authentication is scoped to the host", func(t *testing.T) {
+		var user, password string
+		var ok bool
+		authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, password, ok = r.BasicAuth()
+		}))
+		defer authServer.Close()
+
+		serverUrl, err := url.Parse(authServer.URL)
+		require.NoError(t, err)
+
+		client := NewClient(Options{Hosts: []string{serverUrl.Host}, User: "alice", Password: "secret"})
+		resp, err := client.Get(context.Background(), authServer.URL+"/private.pdf")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.True(t, ok, "Credentials should be sent to the configured host")
+		assert.Equal(t, "alice", user)
+		assert.Equal(t, "secret", password)
+
+		client = NewClient(Options{Hosts: []string{"docs.example.com", serverUrl.Host}, User: "alice", Password: "secret"})
+		resp, err = client.Get(context.Background(), authServer.URL+"/private.pdf")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.True(t, ok, "Credentials should be sent to every site host")
+
+		client = NewClient(Options{Hosts: []string{"LOCALHOST"}, User: "alice", Password: "secret"})
+		resp, err = client.Get(context.Background(), "http://localhost:"+serverUrl.Port()+"/private.pdf")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.True(t, ok, "Site hosts should be matched by hostname, whatever the case and port")
+
+		client = NewClient(Options{Hosts: []string{"docs.example.com"}, User: "alice", Password: "secret"})
+		resp, err = client.Get(context.Background(), authServer.URL+"/private.pdf")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.False(t, ok, "Credentials should not be sent to other hosts")
+	})
+
+	t.Run("Additional headers are scoped to the host", func(t *testing.T) {
+		var received http.Header
+		headerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r.Header.Clone()
+		}))
+		defer headerServer.Close()
+
+		serverUrl, err := url.Parse(headerServer.URL)
+		require.NoError(t, err)
+
+		header := make(http.Header)
+		header.Set("X-Api-Key", "key123")
+		header.Set("Cookie", "session=abc")
+
+		resp, err := NewClient(Options{Hosts: []string{serverUrl.Host}, Header: header}).Get(context.Background(), headerServer.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, "key123", received.Get("X-Api-Key"), "Header should be sent to the configured host")
+		assert.Equal(t, "session=abc", received.Get("Cookie"), "Header should be sent to the configured host")
+
+		resp, err = NewClient(Options{Hosts: []string{"docs.example.com"}, Header: header}).Get(context.Background(), headerServer.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Empty(t, received.Get("X-Api-Key"), "Header should not be sent to other hosts")
+		assert.Empty(t, received.Get("Cookie"), "Header should not be sent to other hosts")
+	})
+
+	t.Run("Cancelled context aborts the request", func(t *testing.T) {
+		slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		defer slowServer.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := NewClient(Options{}).Get(ctx, slowServer.URL)
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "Request should be aborted by the context")
+	})
+
+	t.Run("Invalid URL", func(t *testing.T) {
+		client := NewClient(Options{Timeout: time.Second})
+
+		_, err := client.Get(context.Background(), "://invalid.url")
+		assert.Error(t, err)
+	})
+}
+
+func TestClientRedirects(t *testing.T) {
+	// The target is reached by another hostname of the loopback interface
+	var targetHeader http.Header
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targetHeader = r.Header.Clone()
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+	targetUrl, err := url.Parse(target.URL)
+	require.NoError(t, err)
+	external := "http://localhost:" + targetUrl.Port() + "/file.pdf"
+
+	var requests int
+	var siteHeader http.Header
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		siteHeader = r.Header.Clone()
+		switch r.URL.Path {
+		case "/external":
+			http.Redirect(w, r, external, http.StatusFound)
+		case "/internal":
+			http.Redirect(w, r, "/file.pdf", http.StatusFound)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer site.Close()
+	siteUrl, err := url.Parse(site.URL)
+	require.NoError(t, err)
+
+	t.Run("Redirect to another host is refused", func(t *testing.T) {
+		requests = 0
+		client := NewClient(Options{Hosts: []string{siteUrl.Host}, Retries: 2})
+		_, err := client.Get(context.Background(), site.URL+"/external")
+		assert.ErrorIs(t, err, ErrExternalRedirect)
+		assert.Contains(t, err.Error(), external, "Error should name the redirect target")
+		assert.Equal(t, 1, requests, "Refused redirects should not be retried")
+	})
+
+	t.Run("Redirect on the same host is followed", func(t *testing.T) {
+		resp, err := NewClient(Options{Hosts: []string{siteUrl.Host}}).Get(context.Background(), site.URL+"/internal")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, site.URL+"/file.pdf", resp.Request.URL.String())
+	})
+
+	t.Run("Redirect from another host is followed", func(t *testing.T) {
+		resp, err := NewClient(Options{Hosts: []string{"docs.example.com"}}).Get(context.Background(), site.URL+"/external")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, external, resp.Request.URL.String())
+	})
+
+	t.Run("External redirects are followed when enabled", func(t *testing.T) {
+		client := NewClient(Options{Hosts: []string{siteUrl.Host}, FollowExternalRedirects: true})
+		resp, err := client.Get(context.Background(), site.URL+"/external")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, external, resp.Request.URL.String())
+	})
+
+	t.Run("Site headers are not sent to external redirect targets", func(t *testing.T) {
+		client := NewClient(Options{
+			Hosts:                   []string{siteUrl.Host},
+			FollowExternalRedirects: true,
+			Header:                  http.Header{"X-Api-Key": {"secret"}, "Cookie": {"session=1"}},
+			User:                    "user",
+			Password:                "pass",
+		})
+		resp, err := client.Get(context.Background(), site.URL+"/external")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, "secret", siteHeader.Get("X-Api-Key"), "Site should receive the headers")
+		assert.Equal(t, "session=1", siteHeader.Get("Cookie"), "Site should receive the cookies")
+		assert.Empty(t, targetHeader.Get("X-Api-Key"), "External host should not receive the headers")
+		assert.Empty(t, targetHeader.Get("Cookie"), "External host should not receive the cookies")
+		assert.Empty(t, targetHeader.Get("Authorization"), "External host should not receive the credentials")
+		assert.NotEmpty(t, targetHeader.Get("User-Agent"), "Other headers should still be sent")
+	})
+}
+
+// tRoundTripper answers requests with a function instead of a server
+type tRoundTripper func(req *http.Request) (*http.Response, error)
+
+func (rt tRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt(req)
+}
+
+func TestClientHTTPClient(t *testing.T) {
+	var userAgent string
+	base := &http.Client{
+		Timeout: time.Minute,
+		Transport: tRoundTripper(func(req *http.Request) (*http.Response, error) {
+			userAgent = req.Header.Get("User-Agent")
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader("ok")),
+				Request:    req,
+			}, nil
+		}),
+	}
+
+	t.Run("Requests go through the base transport", func(t *testing.T) {
+		client := NewClient(Options{HTTPClient: base})
+		resp, err := client.Get(context.Background(), "https://docs.example.com/file.pdf")
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		assert.Equal(t, "ok", string(body))
+		assert.Equal(t, DefaultUserAgent, userAgent, "Request policy should still apply")
+		assert.Equal(t, time.Minute, client.http.Timeout, "Timeout of the base client should be kept")
+	})
+
+	t.Run("Base client is not modified", func(t *testing.T) {
+		client := NewClient(Options{HTTPClient: base, Timeout: time.Second, Hosts: []string{"docs.example.com"}})
+		assert.Equal(t, time.Second, client.http.Timeout, "Timeout option should replace the base timeout")
+		assert.NotNil(t, client.http.CheckRedirect)
+		assert.Equal(t, time.Minute, base.Timeout)
+		assert.Nil(t, base.CheckRedirect)
+	})
+
+	t.Run("Base redirect policy is consulted", func(t *testing.T) {
+		stop := errors.New("no redirects")
+		policy := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error { return stop }}
+		redirecting := httptest.NewServer(http.RedirectHandler("/target", http.StatusFound))
+		defer redirecting.Close()
+
+		_, err := NewClient(Options{HTTPClient: policy}).Get(context.Background(), redirecting.URL)
+		assert.ErrorIs(t, err, stop)
+	})
+}
@@ -0,0 +1,46 @@
+package fetch
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Gate is a per-host timed gate that spaces out requests to the same host
+// Each caller reserves the next free time slot of its host, so the gate is safe
+// to share between worker goroutines and never lets requests to one host through
+// faster than interval
+type Gate struct {
+	mu       sync.Mutex           // Mutex protecting the next free slots
+	interval time.Duration        // Minimum interval between two requests to a host (0 = no limit)
+	next     map[string]time.Time // Earliest moment the next request to each host may pass
+}
+
+// NewGate creates a gate with the given minimum interval between requests to a host
+func NewGate(interval time.Duration) *Gate {
+	return &Gate{
+		interval: interval,
+		next:     make(map[string]time.Time),
+	}
+}
+
+// Wait blocks until a request to the given host is allowed to proceed
+// Returns immediately if the gate is nil or has no interval configured,
+// and with the context error if the context is cancelled while waiting
+func (g *Gate) Wait(ctx context.Context, host string) error {
+	if g == nil || g.interval <= 0 {
+		return nil
+	}
+
+	// Reserve a slot under the lock, then sleep outside of it
+	g.mu.Lock()
+	now := time.Now()
+	slot := g.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	g.next[host] = slot.Add(g.interval)
+	g.mu.Unlock()
+
+	return sleep(ctx, time.Until(slot))
+}
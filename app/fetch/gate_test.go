@@ -0,0 +1,86 @@
+package fetch
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGate(t *testing.T) {
+	t.Run("Zero interval does not block", func(t *testing.T) {
+		gate := NewGate(0)
+
+		start := time.Now()
+		for i := 0; i < 100; i++ {
+			gate.Wait(context.Background(), "example.com")
+		}
+
+		assert.Less(t, time.Since(start), 50*time.Millisecond, "Gate without interval should not delay")
+	})
+
+	t.Run("Nil gate does not block", func(t *testing.T) {
+		var gate *Gate
+		assert.NotPanics(t, func() { gate.Wait(context.Background(), "example.com") }, "Nil gate should be a no-op")
+	})
+
+	t.Run("Interval is enforced across goroutines", func(t *testing.T) {
+		interval := 20 * time.Millisecond
+		gate := NewGate(interval)
+
+		var mu sync.Mutex
+		passes := make([]time.Time, 0, 5)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				gate.Wait(context.Background(), "example.com")
+				mu.Lock()
+				passes = append(passes, time.Now())
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		// Five passes need at least four full intervals between the first and the last
+		first, last := passes[0], passes[0]
+		for _, p := range passes {
+			if p.Before(first) {
+				first = p
+			}
+			if p.After(last) {
+				last = p
+			}
+		}
+		assert.GreaterOrEqual(t, last.Sub(first), 4*interval-time.Millisecond, "Passes should be spaced by the interval")
+	})
+
+	t.Run("Cancellation interrupts waiting", func(t *testing.T) {
+		gate := NewGate(time.Hour)
+		require.NoError(t, gate.Wait(context.Background(), "example.com"), "First request should pass")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := gate.Wait(ctx, "example.com")
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "Waiting should end with the context error")
+		assert.Less(t, time.Since(start), time.Second, "Waiting should stop on cancellation")
+	})
+
+	t.Run("Hosts are gated independently", func(t *testing.T) {
+		gate := NewGate(time.Second)
+
+		start := time.Now()
+		gate.Wait(context.Background(), "a.example.com")
+		gate.Wait(context.Background(), "b.example.com")
+		gate.Wait(context.Background(), "c.example.com")
+
+		assert.Less(t, time.Since(start), 100*time.Millisecond, "First request to each host should not wait")
+	})
+}
@@ -0,0 +1,38 @@
+package fetch
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// maxRetryWait caps the wait between retries
+const maxRetryWait = time.Minute
+
+// backoff returns the wait before the retry following the given attempt (0-based)
+// The wait doubles with every attempt and is randomized to between half and
+// the full value, so that concurrent workers don't retry in lockstep
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	// The cap is shifted instead of the base, which could overflow to a negative wait
+	wait := maxRetryWait
+	if base < maxRetryWait>>attempt {
+		wait = base << attempt
+	}
+	return wait/2 + rand.N(wait/2+1)
+}
+
+// sleep waits for the given duration or until the context is cancelled
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
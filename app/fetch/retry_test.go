@@ -0,0 +1,117 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+
+	for attempt := 0; attempt < 4; attempt++ {
+		full := base << attempt
+		wait := backoff(base, attempt)
+		assert.GreaterOrEqual(t, wait, full/2, "Wait should be at least half of the doubled base")
+		assert.LessOrEqual(t, wait, full, "Wait should not exceed the doubled base")
+	}
+
+	caps := []struct {
+		name    string
+		base    time.Duration
+		attempt int
+	}{
+		{"Many attempts", base, 100},
+		{"Base above the cap", 2 * time.Hour, 0},
+		{"Shift overflowing the base", 10 * time.Second, 30},
+		{"Shift beyond the duration bits", 10 * time.Second, 64},
+	}
+	for _, tc := range caps {
+		wait := backoff(tc.base, tc.attempt)
+		assert.GreaterOrEqual(t, wait, maxRetryWait/2, "%s: wait should be at least half of the cap", tc.name)
+		assert.LessOrEqual(t, wait, maxRetryWait, "%s: wait should be capped", tc.name)
+	}
+	assert.Zero(t, backoff(0, 3), "Zero base should not wait")
+}
+
+func TestClientRetries(t *testing.T) {
+	// newFlakyServer fails the first failures requests with the given status
+	newFlakyServer := func(failures int32, status int) (*httptest.Server, *atomic.Int32) {
+		var requests atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) <= failures {
+				w.WriteHeader(status)
+				return
+			}
+			w.Write([]byte("ok"))
+		}))
+		return ts, &requests
+	}
+
+	t.Run("5xx responses are retried", func(t *testing.T) {
+		ts, requests := newFlakyServer(2, http.StatusServiceUnavailable)
+		defer ts.Close()
+
+		client := NewClient(Options{Retries: 3, RetryWait: time.Millisecond})
+		resp, err := client.Get(context.Background(), ts.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "Request should eventually succeed")
+		assert.Equal(t, int32(3), requests.Load(), "Two failures and one success expected")
+	})
+
+	t.Run("Last 5xx response is returned when retries are exhausted", func(t *testing.T) {
+		ts, requests := newFlakyServer(10, http.StatusBadGateway)
+		defer ts.Close()
+
+		client := NewClient(Options{Retries: 2, RetryWait: time.Millisecond})
+		resp, err := client.Get(context.Background(), ts.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+		assert.Equal(t, int32(3), requests.Load(), "One attempt plus two retries expected")
+	})
+
+	t.Run("4xx responses are not retried", func(t *testing.T) {
+		ts, requests := newFlakyServer(10, http.StatusNotFound)
+		defer ts.Close()
+
+		client := NewClient(Options{Retries: 3, RetryWait: time.Millisecond})
+		resp, err := client.Get(context.Background(), ts.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, int32(1), requests.Load(), "Client errors should not be retried")
+	})
+
+	t.Run("Network errors are retried and reported", func(t *testing.T) {
+		ts := httptest.NewServer(http.NotFoundHandler())
+		deadUrl := ts.URL
+		ts.Close()
+
+		client := NewClient(Options{Retries: 2, RetryWait: time.Millisecond})
+		_, err := client.Get(context.Background(), deadUrl)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "giving up after 3 attempts", "Final error should mention the attempts")
+	})
+
+	t.Run("No retries by default", func(t *testing.T) {
+		ts, requests := newFlakyServer(10, http.StatusServiceUnavailable)
+		defer ts.Close()
+
+		resp, err := NewClient(Options{}).Get(context.Background(), ts.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, int32(1), requests.Load())
+	})
+}
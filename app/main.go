@@ -1,19 +1,148 @@
 package main
 
 import (
+	"context"
+	"docscrawler/app/crawler"
+	"docscrawler/app/researchers"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 )
 
 // tOpts defines command line options for the document crawler
 // Uses go-flags package for parsing and validation
+// Fields are named after those of crawler.Config they are carried over to by config, aliases aside
 type tOpts struct {
-	Site    string   `short:"s" long:"site" required:"true" description:"site name"`
-	Type    []string `short:"t" long:"type" choice:"pdf" choice:"docx" choice:"xlsx" choice:"pptx" description:"document type / file name extension (all if empty)"`
-	Output  string   `short:"o" long:"output" default:"" description:"output stream, stdout if none"`
-	Paramax int      `short:"p" long:"paramax" default:"100" description:"maximum number of parallel analysis threads"`
+	Site                    []string      `short:"s" long:"site" required:"true" description:"site URL to start from, repeat to crawl from several"`
+	Type                    []string      `short:"t" long:"type" description:"document type / file name extension (all if empty)"` // Choices are the registered researchers
+	Sniff                   bool          `long:"sniff" description:"detect the type of URLs without a document extension from the Content-Type of a HEAD request"`
+	ByContentType           bool          `long:"by-content-type" description:"alias of --sniff"` // Resolved by config, the library has Sniff only
+	Include                 []string      `long:"include" description:"regular expression (or glob:path) of URLs to keep, all others are skipped; the site URL is always crawled (repeatable)"`
+	Exclude                 []string      `long:"exclude" description:"regular expression (or glob:path) of URLs to skip, neither crawled nor analysed (repeatable)"`
+	PathPrefix              string        `long:"path-prefix" description:"only crawl and analyse URLs whose path starts with this prefix, e.g. /docs/"`
+	Output                  string        `short:"o" long:"output" default:"" description:"output stream, stdout if none"`
+	ErrorOutput             string        `long:"error-output" description:"file the documents that failed to be analysed are written to as JSON records with url, doc_type and error, - for stdout (none if empty)"`
+	Format                  string        `short:"f" long:"format" default:"json" choice:"json" choice:"ndjson" choice:"sqlite" description:"output format: JSON array, newline-delimited JSON or SQLite database"`
+	Pretty                  bool          `long:"pretty" description:"indent the JSON output for reading (ignored for ndjson)"`
+	WithSummary             bool          `long:"with-summary" description:"write the summary of the run (pages crawled, documents found by type, failures, bytes downloaded) as the last element of the output"`
+	SummaryFile             string        `long:"summary-file" description:"write the summary of the run as a JSON object to this file, - for stdout"`
+	SplitByType             bool          `long:"split-by-type" description:"write the documents of each type to a file of their own named after the output file, e.g. output.pdf.json for output.json"`
+	SortBy                  string        `long:"sort-by" default:"url" choice:"url" choice:"title" choice:"modified" description:"field the documents are sorted by in the output, then by URL"`
+	Wrap                    bool          `long:"wrap" description:"write the documents in an object with the site, start time and count of the run, under \"documents\""`
+	Stream                  bool          `long:"stream" description:"write the documents of the JSON output as they are analysed, in that order, instead of sorted at the end"`
+	ReportErrors            bool          `long:"report-errors" description:"print the documents that failed to be analysed and why to stderr"`
+	Webhook                 string        `long:"webhook" description:"URL the metadata JSON of each analysed document is POSTed to as soon as it is analysed"`
+	WebhookConcurrency      int           `long:"webhook-concurrency" default:"4" description:"number of concurrent POST requests to the webhook"`
+	WebhookOnly             bool          `long:"webhook-only" description:"post the documents to the webhook without writing the output"`
+	Paramax                 int           `short:"p" long:"paramax" default:"100" description:"maximum number of parallel analysis threads"`
+	Delay                   time.Duration `short:"d" long:"delay" default:"0s" description:"minimum delay between requests to the same host, e.g. 500ms (no delay if zero)"`
+	Timeout                 time.Duration `long:"timeout" default:"0s" description:"HTTP request timeout, e.g. 2m (10s for pages and 30s for documents if zero)"`
+	MaxSize                 string        `long:"max-size" default:"100M" description:"maximum document size, e.g. 250M or bytes (unlimited if zero)"`
+	TempDir                 string        `long:"temp-dir" description:"directory of the temporary files of the downloads (OS temp directory if empty)"`
+	RangeRequests           bool          `long:"range-requests" description:"read OOXML documents over 8 MB by HTTP range requests, downloading only their metadata, where the server supports them"`
+	Depth                   int           `long:"depth" default:"0" description:"maximum number of clicks from the site page, 1 scans the site page only (unlimited if zero)"`
+	MaxPages                int           `long:"max-pages" default:"0" description:"maximum number of pages fetched while crawling, the site page included (unlimited if zero)"`
+	MaxDocs                 int           `long:"max-docs" default:"0" description:"number of documents analysed successfully after which no new analysis starts, those in progress still finish (unlimited if zero)"`
+	MaxDuration             time.Duration `long:"max-duration" default:"0s" description:"maximum duration of the crawl and analysis, e.g. 10m, after which the results gathered are written (unlimited if zero)"`
+	Sitemap                 bool          `long:"sitemap" description:"seed the crawl with the pages listed in the site's sitemap.xml"`
+	URLFile                 string        `long:"url-file" description:"file listing URLs to analyse, one per line; blank lines and # comments are ignored"`
+	NoCrawl                 bool          `long:"no-crawl" description:"follow no links, only analyse the site and --url-file URLs"`
+	IncludeSubdomains       bool          `long:"include-subdomains" description:"crawl every host within the registrable domain of the site, e.g. docs.example.com for www.example.com"`
+	AnalyseExternal         bool          `long:"analyse-external" description:"analyse documents the site links to on other hosts whatever --path-prefix, without crawling those hosts"`
+	RespectNofollow         bool          `long:"respect-nofollow" description:"follow no links marked rel=\"nofollow\""`
+	Scheme                  []string      `long:"scheme" default:"http" default:"https" description:"URL scheme links are followed and documents fetched over, repeat to accept several"`
+	HTTPSOnly               bool          `long:"https-only" description:"reject plain http links, whatever the --scheme"`
+	NoNormalize             bool          `long:"no-normalize" description:"tell URLs apart by their exact spelling, without dropping fragments, default ports or tracking parameters"`
+	Dedup                   bool          `long:"dedup" description:"report documents with the same content once, skipping copies found at other URLs"`
+	LowMemory               bool          `long:"low-memory" description:"keep a hash of each crawled URL instead of the URL itself, and only the possible documents in full, for very large sites"`
+	StateFile               string        `long:"state-file" description:"save the progress to this file and resume from it on the next run"`
+	Manifest                string        `long:"manifest" description:"file recording the analysed documents with their ETag and Last-Modified, so the next run with it only downloads the documents that changed"`
+	User                    string        `long:"user" description:"user name for HTTP basic authentication on the site"`
+	Password                string        `long:"password" env:"DOCSCRAWLER_PASSWORD" description:"password for HTTP basic authentication on the site, kept out of process listings if set in the environment instead"`
+	Header                  []string      `short:"H" long:"header" description:"additional request header \"Name: Value\" sent to the site (repeatable)"`
+	Cookie                  []string      `long:"cookie" description:"cookies \"name=value; name2=value2\" sent to the site, e.g. the session cookie of a logged-in browser (repeatable)"`
+	UserAgent               string        `long:"user-agent" description:"User-Agent header sent with every request (docs-metadata-crawler/1.0 if empty)"`
+	Retries                 int           `long:"retries" default:"2" description:"number of retries after a network error or 5xx response"`
+	RetryWait               time.Duration `long:"retry-wait" default:"1s" description:"wait before the first retry, doubled for every next one"`
+	FollowExternalRedirects bool          `long:"follow-external-redirects" description:"follow redirects from the site to other hosts"`
+	Proxy                   string        `long:"proxy" description:"URL of the proxy every request is sent through, e.g. http://proxy.example.com:3128, overriding HTTP_PROXY and the like"`
+	Progress                string        `long:"progress" optional:"yes" optional-value:"auto" choice:"auto" choice:"force" description:"refresh a status line on stderr every second, only if it is a terminal unless forced"`
+	LogLevel                string        `long:"log-level" default:"quiet" choice:"debug" choice:"info" choice:"warn" choice:"error" choice:"quiet" description:"level of the crawl events logged to stderr"`
+	LogFormat               string        `long:"log-format" default:"text" choice:"text" choice:"json" description:"format of the crawl events logged to stderr"`
+}
+
+// config returns the crawler configuration set by the options
+// Settings of the library that are not options, such as the logger, are left unset
+func (opts tOpts) config() crawler.Config {
+	return crawler.Config{
+		Site:                    opts.Site,
+		Type:                    opts.Type,
+		Sniff:                   opts.Sniff || opts.ByContentType,
+		Include:                 opts.Include,
+		Exclude:                 opts.Exclude,
+		PathPrefix:              opts.PathPrefix,
+		Output:                  opts.Output,
+		ErrorOutput:             opts.ErrorOutput,
+		Format:                  opts.Format,
+		Pretty:                  opts.Pretty,
+		WithSummary:             opts.WithSummary,
+		SummaryFile:             opts.SummaryFile,
+		SplitByType:             opts.SplitByType,
+		SortBy:                  opts.SortBy,
+		Wrap:                    opts.Wrap,
+		Stream:                  opts.Stream,
+		ReportErrors:            opts.ReportErrors,
+		Webhook:                 opts.Webhook,
+		WebhookConcurrency:      opts.WebhookConcurrency,
+		WebhookOnly:             opts.WebhookOnly,
+		Paramax:                 opts.Paramax,
+		Delay:                   opts.Delay,
+		Timeout:                 opts.Timeout,
+		MaxSize:                 opts.MaxSize,
+		TempDir:                 opts.TempDir,
+		RangeRequests:           opts.RangeRequests,
+		Depth:                   opts.Depth,
+		MaxPages:                opts.MaxPages,
+		MaxDocs:                 opts.MaxDocs,
+		MaxDuration:             opts.MaxDuration,
+		Sitemap:                 opts.Sitemap,
+		URLFile:                 opts.URLFile,
+		NoCrawl:                 opts.NoCrawl,
+		IncludeSubdomains:       opts.IncludeSubdomains,
+		AnalyseExternal:         opts.AnalyseExternal,
+		RespectNofollow:         opts.RespectNofollow,
+		Scheme:                  opts.Scheme,
+		HTTPSOnly:               opts.HTTPSOnly,
+		NoNormalize:             opts.NoNormalize,
+		Dedup:                   opts.Dedup,
+		LowMemory:               opts.LowMemory,
+		StateFile:               opts.StateFile,
+		Manifest:                opts.Manifest,
+		User:                    opts.User,
+		Password:                opts.Password,
+		Header:                  opts.Header,
+		Cookie:                  opts.Cookie,
+		UserAgent:               opts.UserAgent,
+		Retries:                 opts.Retries,
+		RetryWait:               opts.RetryWait,
+		FollowExternalRedirects: opts.FollowExternalRedirects,
+		Proxy:                   opts.Proxy,
+		Progress:                opts.Progress,
+		LogLevel:                opts.LogLevel,
+		LogFormat:               opts.LogFormat,
+	}
+}
+
+// newParser creates the command line parser for the options
+// Document types to choose from are the researchers registered at the time
+func newParser(opts *tOpts, options flags.Options) *flags.Parser {
+	parser := flags.NewParser(opts, options)
+	parser.FindOptionByLongName("type").Choices = researchers.Types()
+	return parser
 }
 
 // main is the entry point of the application
@@ -22,7 +151,7 @@ func main() {
 	var opts tOpts
 
 	// Initialize command line parser
-	parser := flags.NewParser(&opts, flags.Default)
+	parser := newParser(&opts, flags.Default)
 
 	// Parse command line arguments
 	if _, err := parser.Parse(); err != nil {
@@ -30,17 +159,45 @@ func main() {
 	}
 
 	// If no document types are specified, use all supported types
-	typeOption := parser.FindOptionByLongName("type")
-	allowDocTypes := typeOption.Choices
 	if len(opts.Type) == 0 {
-		opts.Type = allowDocTypes
+		opts.Type = researchers.Types()
+	}
+
+	// Options map one to one onto the crawler configuration, only the default output differs
+	cfg := opts.config()
+	if cfg.Output == "" {
+		cfg.Output = crawler.Stdout
+	}
+
+	// Failures of the run are logged in the format of the crawl events, whatever their level
+	errLogger, err := crawler.NewLogger(os.Stderr, crawler.LogError, cfg.LogFormat)
+	if err != nil {
+		log.Fatalf("Logger initialization error: %v", err)
 	}
 
 	// Initialize and run the crawler engine
-	engine, err := newEngine(opts)
+	engine, err := crawler.New(cfg)
 	if err != nil {
-		log.Fatalf("Engine initialization error: %v", err)
+		errLogger.Error("engine initialization failed", "error", err)
+		os.Exit(1)
 	}
 
-	engine.run()
+	// The first SIGINT/SIGTERM cancels the crawl, the results gathered so far are still written;
+	// once received, default signal handling is restored so a second signal exits immediately
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		errLogger.Error("interrupted, writing partial results (repeat to exit immediately)", "signal", sig.String())
+		cancel()
+	}()
+
+	// Results are written by the engine even when the run fails, the errors only set the exit status
+	if _, err := engine.Run(ctx); err != nil {
+		errLogger.Error("crawl failed", "error", err)
+		os.Exit(1)
+	}
 }
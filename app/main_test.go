@@ -1,9 +1,14 @@
 package main
 
 import (
+	"docscrawler/app/crawler"
+	"docscrawler/app/researchers"
 	"testing"
+	"time"
 
+	"github.com/jessevdk/go-flags"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestOptsParsing(t *testing.T) {
@@ -11,26 +16,142 @@ func TestOptsParsing(t *testing.T) {
 	opts := tOpts{}
 
 	// Assert default values
-	assert.Equal(t, "", opts.Site, "Default Site should be empty string")
+	assert.Empty(t, opts.Site, "Default Site should be empty slice")
 	assert.Empty(t, opts.Type, "Default Type should be empty slice")
 	assert.Equal(t, "", opts.Output, "Default Output should be empty string")
 	assert.Equal(t, 0, opts.Paramax, "Default Paramax should be 0")
 
 	// Test with values
 	opts = tOpts{
-		Site:    "https://example.com",
+		Site:    []string{"https://example.com"},
 		Type:    []string{"pdf", "docx"},
 		Output:  "output.json",
 		Paramax: 10,
 	}
 
 	// Verify values
-	assert.Equal(t, "https://example.com", opts.Site)
+	assert.Equal(t, []string{"https://example.com"}, opts.Site)
 	assert.Equal(t, []string{"pdf", "docx"}, opts.Type)
 	assert.Equal(t, "output.json", opts.Output)
 	assert.Equal(t, 10, opts.Paramax)
 }
 
+func TestOptsTimeoutFlag(t *testing.T) {
+	t.Run("Zero by default", func(t *testing.T) {
+		var opts tOpts
+		_, err := flags.NewParser(&opts, flags.None).ParseArgs([]string{"-s", "https://example.com"})
+		require.NoError(t, err)
+		assert.Zero(t, opts.Timeout, "Timeout should be zero, leaving the per-client defaults in place")
+	})
+
+	t.Run("Duration value", func(t *testing.T) {
+		var opts tOpts
+		_, err := flags.NewParser(&opts, flags.None).ParseArgs([]string{"-s", "https://example.com", "--timeout", "2m"})
+		require.NoError(t, err)
+		assert.Equal(t, 2*time.Minute, opts.Timeout)
+	})
+
+	t.Run("Invalid value", func(t *testing.T) {
+		var opts tOpts
+		_, err := flags.NewParser(&opts, flags.None).ParseArgs([]string{"-s", "https://example.com", "--timeout", "soon"})
+		assert.Error(t, err, "Timeout without a unit should be rejected")
+	})
+}
+
+func TestOptsPasswordEnv(t *testing.T) {
+	t.Setenv("DOCSCRAWLER_PASSWORD", "from-env")
+
+	t.Run("Password from the environment", func(t *testing.T) {
+		var opts tOpts
+		_, err := flags.NewParser(&opts, flags.None).ParseArgs([]string{"-s", "https://example.com", "--user", "alice"})
+		require.NoError(t, err)
+		assert.Equal(t, "from-env", opts.Password)
+	})
+
+	t.Run("Flag overrides the environment", func(t *testing.T) {
+		var opts tOpts
+		_, err := flags.NewParser(&opts, flags.None).ParseArgs([]string{"-s", "https://example.com", "--password", "from-flag"})
+		require.NoError(t, err)
+		assert.Equal(t, "from-flag", opts.Password)
+	})
+}
+
+func TestOptsProgressFlag(t *testing.T) {
+	parse := func(t *testing.T, args ...string) (tOpts, error) {
+		var opts tOpts
+		_, err := flags.NewParser(&opts, flags.None).ParseArgs(append([]string{"-s", "https://example.com"}, args...))
+		return opts, err
+	}
+
+	opts, err := parse(t)
+	require.NoError(t, err)
+	assert.Empty(t, opts.Progress, "Progress should be off by default")
+
+	opts, err = parse(t, "--progress")
+	require.NoError(t, err)
+	assert.Equal(t, crawler.ProgressAuto, opts.Progress, "Flag without a value should only report to a terminal")
+
+	opts, err = parse(t, "--progress=force")
+	require.NoError(t, err)
+	assert.Equal(t, crawler.ProgressForce, opts.Progress)
+
+	_, err = parse(t, "--progress=always")
+	assert.Error(t, err, "Unknown mode should be rejected")
+}
+
+func TestOptsTypeChoices(t *testing.T) {
+	parse := func(args ...string) (tOpts, error) {
+		var opts tOpts
+		_, err := newParser(&opts, flags.None).ParseArgs(append([]string{"-s", "https://example.com"}, args...))
+		return opts, err
+	}
+
+	opts, err := parse("-t", "pdf", "-t", "ppt")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pdf", "ppt"}, opts.Type)
+
+	_, err = parse("-t", "exe")
+	assert.Error(t, err, "Unregistered type should be rejected")
+
+	// Researchers registered before the parser is created are offered as choices
+	require.NoError(t, researchers.Register("main-test", func(downloader *researchers.Downloader) researchers.Researcher { return nil }))
+	opts, err = parse("-t", "main-test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main-test"}, opts.Type)
+}
+
+func TestOptsSiteFlag(t *testing.T) {
+	var opts tOpts
+	_, err := flags.NewParser(&opts, flags.None).ParseArgs([]string{"-s", "https://example.com/docs/", "--site", "https://example.com/forms/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/docs/", "https://example.com/forms/"}, opts.Site, "Site should be repeatable")
+
+	_, err = flags.NewParser(&opts, flags.None).ParseArgs([]string{"-t", "pdf"})
+	assert.Error(t, err, "Site should be required")
+}
+
+func TestOptsConfig(t *testing.T) {
+	var opts tOpts
+	_, err := flags.NewParser(&opts, flags.None).ParseArgs([]string{"-s", "https://example.com", "-t", "pdf", "--depth", "2", "--exclude", "/private/"})
+	require.NoError(t, err)
+
+	// Every option is carried over to the crawler configuration
+	cfg := opts.config()
+	assert.Equal(t, []string{"https://example.com"}, cfg.Site)
+	assert.Equal(t, []string{"pdf"}, cfg.Type)
+	assert.Equal(t, 2, cfg.Depth)
+	assert.Equal(t, []string{"/private/"}, cfg.Exclude)
+	assert.Equal(t, 100, cfg.Paramax, "Defaults of the options should be carried over")
+	assert.Equal(t, crawler.LogText, cfg.LogFormat, "Defaults of the options should be carried over")
+}
+
+func TestOptsByContentTypeFlag(t *testing.T) {
+	var opts tOpts
+	_, err := flags.NewParser(&opts, flags.None).ParseArgs([]string{"-s", "https://example.com", "--by-content-type"})
+	require.NoError(t, err)
+	assert.True(t, opts.config().Sniff, "--by-content-type should enable sniffing as --sniff does")
+}
+
 // Note: Testing the main function directly is challenging because it calls os.Exit()
 // A more comprehensive test would involve capturing command line arguments and
 // redirecting them to the parser. That would be more of an integration test.
@@ -0,0 +1,111 @@
+package researchers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts of the ISO 8601 dates found in document metadata, tried in order
+// Dates without a time zone, as OpenDocument writes them, are read as UTC
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// normalizeDate converts a date of document metadata to RFC 3339
+// PDF dates (D:YYYYMMDDHHmmSSOHH'mm') are decoded by parsePdfDate
+// Returns the date unchanged and false if it is empty or its format is not recognized
+func normalizeDate(raw string) (string, bool) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return raw, false
+	}
+	if strings.HasPrefix(s, "D:") {
+		t, ok := parsePdfDate(s)
+		if !ok {
+			return raw, false
+		}
+		return t.Format(time.RFC3339), true
+	}
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t.Format(time.RFC3339), true
+		}
+	}
+	return raw, false
+}
+
+// normalizeDateField replaces the date with its RFC 3339 form, keeping the original in raw
+// when they differ; dates that cannot be parsed are left as they are
+func normalizeDateField(date *string, raw *string) {
+	normalized, ok := normalizeDate(*date)
+	if ok && normalized != *date {
+		*raw = *date
+		*date = normalized
+	}
+}
+
+// parsePdfDate decodes a PDF date, D:YYYYMMDDHHmmSSOHH'mm' (ISO 32000 7.9.4)
+// Every part after the year is optional, the apostrophes too; without a time zone,
+// or with Z whatever follows it, the date is UTC
+func parsePdfDate(s string) (time.Time, bool) {
+	s = strings.TrimPrefix(s, "D:")
+
+	// Year, month, day, hour, minute and second, with the defaults of the omitted ones
+	fields := [6]int{0, 1, 1, 0, 0, 0}
+	widths := [6]int{4, 2, 2, 2, 2, 2}
+	for i, width := range widths {
+		if s == "" || s[0] < '0' || s[0] > '9' {
+			if i == 0 {
+				return time.Time{}, false
+			}
+			break
+		}
+		if len(s) < width {
+			return time.Time{}, false
+		}
+		n, err := strconv.Atoi(s[:width])
+		if err != nil {
+			return time.Time{}, false
+		}
+		fields[i] = n
+		s = s[width:]
+	}
+
+	loc := time.UTC
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		offset := strings.ReplaceAll(s[1:], "'", "")
+		if len(offset) != 2 && len(offset) != 4 {
+			return time.Time{}, false
+		}
+		hours, err := strconv.Atoi(offset[:2])
+		if err != nil {
+			return time.Time{}, false
+		}
+		minutes := 0
+		if len(offset) == 4 {
+			minutes, err = strconv.Atoi(offset[2:])
+			if err != nil {
+				return time.Time{}, false
+			}
+		}
+		seconds := (hours*60 + minutes) * 60
+		if s[0] == '-' {
+			seconds = -seconds
+		}
+		loc = time.FixedZone("", seconds)
+	} else if s != "" && s[0] != 'Z' {
+		return time.Time{}, false
+	}
+
+	t := time.Date(fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5], 0, loc)
+	// Out of range values would otherwise be normalized into another date
+	if int(t.Month()) != fields[1] || t.Day() != fields[2] || t.Hour() != fields[3] || t.Minute() != fields[4] || t.Second() != fields[5] {
+		return time.Time{}, false
+	}
+	return t, true
+}
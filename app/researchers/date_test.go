@@ -0,0 +1,56 @@
+package researchers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeDate(t *testing.T) {
+	testCases := []struct {
+		raw        string
+		normalized string
+		ok         bool
+	}{
+		{raw: "D:20240102030405Z", normalized: "2024-01-02T03:04:05Z", ok: true},
+		{raw: "D:20240102030405Z00'00'", normalized: "2024-01-02T03:04:05Z", ok: true},
+		{raw: "D:20240102030405+02'00'", normalized: "2024-01-02T03:04:05+02:00", ok: true},
+		{raw: "D:20240102030405-05'30", normalized: "2024-01-02T03:04:05-05:30", ok: true},
+		{raw: "D:20240102", normalized: "2024-01-02T00:00:00Z", ok: true},
+		{raw: "D:2024", normalized: "2024-01-01T00:00:00Z", ok: true},
+		{raw: "2023-01-02T11:00:00Z", normalized: "2023-01-02T11:00:00Z", ok: true},
+		{raw: "2023-01-02T11:00:00.123+03:00", normalized: "2023-01-02T11:00:00+03:00", ok: true},
+		{raw: "2023-01-02T11:00:00.5", normalized: "2023-01-02T11:00:00Z", ok: true},
+		{raw: "2023-01-02", normalized: "2023-01-02T00:00:00Z", ok: true},
+		{raw: "", normalized: "", ok: false},
+		{raw: "D:20240102030405+0200", normalized: "2024-01-02T03:04:05+02:00", ok: true},
+		{raw: "D:garbage", normalized: "D:garbage", ok: false},
+		{raw: "D:20241302", normalized: "D:20241302", ok: false},
+		{raw: "D:2024010203040", normalized: "D:2024010203040", ok: false},
+		{raw: "D:20240102030405+2", normalized: "D:20240102030405+2", ok: false},
+		{raw: "last Tuesday", normalized: "last Tuesday", ok: false},
+	}
+
+	for _, tc := range testCases {
+		normalized, ok := normalizeDate(tc.raw)
+		assert.Equal(t, tc.ok, ok, tc.raw)
+		assert.Equal(t, tc.normalized, normalized, tc.raw)
+	}
+}
+
+func TestNormalizeDateField(t *testing.T) {
+	date, raw := "D:20240102030405Z", ""
+	normalizeDateField(&date, &raw)
+	assert.Equal(t, "2024-01-02T03:04:05Z", date)
+	assert.Equal(t, "D:20240102030405Z", raw, "Original should be kept")
+
+	date, raw = "2024-01-02T03:04:05Z", ""
+	normalizeDateField(&date, &raw)
+	assert.Equal(t, "2024-01-02T03:04:05Z", date)
+	assert.Empty(t, raw, "Original in RFC 3339 should not be repeated")
+
+	date, raw = "unknown", ""
+	normalizeDateField(&date, &raw)
+	assert.Equal(t, "unknown", date, "Unparsable date should be left as is")
+	assert.Empty(t, raw)
+}
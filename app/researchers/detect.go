@@ -0,0 +1,245 @@
+package researchers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// Signatures at the start of the supported document files
+var (
+	pdfMagic = []byte("%PDF")
+	zipMagic = []byte("PK\x03\x04")
+	oleMagic = []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")
+)
+
+// Number of bytes at the start of a file examined by sniffType
+const sniffSize = 4096
+
+// Map of OLE compound file streams to the legacy Office file types they identify
+var oleStreams = map[string]string{
+	"WordDocument":        "doc",
+	"Workbook":            "xls",
+	"Book":                "xls",
+	"PowerPoint Document": "ppt",
+}
+
+// Map of top-level OOXML part folders to the file types they identify
+var ooxmlFolders = map[string]string{
+	"word/": "docx",
+	"xl/":   "xlsx",
+	"ppt/":  "pptx",
+}
+
+// Detect recognizes the document type by the content of the file rather than its name
+// Returns the file type, or "" if the content is not a supported document
+// The reader is positioned at its start on return
+func Detect(r io.ReadSeeker) (string, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", err
+	}
+	defer r.Seek(0, io.SeekStart)
+
+	head := make([]byte, sniffSize)
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	n, _ := io.ReadFull(r, head)
+	head = head[:n]
+
+	// The start of the file is usually enough, the structure is only read when it is not
+	if st := sniffType(head); st != "" {
+		return st, nil
+	}
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		return detectZip(&readSeekerAt{r}, size), nil
+	case bytes.HasPrefix(head, oleMagic):
+		return detectOle(&readSeekerAt{r}), nil
+	}
+	return "", nil
+}
+
+// sniffType recognizes the document type by the bytes at the start of the file alone:
+// the PDF signature, the mimetype entry OpenDocument files start with, or the names
+// of the OOXML parts in the ZIP local file headers
+// Returns "" if the start of the file is not enough to tell, as for legacy Office files
+func sniffType(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, pdfMagic):
+		return "pdf"
+	case !bytes.HasPrefix(head, zipMagic):
+		return ""
+	}
+
+	names, mimeType := zipEntryNames(head)
+	if len(names) > 0 && names[0] == "mimetype" {
+		st, _ := ByMimeType(strings.TrimSpace(mimeType))
+		return st
+	}
+	if !slices.Contains(names, "[Content_Types].xml") {
+		return ""
+	}
+	for _, name := range names {
+		for prefix, st := range ooxmlFolders {
+			if strings.HasPrefix(name, prefix) {
+				return st
+			}
+		}
+	}
+	return ""
+}
+
+// zipEntryNames returns the names of the ZIP local file headers found in the head of a file,
+// and the content of the first entry when it is stored uncompressed (the OpenDocument mimetype)
+func zipEntryNames(head []byte) (names []string, firstStored string) {
+	const headerSize = 30 // Fixed part of a local file header
+	for off := 0; off+headerSize <= len(head); {
+		i := bytes.Index(head[off:], zipMagic)
+		if i < 0 || off+i+headerSize > len(head) {
+			break
+		}
+		h := head[off+i:]
+		method := binary.LittleEndian.Uint16(h[8:])
+		size := int(binary.LittleEndian.Uint32(h[18:]))
+		nameLen := int(binary.LittleEndian.Uint16(h[26:]))
+		extraLen := int(binary.LittleEndian.Uint16(h[28:]))
+		if headerSize+nameLen > len(h) {
+			break
+		}
+		names = append(names, string(h[headerSize:headerSize+nameLen]))
+		if len(names) == 1 && method == zip.Store {
+			start := headerSize + nameLen + extraLen
+			// Without its size in the header (followed by a data descriptor),
+			// the content runs up to the next signature
+			if size == 0 && start <= len(h) {
+				size = bytes.Index(h[start:], []byte("PK"))
+			}
+			if size > 0 && start+size <= len(h) {
+				firstStored = string(h[start : start+size])
+			}
+		}
+		off += i + headerSize + nameLen
+	}
+	return names, firstStored
+}
+
+// detectZip recognizes OOXML documents by [Content_Types].xml and their part folders,
+// and OpenDocument files by their mimetype entry
+func detectZip(r io.ReaderAt, size int64) string {
+	rZip, err := zip.NewReader(r, size)
+	if err != nil {
+		return ""
+	}
+
+	contentTypes := false
+	folder := ""
+	for _, fInZip := range rZip.File {
+		switch {
+		case fInZip.Name == "[Content_Types].xml":
+			contentTypes = true
+		case fInZip.Name == "mimetype":
+			rc, err := fInZip.Open()
+			if err != nil {
+				return ""
+			}
+			data, err := io.ReadAll(io.LimitReader(rc, 256))
+			rc.Close()
+			if err != nil {
+				return ""
+			}
+			st, _ := ByMimeType(strings.TrimSpace(string(data)))
+			return st
+		default:
+			for prefix, st := range ooxmlFolders {
+				if strings.HasPrefix(fInZip.Name, prefix) {
+					folder = st
+				}
+			}
+		}
+	}
+
+	if contentTypes {
+		return folder
+	}
+	return ""
+}
+
+// detectOle recognizes legacy Office documents by the main stream of the compound file
+func detectOle(r io.ReaderAt) string {
+	doc, err := mscfb.New(r)
+	if err != nil {
+		return ""
+	}
+	for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+		if st, ok := oleStreams[entry.Name]; ok {
+			return st
+		}
+	}
+	return ""
+}
+
+// readSeekerAt adapts an io.ReadSeeker to io.ReaderAt for sequential use by zip.Reader
+type readSeekerAt struct {
+	r io.ReadSeeker
+}
+
+// ReadAt reads len(p) bytes starting at offset off
+func (rsa *readSeekerAt) ReadAt(p []byte, off int64) (int, error) {
+	_, err := rsa.r.Seek(off, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(rsa.r, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// DetectType downloads the document at the given URL and recognizes its type by content
+// When the type is one of the accepted types, the downloaded file is kept and handed to the
+// next download of the same URL, so the researcher that follows does not fetch it again
+// Returns "" if the content is not an accepted document
+func (d *Downloader) DetectType(ctx context.Context, url string, accepted []string) (string, error) {
+	doc, cleanup, info, err := d.download(ctx, url)
+	if err != nil {
+		return "", err
+	}
+
+	st, err := Detect(doc)
+	if err != nil || !slices.Contains(accepted, st) {
+		cleanup()
+		return "", err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.prefetched == nil {
+		d.prefetched = make(map[string]tDownloaded)
+	}
+	d.prefetched[url] = tDownloaded{doc: doc, cleanup: cleanup, info: info}
+	return st, nil
+}
+
+// Discard releases the file downloaded by DetectType for the URL when no researcher is to use it,
+// removing its temporary file if one was used, and the answer to its HEAD request
+// URLs without a downloaded file or a HEAD request are ignored
+func (d *Downloader) Discard(url string) {
+	d.mu.Lock()
+	prefetched, ok := d.prefetched[url]
+	delete(d.prefetched, url)
+	delete(d.heads, url)
+	d.mu.Unlock()
+	if ok {
+		prefetched.cleanup()
+	}
+}
@@ -0,0 +1,153 @@
+package researchers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildZip creates an in-memory ZIP archive holding the named files with dummy content
+func buildZip(t *testing.T, names ...string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("<xml/>"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestDetect(t *testing.T) {
+	sampleDoc, err := os.ReadFile("testdata/sample.doc")
+	require.NoError(t, err)
+	// Renaming the WordDocument stream leaves a compound file of no known application
+	unknownOle := bytes.Replace(sampleDoc, []byte("W\x00o\x00r\x00d\x00D"), []byte("X\x00o\x00r\x00d\x00D"), 1)
+
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{name: "PDF", data: []byte("%PDF-1.7\n%binary"), expected: "pdf"},
+		{name: "DOCX", data: buildZip(t, "[Content_Types].xml", "_rels/.rels", "word/document.xml"), expected: "docx"},
+		{name: "XLSX", data: buildZip(t, "[Content_Types].xml", "xl/workbook.xml"), expected: "xlsx"},
+		{name: "PPTX", data: buildZip(t, "[Content_Types].xml", "ppt/presentation.xml"), expected: "pptx"},
+		{name: "ODT", data: buildOdf(t, testOdfMeta), expected: "odt"},
+		{name: "DOC", data: sampleDoc, expected: "doc"},
+		{name: "OLE without main stream", data: unknownOle, expected: ""},
+		{name: "Plain ZIP", data: buildZip(t, "readme.txt"), expected: ""},
+		{name: "OOXML without parts", data: buildZip(t, "[Content_Types].xml"), expected: ""},
+		{name: "HTML", data: []byte("<!DOCTYPE html><html></html>"), expected: ""},
+		{name: "Too short", data: []byte("PK"), expected: ""},
+		{name: "Empty", data: []byte{}, expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := bytes.NewReader(tc.data)
+			st, err := Detect(r)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, st)
+
+			pos, _ := r.Seek(0, io.SeekCurrent)
+			assert.Zero(t, pos, "Reader should be positioned at its start")
+		})
+	}
+}
+
+func TestSniffType(t *testing.T) {
+	// OpenDocument files start with their mimetype stored uncompressed
+	var odp bytes.Buffer
+	zw := zip.NewWriter(&odp)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	require.NoError(t, err)
+	_, err = w.Write([]byte("application/vnd.oasis.opendocument.presentation"))
+	require.NoError(t, err)
+	_, err = zw.Create("content.xml")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	sampleDoc, err := os.ReadFile("testdata/sample.doc")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		head     []byte
+		expected string
+	}{
+		{name: "PDF", head: []byte("%PDF-1.4\n"), expected: "pdf"},
+		{name: "DOCX", head: buildZip(t, "[Content_Types].xml", "_rels/.rels", "word/document.xml"), expected: "docx"},
+		{name: "XLSX", head: buildZip(t, "[Content_Types].xml", "xl/workbook.xml"), expected: "xlsx"},
+		{name: "PPTX parts first", head: buildZip(t, "ppt/presentation.xml", "[Content_Types].xml"), expected: "pptx"},
+		{name: "ODP", head: odp.Bytes(), expected: "odp"},
+		{name: "Compressed mimetype", head: buildOdf(t, testOdfMeta), expected: ""},
+		{name: "OOXML cut before the parts", head: buildZip(t, "[Content_Types].xml", "word/document.xml")[:60], expected: ""},
+		{name: "Parts without content types", head: buildZip(t, "word/document.xml"), expected: ""},
+		{name: "DOC", head: sampleDoc, expected: ""},
+		{name: "HTML", head: []byte("<html>"), expected: ""},
+		{name: "Empty", head: nil, expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, sniffType(tc.head))
+		})
+	}
+}
+
+func TestDownloaderDetectType(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			requests.Add(1)
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("%PDF-1.4 mock"))
+	}))
+	defer ts.Close()
+
+	t.Run("Accepted type is kept for the researcher", func(t *testing.T) {
+		requests.Store(0)
+		downloader := defaultDownloader()
+
+		st, err := downloader.DetectType(context.Background(), ts.URL+"/blob", []string{"pdf", "docx"})
+		require.NoError(t, err)
+		assert.Equal(t, "pdf", st)
+
+		doc, cleanup, _, err := downloader.download(context.Background(), ts.URL+"/blob")
+		require.NoError(t, err)
+		defer cleanup()
+
+		data, err := io.ReadAll(doc)
+		require.NoError(t, err)
+		assert.Equal(t, "%PDF-1.4 mock", string(data))
+		assert.Equal(t, int32(1), requests.Load(), "Detected document should not be downloaded twice")
+	})
+
+	t.Run("Other types are discarded", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		t.Setenv("TMPDIR", tmpDir)
+		downloader := defaultDownloader()
+		downloader.MaxMemory = 0
+
+		st, err := downloader.DetectType(context.Background(), ts.URL+"/blob", []string{"docx"})
+		require.NoError(t, err)
+		assert.Empty(t, st)
+
+		entries, err := os.ReadDir(tmpDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "Discarded download should not be left in the temp directory")
+	})
+}
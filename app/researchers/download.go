@@ -0,0 +1,249 @@
+package researchers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"docscrawler/app/fetch"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// tHttpInfo holds the HTTP response headers describing a downloaded file
+// Unlike the dates embedded in a document, these reflect the file as served
+type tHttpInfo struct {
+	LastModified  string     `json:"http_last_modified,omitempty"`  // Last-Modified header, RFC 3339 when parseable
+	ContentLength int64      `json:"http_content_length,omitempty"` // Content-Length header in bytes
+	FileSize      int64      `json:"file_size,omitempty"`           // Size of the content received in bytes, whatever the headers say
+	ContentHash   string     `json:"content_hash,omitempty"`        // Hex SHA-256 of the downloaded content
+	validators    Validators // ETag and Last-Modified headers as sent
+}
+
+// Hash returns the hex SHA-256 of the downloaded content, "" if nothing was downloaded
+func (info tHttpInfo) Hash() string {
+	return info.ContentHash
+}
+
+// Validators returns the validators the server sent with the document, empty if none
+func (info tHttpInfo) Validators() Validators {
+	return info.validators
+}
+
+// File returns the size and the Last-Modified header of the downloaded file
+func (info tHttpInfo) File() FileInfo {
+	return FileInfo{Size: info.FileSize, LastModified: info.LastModified}
+}
+
+// newHttpInfo extracts the file information from the response headers
+// Absent headers leave their fields empty
+func newHttpInfo(resp *http.Response) tHttpInfo {
+	var info tHttpInfo
+	info.validators = Validators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	if lm := resp.Header.Get("Last-Modified"); lm != "" {
+		info.LastModified = lm
+		if t, err := http.ParseTime(lm); err == nil {
+			info.LastModified = t.UTC().Format(time.RFC3339)
+		}
+	}
+	if resp.ContentLength > 0 {
+		info.ContentLength = resp.ContentLength
+	}
+	return info
+}
+
+// tDocReader is a downloaded document, held in memory or in a temporary file
+// Both allow random access, which the ZIP and OLE2 readers need
+type tDocReader interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+// tHead is the answer to the HEAD request of a document, or the error making it
+type tHead struct {
+	resp *http.Response // Response, its body closed
+	err  error
+}
+
+// tDownloaded is a document downloaded by DetectType, awaiting its researcher
+type tDownloaded struct {
+	doc     tDocReader
+	cleanup func()
+	info    tHttpInfo
+}
+
+// Downloader fetches documents for the researchers
+type Downloader struct {
+	Client      *fetch.Client // HTTP client used for downloads
+	MaxFileSize int64         // Maximum document size in bytes (unlimited if zero)
+	TempDir     string        // Directory of the temporary files of the downloads (OS temp directory if empty)
+	MaxMemory   int64         // Size in bytes under which downloads are held in memory instead of a temporary file
+	Ranges      bool          // Read larger OOXML documents by HTTP range requests where the server supports them
+
+	// Validators of the documents analysed in an earlier run, by URL, not to be modified during downloads
+	// Their download is conditional, failing with ErrNotModified when the server reports them unchanged
+	Prior map[string]Validators
+
+	mu         sync.Mutex             // Protects prefetched and heads
+	prefetched map[string]tDownloaded // Files downloaded by DetectType, awaiting their researcher
+	heads      map[string]tHead       // Answers to the HEAD requests of the documents not yet downloaded
+}
+
+// NewDownloader creates a downloader using the given client and the default size limits
+func NewDownloader(client *fetch.Client) *Downloader {
+	return &Downloader{
+		Client:      client,
+		MaxFileSize: maxFileSize,
+		MaxMemory:   maxMemorySize,
+	}
+}
+
+// defaultDownloader returns the downloader of researchers created without one
+func defaultDownloader() *Downloader {
+	return NewDownloader(NewClient(fetch.Options{}))
+}
+
+// download fetches the document at the given URL into memory or a temporary file, positioned at its start
+// Also returns the file information from the response headers
+// Caller is responsible for calling the cleanup function when finished with the document
+func (d *Downloader) download(ctx context.Context, url string) (tDocReader, func(), tHttpInfo, error) {
+	// A document already fetched for type detection is used once instead of downloading again
+	// Once downloaded, the document needs no HEAD request any more
+	d.mu.Lock()
+	prefetched, ok := d.prefetched[url]
+	delete(d.prefetched, url)
+	d.mu.Unlock()
+	defer d.forgetHead(url)
+	if ok {
+		return prefetched.doc, prefetched.cleanup, prefetched.info, nil
+	}
+
+	// The size of a document that may not be downloaded at all is left to the download to check
+	prior, conditional := d.Prior[url]
+	if !conditional {
+		err := d.checkSize(ctx, url)
+		if err != nil {
+			return nil, nil, tHttpInfo{}, err
+		}
+	}
+
+	resp, err := d.Client.GetConditional(ctx, url, prior.ETag, prior.LastModified)
+	if err != nil {
+		return nil, nil, tHttpInfo{}, err
+	}
+	defer resp.Body.Close()
+	if conditional && resp.StatusCode == http.StatusNotModified {
+		return nil, nil, tHttpInfo{}, ErrNotModified
+	}
+	if resp.StatusCode != http.StatusOK { // Check for 200 OK status
+		// Can read response body for more detailed error if needed
+		return nil, nil, tHttpInfo{}, fmt.Errorf("failed to download file: status code %d", resp.StatusCode)
+	}
+
+	// Convert response body to a ReadSeeker for document operations
+	doc, hash, cleanup, err := d.toReadSeeker(resp.Body)
+	if err != nil {
+		return nil, nil, tHttpInfo{}, err
+	}
+	info := newHttpInfo(resp)
+	info.ContentHash = hash
+
+	// The document is measured by seeking to its end, whether held in memory or in a file
+	info.FileSize, err = doc.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = doc.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, tHttpInfo{}, err
+	}
+	return doc, cleanup, info, nil
+}
+
+// checkSize rejects a document whose size, as announced in response to a HEAD request,
+// exceeds MaxFileSize, sparing its download
+// Servers that do not answer HEAD requests or do not tell the size leave the check to the download
+func (d *Downloader) checkSize(ctx context.Context, url string) error {
+	if d.MaxFileSize <= 0 {
+		return nil
+	}
+	resp, err := d.Head(ctx, url)
+	if err != nil {
+		return nil
+	}
+	if resp.StatusCode == http.StatusOK && resp.ContentLength > d.MaxFileSize {
+		return tooLarge(d.MaxFileSize)
+	}
+	return nil
+}
+
+// Head returns the answer to a HEAD request for the document at the URL, its body closed
+// The request is made once per document, by whichever of the type detection, the size check
+// and the range probe asks first; the answer is kept until the document is downloaded,
+// read by range requests or discarded
+func (d *Downloader) Head(ctx context.Context, url string) (*http.Response, error) {
+	d.mu.Lock()
+	head, ok := d.heads[url]
+	d.mu.Unlock()
+	if ok {
+		return head.resp, head.err
+	}
+
+	resp, err := d.Client.Head(ctx, url)
+	if err == nil {
+		resp.Body.Close()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.heads == nil {
+		d.heads = make(map[string]tHead)
+	}
+	d.heads[url] = tHead{resp: resp, err: err}
+	return resp, err
+}
+
+// forgetHead drops the answer to the HEAD request of the document at the URL
+func (d *Downloader) forgetHead(url string) {
+	d.mu.Lock()
+	delete(d.heads, url)
+	d.mu.Unlock()
+}
+
+// toReadSeeker reads a document smaller than MaxMemory into memory, and a larger one into
+// a temporary file, saving the file operations for the common case of small documents
+// Returns the document positioned at its start, the hex SHA-256 of its content and the function
+// releasing it, which removes the temporary file if one was used
+func (d *Downloader) toReadSeeker(rc io.ReadCloser) (tDocReader, string, func(), error) {
+	var head []byte
+	if d.MaxMemory > 0 {
+		// One byte past the limit tells whether the document fits in memory
+		var err error
+		head, err = io.ReadAll(io.LimitReader(rc, d.MaxMemory+1))
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if int64(len(head)) <= d.MaxMemory {
+			if d.MaxFileSize > 0 && int64(len(head)) > d.MaxFileSize {
+				return nil, "", nil, tooLarge(d.MaxFileSize)
+			}
+			hash := sha256.Sum256(head)
+			return bytes.NewReader(head), hex.EncodeToString(hash[:]), func() {}, nil
+		}
+	}
+
+	// The bytes already read are written to the file ahead of the rest of the body
+	src := io.NopCloser(io.MultiReader(bytes.NewReader(head), rc))
+	file, hash, err := readCloserToReadSeekerFile(src, d.TempDir, d.MaxFileSize)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	cleanup := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	return file, hash, cleanup, nil
+}
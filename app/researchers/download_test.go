@@ -0,0 +1,193 @@
+package researchers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloader(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.pdf" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("0123456789"))
+	}))
+	defer ts.Close()
+
+	t.Run("Successful download", func(t *testing.T) {
+		downloader := defaultDownloader()
+
+		doc, cleanup, _, err := downloader.download(context.Background(), ts.URL+"/doc.pdf")
+		require.NoError(t, err)
+		defer cleanup()
+
+		data, err := io.ReadAll(doc)
+		require.NoError(t, err)
+		assert.Equal(t, "0123456789", string(data), "Document should be positioned at its start")
+	})
+
+	t.Run("Small document in memory", func(t *testing.T) {
+		downloader := defaultDownloader()
+		downloader.TempDir = t.TempDir()
+
+		doc, cleanup, info, err := downloader.download(context.Background(), ts.URL+"/doc.pdf")
+		require.NoError(t, err)
+		defer cleanup()
+		assert.IsType(t, &bytes.Reader{}, doc)
+		assert.Equal(t, "84d89877f0d4041efb6bf91a16f0248f2fd573e6af05c19f96bedb9f882f7882", info.ContentHash)
+
+		entries, err := os.ReadDir(downloader.TempDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "No temporary file should be created")
+	})
+
+	t.Run("Large document in a temporary file", func(t *testing.T) {
+		downloader := defaultDownloader()
+		downloader.TempDir = t.TempDir()
+		downloader.MaxMemory = 4
+
+		doc, cleanup, info, err := downloader.download(context.Background(), ts.URL+"/doc.pdf")
+		require.NoError(t, err)
+		require.IsType(t, &os.File{}, doc)
+		assert.Equal(t, "84d89877f0d4041efb6bf91a16f0248f2fd573e6af05c19f96bedb9f882f7882", info.ContentHash,
+			"Hash should cover the bytes read before switching to the file")
+
+		data, err := io.ReadAll(doc)
+		require.NoError(t, err)
+		assert.Equal(t, "0123456789", string(data), "Bytes read before switching to the file should be kept")
+
+		cleanup()
+		entries, err := os.ReadDir(downloader.TempDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "Cleanup should remove the temporary file")
+	})
+
+	t.Run("HTTP error", func(t *testing.T) {
+		downloader := defaultDownloader()
+
+		_, _, _, err := downloader.download(context.Background(), ts.URL+"/missing.pdf")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to download file: status code 404")
+	})
+
+	t.Run("Size announced by the server", func(t *testing.T) {
+		var gets int
+		sizeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				gets++
+			}
+			if r.URL.Path == "/unsized.pdf" {
+				w.(http.Flusher).Flush() // Chunked response, without Content-Length
+			}
+			w.Write([]byte("0123456789"))
+		}))
+		defer sizeServer.Close()
+
+		downloader := defaultDownloader()
+		downloader.MaxFileSize = 5
+
+		_, _, _, err := downloader.download(context.Background(), sizeServer.URL+"/doc.pdf")
+		assert.ErrorIs(t, err, ErrTooLarge)
+		assert.Zero(t, gets, "Oversized document should be rejected before its download")
+
+		_, _, _, err = downloader.download(context.Background(), sizeServer.URL+"/unsized.pdf")
+		assert.ErrorIs(t, err, ErrTooLarge, "Size should still be checked while downloading")
+		assert.Equal(t, 1, gets)
+	})
+
+	t.Run("Size limit", func(t *testing.T) {
+		// Temporary files go to an empty directory, so leftovers are easy to spot
+		tmpDir := t.TempDir()
+		t.Setenv("TMPDIR", tmpDir)
+
+		downloader := defaultDownloader()
+		downloader.MaxFileSize = 5
+
+		_, _, _, err := downloader.download(context.Background(), ts.URL+"/doc.pdf")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeds maximum allowed size")
+
+		entries, err := os.ReadDir(tmpDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "Oversized file should not be left in the temp directory")
+	})
+}
+
+func TestDownloaderConditional(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		if r.Header.Get("If-None-Match") == `"v2"` || r.Header.Get("If-Modified-Since") == "Wed, 21 Oct 2015 07:28:00 GMT" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("0123456789"))
+	}))
+	defer ts.Close()
+
+	downloader := defaultDownloader()
+	downloader.Prior = map[string]Validators{
+		ts.URL + "/same-etag.pdf": {ETag: `"v2"`},
+		ts.URL + "/same-date.pdf": {LastModified: "Wed, 21 Oct 2015 07:28:00 GMT"},
+		ts.URL + "/changed.pdf":   {ETag: `"v1"`},
+	}
+
+	for _, name := range []string{"same-etag.pdf", "same-date.pdf"} {
+		_, _, _, err := downloader.download(context.Background(), ts.URL+"/"+name)
+		assert.ErrorIs(t, err, ErrNotModified, name)
+	}
+
+	for _, name := range []string{"changed.pdf", "new.pdf"} {
+		doc, cleanup, info, err := downloader.download(context.Background(), ts.URL+"/"+name)
+		require.NoError(t, err, name)
+		cleanup()
+		assert.NotNil(t, doc)
+		assert.Equal(t, Validators{ETag: `"v2"`, LastModified: "Wed, 21 Oct 2015 07:28:00 GMT"}, info.Validators(),
+			"Validators should be kept for the next run")
+	}
+}
+
+func TestNewHttpInfo(t *testing.T) {
+	testCases := []struct {
+		name     string
+		header   http.Header
+		length   int64
+		expected tHttpInfo
+	}{
+		{
+			name:   "Both headers",
+			header: http.Header{"Last-Modified": {"Wed, 21 Oct 2015 07:28:00 GMT"}, "Etag": {`"v1"`}},
+			length: 1024,
+			expected: tHttpInfo{LastModified: "2015-10-21T07:28:00Z", ContentLength: 1024,
+				validators: Validators{ETag: `"v1"`, LastModified: "Wed, 21 Oct 2015 07:28:00 GMT"}},
+		},
+		{
+			name:     "Unparseable date is kept as is",
+			header:   http.Header{"Last-Modified": {"yesterday"}},
+			length:   1024,
+			expected: tHttpInfo{LastModified: "yesterday", ContentLength: 1024, validators: Validators{LastModified: "yesterday"}},
+		},
+		{
+			name:     "No headers",
+			header:   http.Header{},
+			length:   -1,
+			expected: tHttpInfo{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{Header: tc.header, ContentLength: tc.length}
+			assert.Equal(t, tc.expected, newHttpInfo(resp))
+		})
+	}
+}
@@ -2,13 +2,12 @@ package researchers
 
 import (
 	"archive/zip"
+	"context"
 	"encoding/json"
 	"encoding/xml"
-	"fmt"
 	"io"
-	"net/http"
-	"os"
-	"time"
+	"slices"
+	"strings"
 )
 
 // tCoreProperty represents core document properties from Office Open XML format
@@ -21,7 +20,14 @@ type tCoreProperty struct {
 	Revision       string   `xml:"revision" json:"revision,omitempty"`
 	Created        string   `xml:"created" json:"created,omitempty"`
 	Modified       string   `xml:"modified" json:"modified,omitempty"`
+	CreatedRaw     string   `xml:"-" json:"created_raw,omitempty"`  // Creation date as written, if not RFC 3339
+	ModifiedRaw    string   `xml:"-" json:"modified_raw,omitempty"` // Modification date as written, if not RFC 3339
 	Language       string   `xml:"language" json:"language,omitempty"`
+	Subject        string   `xml:"subject" json:"subject,omitempty"`
+	Description    string   `xml:"description" json:"description,omitempty"`
+	Keywords       string   `xml:"keywords" json:"keywords,omitempty"`
+	Category       string   `xml:"category" json:"category,omitempty"`
+	ContentStatus  string   `xml:"contentStatus" json:"contentStatus,omitempty"`
 }
 
 // tAppProperty represents application-specific properties from Office Open XML format
@@ -41,18 +47,97 @@ type tAppProperty struct {
 	AppVersion  string   `xml:"AppVersion" json:"app_version,omitempty"`
 }
 
+// tCustomProperties represents user-defined document properties from Office Open XML format
+// Found in docProps/custom.xml inside Office documents
+type tCustomProperties struct {
+	XMLName    xml.Name `xml:"Properties"`
+	Properties []struct {
+		Name  string `xml:"name,attr"`
+		Value struct {
+			Text string `xml:",chardata"` // Value of any variant type (vt:lpwstr, vt:bool, vt:filetime, ...)
+		} `xml:",any"`
+	} `xml:"property"`
+}
+
+// tContentTypes lists the content types of the parts of an Office Open XML package
+// Found in [Content_Types].xml at the root of Office documents
+type tContentTypes struct {
+	XMLName   xml.Name `xml:"Types"`
+	Overrides []struct {
+		PartName    string `xml:"PartName,attr"`
+		ContentType string `xml:"ContentType,attr"`
+	} `xml:"Override"`
+}
+
+// Map of the content types of the main document part to the container subtypes they identify,
+// telling macro-enabled documents and templates from plain ones
+var msoxFormats = map[string]string{
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml":   "docx",
+	"application/vnd.ms-word.document.macroEnabled.main+xml":                             "docm",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.template.main+xml":   "dotx",
+	"application/vnd.ms-word.template.macroEnabledTemplate.main+xml":                     "dotm",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml":         "xlsx",
+	"application/vnd.ms-excel.sheet.macroEnabled.main+xml":                               "xlsm",
+	"application/vnd.ms-excel.sheet.binary.macroEnabled.main":                            "xlsb",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.template.main+xml":      "xltx",
+	"application/vnd.ms-excel.template.macroEnabled.main+xml":                            "xltm",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation.main+xml": "pptx",
+	"application/vnd.ms-powerpoint.presentation.macroEnabled.main+xml":                   "pptm",
+	"application/vnd.openxmlformats-officedocument.presentationml.template.main+xml":     "potx",
+	"application/vnd.ms-powerpoint.template.macroEnabled.main+xml":                       "potm",
+	"application/vnd.openxmlformats-officedocument.presentationml.slideshow.main+xml":    "ppsx",
+	"application/vnd.ms-powerpoint.slideshow.macroEnabled.main+xml":                      "ppsm",
+}
+
+// Names of the VBA project parts holding the macros of Office documents
+var vbaProjects = []string{"word/vbaProject.bin", "xl/vbaProject.bin", "ppt/vbaProject.bin"}
+
 // tMsox is a researcher for Microsoft Office Open XML files (docx, xlsx, pptx)
 // Extracts metadata from the Office documents
 type tMsox struct {
-	docType      string
-	Url          string `json:"url,omitempty"`
-	CoreProperty tCoreProperty
-	AppProperty  tAppProperty
+	downloader     *Downloader
+	Url            string `json:"url,omitempty"`
+	DocType        string `json:"doc_type,omitempty"`
+	Format         string `json:"format,omitempty"` // Container subtype by the main part content type, e.g. docm for a macro-enabled docx
+	HasMacros      bool   `json:"has_macros"`       // Set if the document contains a VBA project
+	CoreProperty   tCoreProperty
+	AppProperty    tAppProperty
+	CustomProperty map[string]string `json:"CustomProperty,omitempty"` // Custom properties by name, values as text
+	tHttpInfo                        // File information from the HTTP response headers
+}
+
+// newMsox creates a new Microsoft Office document researcher for the given file type (docx, xlsx or pptx)
+// Documents are fetched by the given downloader (a default downloader if nil)
+func newMsox(docType string, downloader *Downloader) *tMsox {
+	if downloader == nil {
+		downloader = defaultDownloader()
+	}
+	return &tMsox{downloader: downloader, DocType: docType}
+}
+
+// Type returns the file type the researcher was created for
+func (msox *tMsox) Type() string {
+	return msox.DocType
+}
+
+// DocTitle returns the title of the document
+func (msox *tMsox) DocTitle() string {
+	return msox.CoreProperty.Title
+}
+
+// DocAuthor returns the author of the document
+func (msox *tMsox) DocAuthor() string {
+	return msox.CoreProperty.Creator
+}
+
+// DocCreated returns the creation date of the document
+func (msox *tMsox) DocCreated() string {
+	return msox.CoreProperty.Created
 }
 
-// newMsox creates a new Microsoft Office document researcher
-func newMsox() *tMsox {
-	return new(tMsox)
+// DocModified returns the last modification date of the document
+func (msox *tMsox) DocModified() string {
+	return msox.CoreProperty.Modified
 }
 
 // OutJSON serializes the MSOX metadata to JSON and writes it to the provided writer
@@ -67,76 +152,103 @@ func (msox *tMsox) OutJSON(writer io.Writer) error {
 
 // Do performs the analysis of a Microsoft Office document at the given URL
 // Downloads the file, extracts metadata from core.xml and app.xml, and stores it
-func (msox *tMsox) Do(url string) error {
-	msox.docType = "msox"
+func (msox *tMsox) Do(ctx context.Context, url string) error {
 	msox.Url = url
 
-	// Initialize HTTP client with timeout
-	client := http.Client{
-		Timeout: httpGetTimeout * time.Second,
-	}
-	resp, err := client.Get(url)
+	// Only the central directory and the property entries of a large archive are needed,
+	// the server may let them be read without downloading the rest
+	remote, httpInfo, ok, err := msox.downloader.openRemote(ctx, url)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK { // Check for 200 OK status
-		// Can read response body for more detailed error if needed
-		return fmt.Errorf("failed to download file: status code %d", resp.StatusCode)
+	if ok {
+		msox.tHttpInfo = httpInfo
+		rZip, err := zip.NewReader(remote, remote.size)
+		if err != nil {
+			return err
+		}
+		return msox.parse(rZip)
 	}
 
-	// Convert response body to a ReadSeeker for zip operations
-	respReadSeeker, err := readCloserToReadSeekerFile(resp.Body)
+	// Download the document to a ReadSeeker for zip operations
+	doc, cleanup, httpInfo, err := msox.downloader.download(ctx, url)
 	if err != nil {
 		return err
 	}
-
-	// Get temporary file name
-	tmpFileName := respReadSeeker.Name()
+	msox.tHttpInfo = httpInfo
+	defer cleanup()
 
 	// Open ZIP archive (Office documents are ZIP archives)
-	rZip, err := zip.OpenReader(tmpFileName)
+	rZip, err := newZipReader(doc)
 	if err != nil {
 		return err
 	}
-	defer rZip.Close()
 
-	// Process files inside the ZIP archive
+	return msox.parse(rZip)
+}
+
+// parse extracts the metadata from core.xml, app.xml and custom.xml of the archive,
+// the container subtype from [Content_Types].xml and the presence of macros from the entry names
+// Every entry is closed as soon as it is decoded
+func (msox *tMsox) parse(rZip *zip.Reader) error {
 	for _, fInZip := range rZip.File {
+		if slices.Contains(vbaProjects, fInZip.Name) {
+			msox.HasMacros = true
+		}
 		switch fInZip.Name {
-		case "docProps/core.xml":
-			rc1, err := fInZip.Open()
+		case "[Content_Types].xml":
+			var types tContentTypes
+			err := decodeZipEntry(fInZip, &types)
 			if err != nil {
 				return err
 			}
-			defer rc1.Close()
-			err = xml.NewDecoder(rc1).Decode(&msox.CoreProperty)
-			//rc.Close()
+			for _, override := range types.Overrides {
+				if format, ok := msoxFormats[override.ContentType]; ok {
+					msox.Format = format
+				}
+			}
+		case "docProps/core.xml":
+			err := decodeZipEntry(fInZip, &msox.CoreProperty)
 			if err != nil {
 				return err
 			}
 		case "docProps/app.xml":
-			rc2, err := fInZip.Open()
+			err := decodeZipEntry(fInZip, &msox.AppProperty)
 			if err != nil {
 				return err
 			}
-			defer rc2.Close()
-			err = xml.NewDecoder(rc2).Decode(&msox.AppProperty)
-			//rc.Close()
+		case "docProps/custom.xml":
+			var custom tCustomProperties
+			err := decodeZipEntry(fInZip, &custom)
 			if err != nil {
 				return err
 			}
-		default:
-			continue
+			msox.CustomProperty = make(map[string]string, len(custom.Properties))
+			for _, property := range custom.Properties {
+				msox.CustomProperty[property.Name] = strings.TrimSpace(property.Value.Text)
+			}
 		}
 	}
+	normalizeDateField(&msox.CoreProperty.Created, &msox.CoreProperty.CreatedRaw)
+	normalizeDateField(&msox.CoreProperty.Modified, &msox.CoreProperty.ModifiedRaw)
+	return nil
+}
 
-	// Clean up temporary file
-	respReadSeeker.Close()
-	err = os.Remove(tmpFileName)
+// newZipReader opens a downloaded document as a ZIP archive
+func newZipReader(doc tDocReader) (*zip.Reader, error) {
+	size, err := doc.Seek(0, io.SeekEnd)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return zip.NewReader(doc, size)
+}
 
-	return nil
+// decodeZipEntry decodes an XML entry of a ZIP archive into v and closes the entry
+func decodeZipEntry(fInZip *zip.File, v any) error {
+	rc, err := fInZip.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
 }
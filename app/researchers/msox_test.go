@@ -1,9 +1,14 @@
 package researchers
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"docscrawler/app/fetch"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -12,7 +17,7 @@ import (
 
 func TestMsoxResearcher(t *testing.T) {
 	t.Run("MSOX initialization", func(t *testing.T) {
-		msox := newMsox()
+		msox := newMsox("docx", nil)
 		assert.NotNil(t, msox, "MSOX researcher should be initialized")
 		assert.IsType(t, &tMsox{}, msox, "Should return correct type")
 		assert.Empty(t, msox.Url, "URL should be empty initially")
@@ -22,7 +27,7 @@ func TestMsoxResearcher(t *testing.T) {
 
 	t.Run("Output to JSON", func(t *testing.T) {
 		// Create MSOX researcher with test data
-		msox := newMsox()
+		msox := newMsox("docx", nil)
 		msox.Url = "https://example.com/test.docx"
 		msox.CoreProperty = tCoreProperty{
 			Title:          "Test Document",
@@ -70,8 +75,8 @@ func TestMsoxResearcher(t *testing.T) {
 		}))
 		defer ts.Close()
 
-		msox := newMsox()
-		err := msox.Do(ts.URL)
+		msox := newMsox("docx", nil)
+		err := msox.Do(context.Background(), ts.URL)
 		assert.Error(t, err, "Should return error for non-200 HTTP status")
 		assert.Contains(t, err.Error(), "failed to download file", "Error should indicate download failure")
 	})
@@ -79,9 +84,9 @@ func TestMsoxResearcher(t *testing.T) {
 	// Note: Complete MSOX parsing tests would require actual Office files
 	// Below is a mock test - in a real environment, consider using testdata with real files
 
-	t.Run("Do method sets URL and docType", func(t *testing.T) {
-		// This minimal test just verifies the URL and docType are set
-		msox := newMsox()
+	t.Run("Do method sets URL and DocType", func(t *testing.T) {
+		// This minimal test just verifies the URL and DocType are set
+		msox := newMsox("docx", nil)
 
 		// Mock server that returns invalid data (not a real Office file)
 		// This will cause errors in the ZIP parsing, but we can still check some basic setup
@@ -91,13 +96,337 @@ func TestMsoxResearcher(t *testing.T) {
 		}))
 		defer ts.Close()
 
-		// Call will fail due to invalid data, but URL and docType should be set
-		_ = msox.Do(ts.URL)
+		// Call will fail due to invalid data, but URL and DocType should be set
+		_ = msox.Do(context.Background(), ts.URL)
 		assert.Equal(t, ts.URL, msox.Url, "URL should be set even if processing fails")
-		assert.Equal(t, "msox", msox.docType, "Document type should be set to msox")
+		assert.Equal(t, "docx", msox.DocType, "Document type should be the file type")
 	})
 }
 
+// TestMsoxCoreAndApp checks an archive holding both property parts, each decoded and closed in turn
+func TestMsoxCoreAndApp(t *testing.T) {
+	data := buildDocx(t, map[string]string{
+		"docProps/core.xml": `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties"
+	xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Annual Plan</dc:title><dc:creator>Alice</dc:creator></cp:coreProperties>`,
+		"docProps/app.xml": `<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties">
+	<Application>Microsoft Office Word</Application><Pages>3</Pages><Words>420</Words></Properties>`,
+	})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer ts.Close()
+
+	for _, maxMemory := range []int64{maxMemorySize, 0} {
+		downloader := NewDownloader(NewClient(fetch.Options{}))
+		downloader.TempDir = t.TempDir()
+		downloader.MaxMemory = maxMemory
+
+		msox := newMsox("docx", downloader)
+		require.NoError(t, msox.Do(context.Background(), ts.URL))
+		assert.Equal(t, "Annual Plan", msox.CoreProperty.Title)
+		assert.Equal(t, "Alice", msox.CoreProperty.Creator)
+		assert.Equal(t, "Microsoft Office Word", msox.AppProperty.Application)
+		assert.Equal(t, "3", msox.AppProperty.Pages)
+		assert.Equal(t, "420", msox.AppProperty.Words)
+
+		entries, err := os.ReadDir(downloader.TempDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "Temporary file should be removed")
+	}
+}
+
+const testCustomXml = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/custom-properties"
+	xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">
+	<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="2" name="Classification"><vt:lpwstr>Internal</vt:lpwstr></property>
+	<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="3" name="Reviewed"><vt:bool>true</vt:bool></property>
+	<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="4" name="Retention"><vt:i4>7</vt:i4></property>
+</Properties>`
+
+// buildDocx creates an in-memory Office document holding the given parts
+func buildDocx(t *testing.T, parts map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestMsoxCoreProperties(t *testing.T) {
+	core := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties"
+	xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/"
+	xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+	<dc:title>Quarterly Report</dc:title>
+	<dc:subject>Finance</dc:subject>
+	<dc:creator>Alice</dc:creator>
+	<cp:keywords>budget, q3</cp:keywords>
+	<dc:description>Numbers for the third quarter</dc:description>
+	<cp:lastModifiedBy>Bob</cp:lastModifiedBy>
+	<cp:revision>4</cp:revision>
+	<dcterms:created xsi:type="dcterms:W3CDTF">2023-01-01T10:00:00Z</dcterms:created>
+	<dcterms:modified xsi:type="dcterms:W3CDTF">2023-01-02T11:00:00Z</dcterms:modified>
+	<cp:category>Reports</cp:category>
+	<cp:contentStatus>Final</cp:contentStatus>
+</cp:coreProperties>`
+
+	data := buildDocx(t, map[string]string{"docProps/core.xml": core})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer ts.Close()
+
+	msox := newMsox("docx", nil)
+	require.NoError(t, msox.Do(context.Background(), ts.URL))
+
+	cp := msox.CoreProperty
+	assert.Equal(t, "Quarterly Report", cp.Title)
+	assert.Equal(t, "Finance", cp.Subject)
+	assert.Equal(t, "Alice", cp.Creator)
+	assert.Equal(t, "budget, q3", cp.Keywords)
+	assert.Equal(t, "Numbers for the third quarter", cp.Description)
+	assert.Equal(t, "Bob", cp.LastModifiedBy)
+	assert.Equal(t, "4", cp.Revision)
+	assert.Equal(t, "2023-01-01T10:00:00Z", cp.Created)
+	assert.Equal(t, "2023-01-02T11:00:00Z", cp.Modified)
+	assert.Equal(t, "Reports", cp.Category)
+	assert.Equal(t, "Final", cp.ContentStatus)
+
+	var buf bytes.Buffer
+	require.NoError(t, msox.OutJSON(&buf))
+	jsonOutput := buf.String()
+	assert.Contains(t, jsonOutput, `"keywords":"budget, q3"`, "JSON should contain keywords")
+	assert.Contains(t, jsonOutput, `"category":"Reports"`, "JSON should contain category")
+	assert.Contains(t, jsonOutput, `"contentStatus":"Final"`, "JSON should contain content status")
+}
+
+func TestMsoxMacros(t *testing.T) {
+	contentTypes := func(mainType string) string {
+		return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+	<Default Extension="xml" ContentType="application/xml"/>
+	<Override PartName="/word/document.xml" ContentType="` + mainType + `"/>
+	<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>
+</Types>`
+	}
+
+	testCases := []struct {
+		name      string
+		parts     map[string]string
+		format    string
+		hasMacros bool
+	}{
+		{
+			name: "Macro-enabled document",
+			parts: map[string]string{
+				"[Content_Types].xml": contentTypes("application/vnd.ms-word.document.macroEnabled.main+xml"),
+				"word/document.xml":   "<w:document/>",
+				"word/vbaProject.bin": "\xd0\xcf\x11\xe0",
+			},
+			format:    "docm",
+			hasMacros: true,
+		},
+		{
+			name: "Plain document",
+			parts: map[string]string{
+				"[Content_Types].xml": contentTypes("application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"),
+				"word/document.xml":   "<w:document/>",
+			},
+			format: "docx",
+		},
+		{
+			name: "Macros in a document named docx",
+			parts: map[string]string{
+				"xl/vbaProject.bin": "",
+			},
+			hasMacros: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := buildDocx(t, tc.parts)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(data)
+			}))
+			defer ts.Close()
+
+			msox := newMsox("docx", nil)
+			require.NoError(t, msox.Do(context.Background(), ts.URL))
+			assert.Equal(t, tc.format, msox.Format)
+			assert.Equal(t, tc.hasMacros, msox.HasMacros)
+			assert.Equal(t, "docx", msox.Type(), "Type should stay the one requested")
+
+			var buf bytes.Buffer
+			require.NoError(t, msox.OutJSON(&buf))
+			assert.Contains(t, buf.String(), fmt.Sprintf(`"has_macros":%t`, tc.hasMacros), "JSON should always state whether there are macros")
+		})
+	}
+}
+
+func TestMsoxHttpInfo(t *testing.T) {
+	data := buildDocx(t, map[string]string{})
+
+	t.Run("Response headers are recorded", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			w.Write(data)
+		}))
+		defer ts.Close()
+
+		msox := newMsox("docx", nil)
+		require.NoError(t, msox.Do(context.Background(), ts.URL))
+		assert.Equal(t, "2006-01-02T15:04:05Z", msox.LastModified)
+		assert.Equal(t, int64(len(data)), msox.ContentLength)
+		assert.Equal(t, int64(len(data)), msox.FileSize)
+	})
+
+	t.Run("Recorded when parsing fails", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			w.Write([]byte("Not a ZIP archive"))
+		}))
+		defer ts.Close()
+
+		msox := newMsox("docx", nil)
+		require.Error(t, msox.Do(context.Background(), ts.URL))
+
+		var buf bytes.Buffer
+		require.NoError(t, msox.OutJSON(&buf))
+		assert.Contains(t, buf.String(), `"file_size":17`)
+		assert.Contains(t, buf.String(), `"http_last_modified":"2006-01-02T15:04:05Z"`)
+	})
+
+	t.Run("Absent headers are omitted", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Flushing before the body is written forces a chunked response without Content-Length
+			w.(http.Flusher).Flush()
+			w.Write(data)
+		}))
+		defer ts.Close()
+
+		msox := newMsox("docx", nil)
+		require.NoError(t, msox.Do(context.Background(), ts.URL))
+
+		var buf bytes.Buffer
+		require.NoError(t, msox.OutJSON(&buf))
+		assert.NotContains(t, buf.String(), "http_last_modified")
+		assert.NotContains(t, buf.String(), "http_content_length")
+		assert.Contains(t, buf.String(), fmt.Sprintf(`"file_size":%d`, len(data)), "Size should be measured without Content-Length")
+	})
+}
+
+func TestMsoxCustomProperties(t *testing.T) {
+	core := `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Policy</dc:title></cp:coreProperties>`
+
+	serve := func(data []byte) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(data)
+		}))
+	}
+
+	t.Run("Custom properties are parsed", func(t *testing.T) {
+		ts := serve(buildDocx(t, map[string]string{"docProps/core.xml": core, "docProps/custom.xml": testCustomXml}))
+		defer ts.Close()
+
+		msox := newMsox("docx", nil)
+		require.NoError(t, msox.Do(context.Background(), ts.URL))
+
+		assert.Equal(t, "Policy", msox.CoreProperty.Title)
+		assert.Equal(t, map[string]string{
+			"Classification": "Internal",
+			"Reviewed":       "true",
+			"Retention":      "7",
+		}, msox.CustomProperty)
+
+		var buf bytes.Buffer
+		require.NoError(t, msox.OutJSON(&buf))
+		assert.Contains(t, buf.String(), `"CustomProperty":{"Classification":"Internal"`, "JSON should contain custom properties")
+	})
+
+	t.Run("Document without custom.xml", func(t *testing.T) {
+		ts := serve(buildDocx(t, map[string]string{"docProps/core.xml": core}))
+		defer ts.Close()
+
+		msox := newMsox("docx", nil)
+		require.NoError(t, msox.Do(context.Background(), ts.URL))
+
+		assert.Empty(t, msox.CustomProperty)
+
+		var buf bytes.Buffer
+		require.NoError(t, msox.OutJSON(&buf))
+		assert.NotContains(t, buf.String(), "CustomProperty", "Missing custom properties should be omitted")
+	})
+}
+
+func TestMsoxDuplicateEntries(t *testing.T) {
+	// Temporary files go to an empty directory, so leftovers are easy to spot
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	// A crafted archive repeating the core.xml entry many times
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := 0; i < 200; i++ {
+		w, err := zw.Create("docProps/core.xml")
+		require.NoError(t, err)
+		fmt.Fprintf(w, `<cp:coreProperties xmlns:cp="cp" xmlns:dc="dc"><dc:title>Copy %d</dc:title></cp:coreProperties>`, i)
+	}
+	require.NoError(t, zw.Close())
+	data := buf.Bytes()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken.docx" {
+			w.Write(buildDocx(t, map[string]string{"docProps/core.xml": "<cp:coreProperties>"}))
+			return
+		}
+		w.Write(data)
+	}))
+	defer ts.Close()
+
+	t.Run("Every entry is decoded", func(t *testing.T) {
+		msox := newMsox("docx", nil)
+		require.NoError(t, msox.Do(context.Background(), ts.URL+"/dup.docx"))
+		assert.Equal(t, "Copy 199", msox.CoreProperty.Title, "Last entry should win")
+
+		entries, err := os.ReadDir(tmpDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "Temp file should be removed")
+	})
+
+	t.Run("Temp file is removed on parse errors", func(t *testing.T) {
+		msox := newMsox("docx", nil)
+		assert.Error(t, msox.Do(context.Background(), ts.URL+"/broken.docx"))
+
+		entries, err := os.ReadDir(tmpDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "Temp file should be removed")
+	})
+}
+
+// TestMsoxTempFiles checks that the temporary file of the download is removed when the analysis fails
+func TestMsoxTempFiles(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Not a real Office file"))
+	}))
+	defer ts.Close()
+
+	downloader := NewDownloader(NewClient(fetch.Options{}))
+	downloader.TempDir = t.TempDir()
+	downloader.MaxMemory = 0
+	msox := newMsox("docx", downloader)
+	require.Error(t, msox.Do(context.Background(), ts.URL))
+
+	entries, err := os.ReadDir(downloader.TempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "Temporary file should be removed")
+}
+
 // TestIntegrationMSOX is a mock for what an integration test might look like
 // For a real test, you would need actual Office files and would enable this test conditionally
 func TestIntegrationMSOX(t *testing.T) {
@@ -120,8 +449,8 @@ func TestIntegrationMSOX(t *testing.T) {
 		}))
 		defer ts.Close()
 
-		msox := newMsox()
-		err = msox.Do(ts.URL)
+		msox := newMsox("docx", nil)
+		err = msox.Do(context.Background(), ts.URL)
 		require.NoError(t, err)
 
 		assert.Equal(t, "Expected Title", msox.CoreProperty.Title)
@@ -0,0 +1,134 @@
+package researchers
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+)
+
+// tOdfStatistic represents document statistics from OpenDocument format
+// Found as attributes of meta:document-statistic in meta.xml
+type tOdfStatistic struct {
+	Pages      string `xml:"page-count,attr" json:"pages,omitempty"`
+	Tables     string `xml:"table-count,attr" json:"tables,omitempty"`
+	Images     string `xml:"image-count,attr" json:"images,omitempty"`
+	Objects    string `xml:"object-count,attr" json:"objects,omitempty"`
+	Paragraphs string `xml:"paragraph-count,attr" json:"paragraphs,omitempty"`
+	Words      string `xml:"word-count,attr" json:"words,omitempty"`
+	Characters string `xml:"character-count,attr" json:"characters,omitempty"`
+	Cells      string `xml:"cell-count,attr" json:"cells,omitempty"`
+}
+
+// tMetaProperty represents document metadata from OpenDocument format
+// Found in meta.xml inside OpenDocument files (Dublin Core and meta: elements)
+type tMetaProperty struct {
+	XMLName         xml.Name      `xml:"document-meta" json:"-"`
+	Title           string        `xml:"meta>title" json:"title,omitempty"`
+	Subject         string        `xml:"meta>subject" json:"subject,omitempty"`
+	Description     string        `xml:"meta>description" json:"description,omitempty"`
+	Keywords        []string      `xml:"meta>keyword" json:"keywords,omitempty"`
+	InitialCreator  string        `xml:"meta>initial-creator" json:"initialCreator,omitempty"`
+	Creator         string        `xml:"meta>creator" json:"creator,omitempty"`
+	Created         string        `xml:"meta>creation-date" json:"created,omitempty"`
+	Modified        string        `xml:"meta>date" json:"modified,omitempty"`
+	CreatedRaw      string        `xml:"-" json:"created_raw,omitempty"`  // Creation date as written, if not RFC 3339
+	ModifiedRaw     string        `xml:"-" json:"modified_raw,omitempty"` // Modification date as written, if not RFC 3339
+	Language        string        `xml:"meta>language" json:"language,omitempty"`
+	Generator       string        `xml:"meta>generator" json:"generator,omitempty"`
+	EditingCycles   string        `xml:"meta>editing-cycles" json:"editing_cycles,omitempty"`
+	EditingDuration string        `xml:"meta>editing-duration" json:"editing_duration,omitempty"`
+	Statistic       tOdfStatistic `xml:"meta>document-statistic" json:"statistic"`
+}
+
+// tOdf is a researcher for OpenDocument files (odt, ods, odp)
+// Extracts metadata from the OpenDocument files
+type tOdf struct {
+	downloader   *Downloader
+	Url          string `json:"url,omitempty"`
+	DocType      string `json:"doc_type,omitempty"`
+	MetaProperty tMetaProperty
+	tHttpInfo    // File information from the HTTP response headers
+}
+
+// newOdf creates a new OpenDocument researcher for the given file type (odt, ods or odp)
+// Documents are fetched by the given downloader (a default downloader if nil)
+func newOdf(docType string, downloader *Downloader) *tOdf {
+	if downloader == nil {
+		downloader = defaultDownloader()
+	}
+	return &tOdf{downloader: downloader, DocType: docType}
+}
+
+// Type returns the file type the researcher was created for
+func (odf *tOdf) Type() string {
+	return odf.DocType
+}
+
+// DocTitle returns the title of the document
+func (odf *tOdf) DocTitle() string {
+	return odf.MetaProperty.Title
+}
+
+// DocAuthor returns the author of the document
+func (odf *tOdf) DocAuthor() string {
+	// The creator is the last one to have edited the document, the initial creator its author
+	if odf.MetaProperty.InitialCreator != "" {
+		return odf.MetaProperty.InitialCreator
+	}
+	return odf.MetaProperty.Creator
+}
+
+// DocCreated returns the creation date of the document
+func (odf *tOdf) DocCreated() string {
+	return odf.MetaProperty.Created
+}
+
+// DocModified returns the last modification date of the document
+func (odf *tOdf) DocModified() string {
+	return odf.MetaProperty.Modified
+}
+
+// OutJSON serializes the OpenDocument metadata to JSON and writes it to the provided writer
+func (odf *tOdf) OutJSON(writer io.Writer) error {
+	data, err := json.Marshal(odf)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+// Do performs the analysis of an OpenDocument file at the given URL
+// Downloads the file, extracts metadata from meta.xml, and stores it
+func (odf *tOdf) Do(ctx context.Context, url string) error {
+	odf.Url = url
+
+	// Download the document to a ReadSeeker for zip operations
+	doc, cleanup, httpInfo, err := odf.downloader.download(ctx, url)
+	if err != nil {
+		return err
+	}
+	odf.tHttpInfo = httpInfo
+	defer cleanup()
+
+	// Open ZIP archive (OpenDocument files are ZIP archives)
+	rZip, err := newZipReader(doc)
+	if err != nil {
+		return err
+	}
+
+	for _, fInZip := range rZip.File {
+		if fInZip.Name != "meta.xml" {
+			continue
+		}
+		err := decodeZipEntry(fInZip, &odf.MetaProperty)
+		if err != nil {
+			return err
+		}
+	}
+	normalizeDateField(&odf.MetaProperty.Created, &odf.MetaProperty.CreatedRaw)
+	normalizeDateField(&odf.MetaProperty.Modified, &odf.MetaProperty.ModifiedRaw)
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package researchers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOdfMeta = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0"
+	xmlns:dc="http://purl.org/dc/elements/1.1/" office:version="1.3">
+	<office:meta>
+		<meta:generator>LibreOffice/7.6</meta:generator>
+		<dc:title>Annual Report</dc:title>
+		<dc:subject>Finance</dc:subject>
+		<meta:keyword>report</meta:keyword>
+		<meta:keyword>2023</meta:keyword>
+		<meta:initial-creator>Alice</meta:initial-creator>
+		<dc:creator>Bob</dc:creator>
+		<meta:creation-date>2023-01-01T10:00:00</meta:creation-date>
+		<dc:date>2023-01-02T11:00:00</dc:date>
+		<dc:language>uk-UA</dc:language>
+		<meta:editing-cycles>3</meta:editing-cycles>
+		<meta:document-statistic meta:page-count="12" meta:word-count="3400" meta:character-count="21000"/>
+	</office:meta>
+</office:document-meta>`
+
+// buildOdf creates an in-memory OpenDocument archive with the given meta.xml
+func buildOdf(t *testing.T, meta string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("mimetype")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("application/vnd.oasis.opendocument.text"))
+	require.NoError(t, err)
+	w, err = zw.Create("meta.xml")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(meta))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestOdfResearcher(t *testing.T) {
+	t.Run("ODF initialization", func(t *testing.T) {
+		odf := newOdf("odt", nil)
+		assert.NotNil(t, odf, "ODF researcher should be initialized")
+		assert.Empty(t, odf.Url, "URL should be empty initially")
+		assert.Empty(t, odf.MetaProperty.Title, "Title should be empty initially")
+	})
+
+	t.Run("Parse meta.xml", func(t *testing.T) {
+		data := buildOdf(t, testOdfMeta)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(data)
+		}))
+		defer ts.Close()
+
+		odf := newOdf("odt", nil)
+		err := odf.Do(context.Background(), ts.URL+"/report.odt")
+		require.NoError(t, err, "Valid ODF document should be parsed")
+
+		meta := odf.MetaProperty
+		assert.Equal(t, ts.URL+"/report.odt", odf.Url)
+		assert.Equal(t, "Annual Report", meta.Title)
+		assert.Equal(t, "Finance", meta.Subject)
+		assert.Equal(t, []string{"report", "2023"}, meta.Keywords)
+		assert.Equal(t, "Alice", meta.InitialCreator)
+		assert.Equal(t, "Bob", meta.Creator)
+		assert.Equal(t, "2023-01-01T10:00:00Z", meta.Created, "Dates should be normalized to RFC 3339")
+		assert.Equal(t, "2023-01-02T11:00:00Z", meta.Modified)
+		assert.Equal(t, "2023-01-01T10:00:00", meta.CreatedRaw, "Dates should be kept as written")
+		assert.Equal(t, "2023-01-02T11:00:00", meta.ModifiedRaw)
+		assert.Equal(t, "uk-UA", meta.Language)
+		assert.Equal(t, "LibreOffice/7.6", meta.Generator)
+		assert.Equal(t, "3", meta.EditingCycles)
+		assert.Equal(t, "12", meta.Statistic.Pages)
+		assert.Equal(t, "3400", meta.Statistic.Words)
+
+		var buf bytes.Buffer
+		require.NoError(t, odf.OutJSON(&buf), "JSON output should not error")
+		jsonOutput := buf.String()
+		assert.Contains(t, jsonOutput, "\"title\":\"Annual Report\"", "JSON should contain title")
+		assert.Contains(t, jsonOutput, "\"creator\":\"Bob\"", "JSON should contain creator")
+		assert.Contains(t, jsonOutput, "\"pages\":\"12\"", "JSON should contain pages count")
+	})
+
+	t.Run("Error handling for invalid archive", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("not a zip archive"))
+		}))
+		defer ts.Close()
+
+		odf := newOdf("odt", nil)
+		err := odf.Do(context.Background(), ts.URL)
+		assert.Error(t, err, "Should return error for invalid archive")
+	})
+
+	t.Run("Error handling for HTTP issues", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer ts.Close()
+
+		odf := newOdf("odt", nil)
+		err := odf.Do(context.Background(), ts.URL)
+		assert.Error(t, err, "Should return error for non-200 HTTP status")
+		assert.Contains(t, err.Error(), "failed to download file", "Error should indicate download failure")
+	})
+}
@@ -0,0 +1,177 @@
+package researchers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/msoleps"
+	"github.com/richardlehane/msoleps/types"
+)
+
+// tOle is a researcher for legacy Microsoft Office files (doc, xls, ppt)
+// These are OLE2 compound files; metadata is read from the SummaryInformation
+// and DocumentSummaryInformation property set streams
+type tOle struct {
+	downloader *Downloader
+	Url        string `json:"url,omitempty"`
+	DocType    string `json:"doc_type,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Subject    string `json:"subject,omitempty"`
+	Author     string `json:"author,omitempty"`
+	Keywords   string `json:"keywords,omitempty"`
+	Comments   string `json:"comments,omitempty"`
+	LastAuthor string `json:"last_author,omitempty"`
+	Revision   string `json:"revision,omitempty"`
+	AppName    string `json:"application,omitempty"`
+	Created    string `json:"created,omitempty"`
+	Modified   string `json:"modified,omitempty"`
+	Pages      string `json:"pages,omitempty"`
+	Words      string `json:"words,omitempty"`
+	Characters string `json:"characters,omitempty"`
+	Category   string `json:"category,omitempty"`
+	Company    string `json:"company,omitempty"`
+	Manager    string `json:"manager,omitempty"`
+	tHttpInfo         // File information from the HTTP response headers
+}
+
+// newOle creates a new legacy Office document researcher for the given file type (doc, xls or ppt)
+// Documents are fetched by the given downloader (a default downloader if nil)
+func newOle(docType string, downloader *Downloader) *tOle {
+	if downloader == nil {
+		downloader = defaultDownloader()
+	}
+	return &tOle{downloader: downloader, DocType: docType}
+}
+
+// Type returns the file type the researcher was created for
+func (ole *tOle) Type() string {
+	return ole.DocType
+}
+
+// DocTitle returns the title of the document
+func (ole *tOle) DocTitle() string {
+	return ole.Title
+}
+
+// DocAuthor returns the author of the document
+func (ole *tOle) DocAuthor() string {
+	return ole.Author
+}
+
+// DocCreated returns the creation date of the document
+func (ole *tOle) DocCreated() string {
+	return ole.Created
+}
+
+// DocModified returns the last modification date of the document
+func (ole *tOle) DocModified() string {
+	return ole.Modified
+}
+
+// OutJSON serializes the legacy Office metadata to JSON and writes it to the provided writer
+func (ole *tOle) OutJSON(writer io.Writer) error {
+	data, err := json.Marshal(ole)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+// Do performs the analysis of a legacy Office document at the given URL
+// Downloads the file, reads its property set streams, and stores the metadata
+func (ole *tOle) Do(ctx context.Context, url string) (err error) {
+	ole.Url = url
+
+	// Download the document for random access to the compound file sectors
+	doc, cleanup, httpInfo, err := ole.downloader.download(ctx, url)
+	if err != nil {
+		return err
+	}
+	ole.tHttpInfo = httpInfo
+	defer cleanup()
+
+	// Corrupt sector chains can make the parsers index out of range, report them as errors
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed OLE2 compound file: %v", r)
+		}
+	}()
+
+	compound, err := mscfb.New(doc)
+	if err != nil {
+		return fmt.Errorf("not an OLE2 compound file: %w", err)
+	}
+
+	// Property set stream names start with \x05, e.g. "\x05SummaryInformation"
+	for entry, err := compound.Next(); err == nil; entry, err = compound.Next() {
+		if !msoleps.IsMSOLEPS(entry.Initial) {
+			continue
+		}
+		props, err := msoleps.NewFrom(entry)
+		if err != nil {
+			return err
+		}
+		for _, prop := range props.Property {
+			ole.set(prop)
+		}
+	}
+
+	return nil
+}
+
+// set stores a property of the SummaryInformation or DocumentSummaryInformation set
+// Properties without a matching field are ignored
+func (ole *tOle) set(prop *msoleps.Property) {
+	if prop.Name == "CreateTime" || prop.Name == "LastSaveTime" {
+		ft, ok := prop.T.(types.FileTime)
+		if !ok || ft.Time().Unix() <= 0 {
+			return // Unset timestamps read as the epoch
+		}
+		st := ft.Time().UTC().Format(time.RFC3339)
+		if prop.Name == "CreateTime" {
+			ole.Created = st
+		} else {
+			ole.Modified = st
+		}
+		return
+	}
+
+	// Strings are stored padded with spaces or NULs
+	value := strings.TrimRight(prop.String(), " \x00")
+	switch prop.Name {
+	case "Title":
+		ole.Title = value
+	case "Subject":
+		ole.Subject = value
+	case "Author":
+		ole.Author = value
+	case "Keywords":
+		ole.Keywords = value
+	case "Comments":
+		ole.Comments = value
+	case "LastAuthor":
+		ole.LastAuthor = value
+	case "RevNumber":
+		ole.Revision = value
+	case "AppName":
+		ole.AppName = value
+	case "Category":
+		ole.Category = value
+	case "Company":
+		ole.Company = value
+	case "Manager":
+		ole.Manager = value
+	case "PageCount":
+		ole.Pages = value
+	case "WordCount":
+		ole.Words = value
+	case "CharCount":
+		ole.Characters = value
+	}
+}
@@ -0,0 +1,88 @@
+package researchers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOleResearcher(t *testing.T) {
+	docData, err := os.ReadFile("testdata/sample.doc")
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/msword")
+		w.Write(docData)
+	}))
+	defer ts.Close()
+
+	t.Run("Summary information", func(t *testing.T) {
+		ole := newOle("doc", nil)
+		require.NoError(t, ole.Do(context.Background(), ts.URL+"/sample.doc"))
+
+		assert.Equal(t, ts.URL+"/sample.doc", ole.Url)
+		assert.Equal(t, "doc", ole.DocType)
+		assert.Equal(t, "Annual Report", ole.Title)
+		assert.Equal(t, "Finance", ole.Subject)
+		assert.Equal(t, "Test Author", ole.Author)
+		assert.Equal(t, "budget, report", ole.Keywords)
+		assert.Equal(t, "Last Editor", ole.LastAuthor)
+		assert.Equal(t, "3", ole.Revision)
+		assert.Equal(t, "Microsoft Office Word", ole.AppName)
+		assert.Equal(t, "2023-01-02T03:04:05Z", ole.Created)
+		assert.Equal(t, "2024-05-06T07:08:09Z", ole.Modified)
+		assert.Equal(t, "4", ole.Pages)
+		assert.Equal(t, "1200", ole.Words)
+		assert.Equal(t, "6800", ole.Characters)
+	})
+
+	t.Run("Document summary information", func(t *testing.T) {
+		ole := newOle("doc", nil)
+		require.NoError(t, ole.Do(context.Background(), ts.URL+"/sample.doc"))
+
+		assert.Equal(t, "Reports", ole.Category)
+		assert.Equal(t, "Example Corp", ole.Company)
+	})
+
+	t.Run("Output to JSON", func(t *testing.T) {
+		ole := newOle("doc", nil)
+		require.NoError(t, ole.Do(context.Background(), ts.URL+"/sample.doc"))
+
+		var buf bytes.Buffer
+		require.NoError(t, ole.OutJSON(&buf))
+		assert.Contains(t, buf.String(), `"doc_type":"doc"`)
+		assert.Contains(t, buf.String(), `"title":"Annual Report"`)
+		assert.Contains(t, buf.String(), `"created":"2023-01-02T03:04:05Z"`)
+		assert.NotContains(t, buf.String(), "manager", "Missing properties should be omitted")
+	})
+
+	t.Run("Not a compound file", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("Not a real document"))
+		}))
+		defer ts.Close()
+
+		ole := newOle("xls", nil)
+		err := ole.Do(context.Background(), ts.URL)
+		assert.ErrorContains(t, err, "not an OLE2 compound file")
+		assert.Equal(t, ts.URL, ole.Url, "URL should be set even if processing fails")
+	})
+
+	t.Run("Truncated compound file", func(t *testing.T) {
+		for _, size := range []int{512, 1024, 2048, len(docData) / 2} {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(docData[:size])
+			}))
+
+			ole := newOle("doc", nil)
+			assert.NotPanics(t, func() { _ = ole.Do(context.Background(), ts.URL) }, "Truncated file of %d bytes", size)
+			ts.Close()
+		}
+	})
+}
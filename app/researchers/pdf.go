@@ -1,36 +1,91 @@
 package researchers
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
-	"net/http"
-	"os"
-	"time"
+	"slices"
 
 	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 )
 
+// tPdfPermissions summarizes the user access permissions of an encrypted PDF document
+type tPdfPermissions struct {
+	Print  bool `json:"print"`  // Printing allowed
+	Copy   bool `json:"copy"`   // Copying or extracting text and graphics allowed
+	Modify bool `json:"modify"` // Modifying the content allowed
+}
+
+// newPdfPermissions decodes the permission flags of the encryption dictionary
+func newPdfPermissions(flags int) *tPdfPermissions {
+	p := model.PermissionFlags(flags)
+	return &tPdfPermissions{
+		Print:  p&model.PermissionPrintRev2 != 0,
+		Copy:   p&model.PermissionExtract != 0,
+		Modify: p&model.PermissionModify != 0,
+	}
+}
+
 // tPdf is a researcher for PDF documents
 // Extracts metadata from PDF files using pdfcpu library
 type tPdf struct {
-	docType      string
-	Url          string `json:"url,omitempty"`
-	FileName     string `json:"source,omitempty"`
-	Version      string `json:"version,omitempty"`
-	Title        string `json:"title,omitempty"`
-	Author       string `json:"author,omitempty"`
-	Subject      string `json:"subject,omitempty"`
-	Producer     string `json:"producer,omitempty"`
-	Creator      string `json:"creator,omitempty"`
-	CreationDate string `json:"creation_date,omitempty"`
-	ModDate      string `json:"mod_date,omitempty"`
+	downloader      *Downloader
+	Url             string           `json:"url,omitempty"`
+	DocType         string           `json:"doc_type,omitempty"`
+	FileName        string           `json:"source,omitempty"`
+	Version         string           `json:"version,omitempty"`
+	Title           string           `json:"title,omitempty"`
+	Author          string           `json:"author,omitempty"`
+	Subject         string           `json:"subject,omitempty"`
+	Producer        string           `json:"producer,omitempty"`
+	Creator         string           `json:"creator,omitempty"`
+	CreationDate    string           `json:"creation_date,omitempty"`
+	ModDate         string           `json:"mod_date,omitempty"`
+	CreationDateRaw string           `json:"creation_date_raw,omitempty"` // Creation date as written, if not RFC 3339
+	ModDateRaw      string           `json:"mod_date_raw,omitempty"`      // Modification date as written, if not RFC 3339
+	Keywords        []string         `json:"keywords,omitempty"`
+	PageCount       int              `json:"page_count,omitempty"`
+	Encrypted       bool             `json:"encrypted,omitempty"`   // Set for encrypted documents, whose metadata may be missing
+	Permissions     *tPdfPermissions `json:"permissions,omitempty"` // Permissions of encrypted documents that could be opened
+	Xmp             map[string]any   `json:"xmp,omitempty"`         // Properties of the XMP metadata packet, by prefixed name
+	tHttpInfo                        // File information from the HTTP response headers
 }
 
 // newPdf creates a new PDF document researcher
-func newPdf() *tPdf {
-	return new(tPdf)
+// Documents are fetched by the given downloader (a default downloader if nil)
+func newPdf(downloader *Downloader) *tPdf {
+	if downloader == nil {
+		downloader = defaultDownloader()
+	}
+	return &tPdf{downloader: downloader, DocType: "pdf"}
+}
+
+// Type returns the file type the researcher was created for
+func (pdf *tPdf) Type() string {
+	return pdf.DocType
+}
+
+// DocTitle returns the title of the document
+func (pdf *tPdf) DocTitle() string {
+	return pdf.Title
+}
+
+// DocAuthor returns the author of the document
+func (pdf *tPdf) DocAuthor() string {
+	return pdf.Author
+}
+
+// DocCreated returns the creation date of the document
+func (pdf *tPdf) DocCreated() string {
+	return pdf.CreationDate
+}
+
+// DocModified returns the last modification date of the document
+func (pdf *tPdf) DocModified() string {
+	return pdf.ModDate
 }
 
 // OutJSON serializes the PDF metadata to JSON and writes it to the provided writer
@@ -45,44 +100,34 @@ func (pdf *tPdf) OutJSON(writer io.Writer) error {
 
 // Do performs the analysis of a PDF document at the given URL
 // Downloads the file, extracts metadata, and stores it
-func (pdf *tPdf) Do(url string) error {
-	pdf.docType = "pdf"
+func (pdf *tPdf) Do(ctx context.Context, url string) error {
 	pdf.Url = url
 
-	// Initialize HTTP client with timeout
-	client := http.Client{
-		Timeout: httpGetTimeout * time.Second,
-	}
-	resp, err := client.Get(url)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK { // Check for 200 OK status
-		// Can read response body for more detailed error if needed
-		return fmt.Errorf("failed to download file: status code %d", resp.StatusCode)
-	}
-
-	// Convert response body to a ReadSeeker for PDF operations
-	respReadSeeker, err := readCloserToReadSeekerFile(resp.Body)
+	// Download the document to a ReadSeeker for PDF operations
+	doc, cleanup, httpInfo, err := pdf.downloader.download(ctx, url)
 	if err != nil {
 		return err
 	}
+	pdf.tHttpInfo = httpInfo
+	defer cleanup()
 
 	// Get PDF information using pdfcpu library
-	tmpFileName := respReadSeeker.Name()
-	info, err := api.PDFInfo(respReadSeeker, tmpFileName, nil, model.NewDefaultConfiguration())
-	if err != nil {
-		return err
-	}
+	conf := model.NewDefaultConfiguration()
+	info, err := api.PDFInfo(doc, url, nil, conf)
 
-	// Clean up temporary file
-	respReadSeeker.Close()
-	err = os.Remove(tmpFileName)
+	// A document protected by a user password cannot be decrypted, so none of its metadata
+	// is readable; it is still reported, flagged as encrypted
+	if errors.Is(err, pdfcpu.ErrWrongPassword) {
+		pdf.Encrypted = true
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
+	// The XMP packet is optional, and one that cannot be read leaves the rest of the metadata valid
+	pdf.Xmp, _ = readXmp(doc, conf)
+
 	// Store extracted metadata
 	pdf.Title = info.Title
 	pdf.Author = info.Author
@@ -91,6 +136,45 @@ func (pdf *tPdf) Do(url string) error {
 	pdf.Producer = info.Producer
 	pdf.CreationDate = info.CreationDate
 	pdf.ModDate = info.ModificationDate
+	normalizeDateField(&pdf.CreationDate, &pdf.CreationDateRaw)
+	normalizeDateField(&pdf.ModDate, &pdf.ModDateRaw)
+	// pdfcpu collects the keywords in a map, sorting them keeps the output the same from run to run
+	pdf.Keywords = slices.Sorted(slices.Values(info.Keywords))
+	pdf.PageCount = info.PageCount
+	pdf.Encrypted = info.Encrypted
+	if info.Encrypted {
+		pdf.Permissions = newPdfPermissions(info.Permissions)
+	}
 
 	return nil
 }
+
+// readXmp returns the properties of the XMP metadata stream of the document catalog
+// Returns nil if the document has no XMP packet
+func readXmp(rs io.ReadSeeker, conf *model.Configuration) (map[string]any, error) {
+	_, err := rs.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := api.ReadContext(rs, conf)
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	o, found := catalog.Find("Metadata")
+	if !found {
+		return nil, nil
+	}
+	sd, _, err := ctx.DereferenceStreamDict(o)
+	if err != nil || sd == nil {
+		return nil, err
+	}
+	err = sd.Decode()
+	if err != nil {
+		return nil, err
+	}
+	return parseXmp(sd.Content)
+}
@@ -2,17 +2,24 @@ package researchers
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"docscrawler/app/fetch"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestPdfResearcher(t *testing.T) {
 	t.Run("PDF initialization", func(t *testing.T) {
-		pdf := newPdf()
+		pdf := newPdf(nil)
 		assert.NotNil(t, pdf, "PDF researcher should be initialized")
 		assert.IsType(t, &tPdf{}, pdf, "Should return correct type")
 		assert.Empty(t, pdf.Url, "URL should be empty initially")
@@ -22,7 +29,7 @@ func TestPdfResearcher(t *testing.T) {
 
 	t.Run("Output to JSON", func(t *testing.T) {
 		// Create PDF researcher with test data
-		pdf := newPdf()
+		pdf := newPdf(nil)
 		pdf.Url = "https://example.com/test.pdf"
 		pdf.Title = "Test Document"
 		pdf.Author = "Test Author"
@@ -56,8 +63,8 @@ func TestPdfResearcher(t *testing.T) {
 		}))
 		defer ts.Close()
 
-		pdf := newPdf()
-		err := pdf.Do(ts.URL)
+		pdf := newPdf(nil)
+		err := pdf.Do(context.Background(), ts.URL)
 		assert.Error(t, err, "Should return error for non-200 HTTP status")
 		assert.Contains(t, err.Error(), "failed to download file", "Error should indicate download failure")
 	})
@@ -67,7 +74,7 @@ func TestPdfResearcher(t *testing.T) {
 
 	t.Run("Do method sets URL", func(t *testing.T) {
 		// This minimal test just verifies the URL is set, without testing actual PDF parsing
-		pdf := newPdf()
+		pdf := newPdf(nil)
 
 		// Mock server that returns invalid data (not a real PDF)
 		// This will cause errors in the PDF parsing, but we can still check that URL is set
@@ -78,39 +85,167 @@ func TestPdfResearcher(t *testing.T) {
 		defer ts.Close()
 
 		// Call will fail due to invalid PDF data, but URL should be set
-		_ = pdf.Do(ts.URL)
+		_ = pdf.Do(context.Background(), ts.URL)
 		assert.Equal(t, ts.URL, pdf.Url, "URL should be set even if processing fails")
-		assert.Equal(t, "pdf", pdf.docType, "Document type should be set to pdf")
+		assert.Equal(t, "pdf", pdf.DocType, "Document type should be set to pdf")
 	})
 }
 
-// TestIntegrationPDF is a mock for what an integration test might look like
-// For a real test, you would need actual PDF files and would enable this test conditionally
+// TestPdfTempFiles checks that the temporary file of the download is removed when the analysis fails
+func TestPdfTempFiles(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Not a real PDF"))
+	}))
+	defer ts.Close()
+
+	downloader := NewDownloader(NewClient(fetch.Options{}))
+	downloader.TempDir = t.TempDir()
+	downloader.MaxMemory = 0
+	pdf := newPdf(downloader)
+	require.Error(t, pdf.Do(context.Background(), ts.URL))
+
+	entries, err := os.ReadDir(downloader.TempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "Temporary file should be removed")
+}
+
+// TestIntegrationPDF analyses the sample PDF committed in testdata
 func TestIntegrationPDF(t *testing.T) {
-	// Skip this test by default since it requires actual PDF files
-	t.Skip("Integration test requires actual PDF files")
+	pdfData, err := os.ReadFile("testdata/sample.pdf")
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		w.Write(pdfData)
+	}))
+	defer ts.Close()
+
+	pdf := newPdf(nil)
+	err = pdf.Do(context.Background(), ts.URL)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Sample Report", pdf.Title)
+	assert.Equal(t, "Test Author", pdf.Author)
+	assert.Equal(t, "Testing", pdf.Subject)
+	assert.Equal(t, []string{"crawler", "metadata"}, pdf.Keywords, "Keywords should be sorted")
+	assert.Equal(t, 3, pdf.PageCount)
+
+	var buf bytes.Buffer
+	require.NoError(t, pdf.OutJSON(&buf))
+	assert.Contains(t, buf.String(), `"keywords":["crawler","metadata"]`, "JSON should contain keywords")
+	assert.Contains(t, buf.String(), `"page_count":3`, "JSON should contain page count")
+	assert.Contains(t, buf.String(), `"http_last_modified":"2015-10-21T07:28:00Z"`, "JSON should contain the Last-Modified header")
+	assert.Contains(t, buf.String(), fmt.Sprintf(`"http_content_length":%d`, len(pdfData)), "JSON should contain the Content-Length header")
+	assert.Contains(t, buf.String(), fmt.Sprintf(`"file_size":%d`, len(pdfData)), "JSON should contain the size of the downloaded file")
+	assert.Contains(t, buf.String(), fmt.Sprintf(`"content_hash":"%x"`, sha256.Sum256(pdfData)), "JSON should contain the SHA-256 of the document")
+}
 
-	// In a real test, you might:
-	// 1. Set up a test server that serves a real PDF file
-	// 2. Create a PDF researcher and process it
-	// 3. Verify the metadata is correctly extracted
+func TestPdfHttpInfoOnParseFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		w.Write([]byte("Not a real PDF"))
+	}))
+	defer ts.Close()
 
-	/*
-		// Example of what this might look like:
-		pdfData, err := os.ReadFile("testdata/sample.pdf")
-		require.NoError(t, err)
+	pdf := newPdf(nil)
+	require.Error(t, pdf.Do(context.Background(), ts.URL))
 
-		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/pdf")
+	var buf bytes.Buffer
+	require.NoError(t, pdf.OutJSON(&buf))
+	assert.Contains(t, buf.String(), `"file_size":14`, "Size should be known though the document could not be parsed")
+	assert.Contains(t, buf.String(), `"http_last_modified":"2015-10-21T07:28:00Z"`)
+}
+
+// tRoundTripper answers requests with a function instead of a server
+type tRoundTripper func(req *http.Request) (*http.Response, error)
+
+func (rt tRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt(req)
+}
+
+func TestPdfHTTPClient(t *testing.T) {
+	pdfData, err := os.ReadFile("testdata/sample.pdf")
+	require.NoError(t, err)
+
+	// Documents are served by the transport of the injected client, no server is needed
+	var requested string
+	client := &http.Client{Transport: tRoundTripper(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.String()
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Body:          io.NopCloser(bytes.NewReader(pdfData)),
+			ContentLength: int64(len(pdfData)),
+			Request:       req,
+		}, nil
+	})}
+
+	pdf := newPdf(NewDownloader(NewClient(fetch.Options{HTTPClient: client})))
+	require.NoError(t, pdf.Do(context.Background(), "https://docs.example.com/report.pdf"))
+	assert.Equal(t, "https://docs.example.com/report.pdf", requested)
+	assert.Equal(t, "Sample Report", pdf.Title)
+	assert.Equal(t, int64(len(pdfData)), pdf.ContentLength)
+}
+
+func TestPdfEncrypted(t *testing.T) {
+	serve := func(t *testing.T, name string) *httptest.Server {
+		pdfData, err := os.ReadFile(name)
+		require.NoError(t, err)
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Write(pdfData)
 		}))
+	}
+
+	t.Run("User password", func(t *testing.T) {
+		ts := serve(t, "testdata/encrypted.pdf")
 		defer ts.Close()
 
-		pdf := newPdf()
-		err = pdf.Do(ts.URL)
-		require.NoError(t, err)
+		pdf := newPdf(nil)
+		err := pdf.Do(context.Background(), ts.URL)
+		require.NoError(t, err, "Encrypted document should still be reported")
+		assert.True(t, pdf.Encrypted)
+		assert.Empty(t, pdf.Title, "Metadata of a document that cannot be decrypted is unreadable")
+
+		var buf bytes.Buffer
+		require.NoError(t, pdf.OutJSON(&buf))
+		assert.Contains(t, buf.String(), `"encrypted":true`, "JSON should flag the document as encrypted")
+		assert.Contains(t, buf.String(), `"url":"`+ts.URL+`"`, "JSON should contain URL")
+	})
+
+	t.Run("Owner password only", func(t *testing.T) {
+		ts := serve(t, "testdata/restricted.pdf")
+		defer ts.Close()
+
+		pdf := newPdf(nil)
+		require.NoError(t, pdf.Do(context.Background(), ts.URL))
+		assert.True(t, pdf.Encrypted)
+		assert.Equal(t, "Sample Report", pdf.Title, "Metadata should be readable without the owner password")
+		assert.Equal(t, 3, pdf.PageCount)
+		require.NotNil(t, pdf.Permissions, "Permissions of an opened encrypted document should be reported")
+		assert.Equal(t, tPdfPermissions{}, *pdf.Permissions, "Sample is restricted to viewing")
+
+		var buf bytes.Buffer
+		require.NoError(t, pdf.OutJSON(&buf))
+		assert.Contains(t, buf.String(), `"permissions":{"print":false,"copy":false,"modify":false}`)
+	})
+
+	t.Run("Plain document", func(t *testing.T) {
+		ts := serve(t, "testdata/sample.pdf")
+		defer ts.Close()
+
+		pdf := newPdf(nil)
+		require.NoError(t, pdf.Do(context.Background(), ts.URL))
+		assert.False(t, pdf.Encrypted)
+
+		var buf bytes.Buffer
+		require.NoError(t, pdf.OutJSON(&buf))
+		assert.NotContains(t, buf.String(), "encrypted")
+		assert.NotContains(t, buf.String(), "permissions")
+	})
+}
 
-		assert.Equal(t, "Expected Title", pdf.Title)
-		assert.Equal(t, "Expected Author", pdf.Author)
-	*/
+func TestNewPdfPermissions(t *testing.T) {
+	assert.Equal(t, tPdfPermissions{}, *newPdfPermissions(int(model.PermissionsNone)))
+	assert.Equal(t, tPdfPermissions{Print: true}, *newPdfPermissions(int(model.PermissionsPrint)))
+	assert.Equal(t, tPdfPermissions{Print: true, Copy: true, Modify: true}, *newPdfPermissions(int(model.PermissionsAll)))
 }
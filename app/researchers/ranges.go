@@ -0,0 +1,109 @@
+package researchers
+
+import (
+	"context"
+	"docscrawler/app/fetch"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Minimum number of bytes fetched by a range request, so the many small reads
+// of the ZIP reader are served from the last block instead of one request each
+const rangeBlockSize = 64 * 1024
+
+// tRangeReader reads a remote document by HTTP range requests
+// Only the parts actually read are downloaded, e.g. the central directory and the
+// metadata entries of a ZIP archive, at the price of a request per block read
+// It is not safe for concurrent use
+type tRangeReader struct {
+	ctx    context.Context
+	client *fetch.Client
+	url    string
+	size   int64
+
+	blockOffset int64  // Offset of the last block fetched
+	block       []byte // Last block fetched
+}
+
+// ReadAt reads len(p) bytes of the document starting at off, fetching them unless they are
+// in the last block; returns io.EOF if the document ends before p is filled
+func (rr *tRangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= rr.size {
+		return 0, io.EOF
+	}
+	want := min(int64(len(p)), rr.size-off)
+
+	if off < rr.blockOffset || off+want > rr.blockOffset+int64(len(rr.block)) {
+		length := min(max(want, rangeBlockSize), rr.size-off)
+		block, err := rr.fetch(off, length)
+		if err != nil {
+			return 0, err
+		}
+		rr.blockOffset, rr.block = off, block
+	}
+
+	n := copy(p[:want], rr.block[off-rr.blockOffset:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fetch downloads length bytes of the document starting at offset
+func (rr *tRangeReader) fetch(offset, length int64) ([]byte, error) {
+	resp, err := rr.client.GetRange(rr.ctx, rr.url, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("range request failed: status code %d", resp.StatusCode)
+	}
+
+	block := make([]byte, length)
+	_, err = io.ReadFull(resp.Body, block)
+	if err != nil {
+		return nil, fmt.Errorf("range request failed: %w", err)
+	}
+	return block, nil
+}
+
+// openRemote prepares reading the document at the URL by range requests, if range requests are
+// enabled, the server announces support for them, and the document is too large to be held in memory
+// Returns false when the document is to be downloaded instead, as are those already fetched by DetectType
+// and those of an earlier run, whose download is conditional
+func (d *Downloader) openRemote(ctx context.Context, url string) (*tRangeReader, tHttpInfo, bool, error) {
+	if !d.Ranges {
+		return nil, tHttpInfo{}, false, nil
+	}
+	d.mu.Lock()
+	_, prefetched := d.prefetched[url]
+	d.mu.Unlock()
+	if _, conditional := d.Prior[url]; prefetched || conditional {
+		return nil, tHttpInfo{}, false, nil
+	}
+
+	// A server that does not answer HEAD requests is left to the download to report
+	// The answer is kept for the size check of the download, which is the only one to follow
+	resp, err := d.Head(ctx, url)
+	if err != nil {
+		return nil, tHttpInfo{}, false, nil
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Accept-Ranges") != "bytes" || resp.ContentLength <= d.MaxMemory {
+		return nil, tHttpInfo{}, false, nil
+	}
+	d.forgetHead(url)
+	if d.MaxFileSize > 0 && resp.ContentLength > d.MaxFileSize {
+		return nil, tHttpInfo{}, false, tooLarge(d.MaxFileSize)
+	}
+
+	reader := &tRangeReader{ctx: ctx, client: d.Client, url: url, size: resp.ContentLength}
+	// Only parts of the document are read, its size is the one announced
+	info := newHttpInfo(resp)
+	info.FileSize = resp.ContentLength
+	return reader, info, true, nil
+}
@@ -0,0 +1,140 @@
+package researchers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"docscrawler/app/fetch"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildLargeDocx creates an Office document whose properties are followed by a large media entry,
+// stored uncompressed so the archive stays large
+func buildLargeDocx(t *testing.T, size int) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("docProps/core.xml")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(`<cp:coreProperties xmlns:cp="cp" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Large Report</dc:title></cp:coreProperties>`))
+	require.NoError(t, err)
+
+	w, err = zw.CreateHeader(&zip.FileHeader{Name: "word/media/image1.png", Method: zip.Store})
+	require.NoError(t, err)
+	media := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(media)
+	_, err = w.Write(media)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestMsoxRangeRequests(t *testing.T) {
+	data := buildLargeDocx(t, 1<<20)
+
+	var rangeRequests, bytesSent atomic.Int64
+	serve := func(ranges bool) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Range") != "" {
+				rangeRequests.Add(1)
+			}
+			if !ranges {
+				w.Write(data)
+				bytesSent.Add(int64(len(data)))
+				return
+			}
+			counter := &tCountingWriter{ResponseWriter: w, n: &bytesSent}
+			http.ServeContent(counter, r, "large.docx", time.Time{}, bytes.NewReader(data))
+		}))
+	}
+	newDownloader := func() *Downloader {
+		downloader := NewDownloader(NewClient(fetch.Options{}))
+		downloader.MaxMemory = 64 * 1024
+		downloader.Ranges = true
+		return downloader
+	}
+
+	t.Run("Only the metadata is downloaded", func(t *testing.T) {
+		rangeRequests.Store(0)
+		bytesSent.Store(0)
+		ts := serve(true)
+		defer ts.Close()
+
+		msox := newMsox("docx", newDownloader())
+		require.NoError(t, msox.Do(context.Background(), ts.URL))
+		assert.Equal(t, "Large Report", msox.CoreProperty.Title)
+		assert.Equal(t, int64(len(data)), msox.ContentLength, "Size should come from the HEAD response")
+		assert.Equal(t, int64(len(data)), msox.FileSize)
+		assert.Empty(t, msox.ContentHash, "Content is not hashed when it is not downloaded")
+		assert.Positive(t, rangeRequests.Load())
+		assert.Less(t, bytesSent.Load(), int64(len(data)/4), "Media entry should not be downloaded")
+	})
+
+	t.Run("Servers without range support", func(t *testing.T) {
+		rangeRequests.Store(0)
+		ts := serve(false)
+		defer ts.Close()
+
+		msox := newMsox("docx", newDownloader())
+		require.NoError(t, msox.Do(context.Background(), ts.URL))
+		assert.Equal(t, "Large Report", msox.CoreProperty.Title)
+		assert.NotEmpty(t, msox.ContentHash, "Document should be downloaded")
+		assert.Zero(t, rangeRequests.Load())
+	})
+
+	t.Run("Size limit", func(t *testing.T) {
+		ts := serve(true)
+		defer ts.Close()
+
+		downloader := newDownloader()
+		downloader.MaxFileSize = 1024
+		msox := newMsox("docx", downloader)
+		err := msox.Do(context.Background(), ts.URL)
+		assert.ErrorContains(t, err, "exceeds maximum allowed size")
+	})
+}
+
+func TestRangeReader(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.ServeContent(w, r, "doc.bin", time.Time{}, strings.NewReader(content))
+	}))
+	defer ts.Close()
+
+	reader := &tRangeReader{ctx: context.Background(), client: NewClient(fetch.Options{}), url: ts.URL, size: int64(len(content))}
+
+	p := make([]byte, 5)
+	n, err := reader.ReadAt(p, 12)
+	require.NoError(t, err)
+	assert.Equal(t, "23456", string(p[:n]))
+
+	n, err = reader.ReadAt(p, 97)
+	assert.Equal(t, io.EOF, err, "Reading past the end should report EOF")
+	assert.Equal(t, "789", string(p[:n]))
+	assert.Equal(t, int64(1), requests.Load(), "Reads within the fetched block should not send requests")
+
+	_, err = reader.ReadAt(p, 100)
+	assert.Equal(t, io.EOF, err)
+}
+
+// tCountingWriter counts the bytes of the response bodies written through it
+type tCountingWriter struct {
+	http.ResponseWriter
+	n *atomic.Int64
+}
+
+func (w *tCountingWriter) Write(p []byte) (int, error) {
+	w.n.Add(int64(len(p)))
+	return w.ResponseWriter.Write(p)
+}
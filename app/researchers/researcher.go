@@ -1,82 +1,231 @@
 package researchers
 
 import (
+	"context"
+	"crypto/sha256"
+	"docscrawler/app/fetch"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"os"
+	"slices"
+	"sync"
+	"time"
 )
 
 // Constants for HTTP timeout and file size limits
 const (
-	httpGetTimeout = 30                // HTTP request timeout in seconds
-	maxFileSize    = 100 * 1024 * 1024 // Maximum file size (100MB)
+	httpGetTimeout = 30                // Default HTTP request timeout in seconds
+	maxFileSize    = 100 * 1024 * 1024 // Default maximum file size (100MB)
+	maxMemorySize  = 8 * 1024 * 1024   // Default size under which downloads are held in memory (8MB)
 )
 
+// ErrTooLarge is returned for documents over the maximum size of their downloader
+var ErrTooLarge = errors.New("file exceeds maximum allowed size")
+
+// ErrNotModified is returned for documents whose download was conditional on the validators of
+// an earlier run, and that the server reports unchanged since; their earlier metadata still holds
+var ErrNotModified = errors.New("document not modified")
+
+// tooLarge returns an ErrTooLarge mentioning the size limit
+func tooLarge(maxSize int64) error {
+	return fmt.Errorf("%w of %d bytes", ErrTooLarge, maxSize)
+}
+
+// Factory creates a researcher fetching documents with the given downloader
+// The downloader is never nil when called by New
+type Factory func(downloader *Downloader) Researcher
+
 // Map of supported file types to their researcher factory functions
-var allFileTypes = map[string]func() Researcher{
-	"pdf":  func() Researcher { return newPdf() },
-	"docx": func() Researcher { return newMsox() },
-	"xlsx": func() Researcher { return newMsox() },
-	"pptx": func() Researcher { return newMsox() },
+// Built-in types are listed here, others are added by Register
+var allFileTypes = map[string]Factory{
+	"pdf":  func(downloader *Downloader) Researcher { return newPdf(downloader) },
+	"docx": func(downloader *Downloader) Researcher { return newMsox("docx", downloader) },
+	"xlsx": func(downloader *Downloader) Researcher { return newMsox("xlsx", downloader) },
+	"pptx": func(downloader *Downloader) Researcher { return newMsox("pptx", downloader) },
+	"odt":  func(downloader *Downloader) Researcher { return newOdf("odt", downloader) },
+	"ods":  func(downloader *Downloader) Researcher { return newOdf("ods", downloader) },
+	"odp":  func(downloader *Downloader) Researcher { return newOdf("odp", downloader) },
+	"doc":  func(downloader *Downloader) Researcher { return newOle("doc", downloader) },
+	"xls":  func(downloader *Downloader) Researcher { return newOle("xls", downloader) },
+	"ppt":  func(downloader *Downloader) Researcher { return newOle("ppt", downloader) },
+}
+
+// Supported file types in the order they were registered, built-in types first
+var fileTypes = []string{"pdf", "docx", "xlsx", "pptx", "odt", "ods", "odp", "doc", "xls", "ppt"}
+
+// registryMu protects allFileTypes and fileTypes
+var registryMu sync.RWMutex
+
+// Register adds a researcher for a new file type/extension, making it known to Is, New and Types
+// Registering a type that is already supported, built-in or not, is an error;
+// types are meant to be registered at startup, before the crawl begins
+func Register(st string, factory Factory) error {
+	if st == "" || factory == nil {
+		return errors.New("file type and researcher factory are required")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exist := allFileTypes[st]; exist {
+		return fmt.Errorf("researcher for %q is already registered", st)
+	}
+	allFileTypes[st] = factory
+	fileTypes = append(fileTypes, st)
+	return nil
+}
+
+// Types returns the supported file types in the order they were registered, built-in types first
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return slices.Clone(fileTypes)
+}
+
+// Map of document MIME types to the file types of their researchers
+var mimeTypes = map[string]string{
+	"application/pdf": "pdf",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   "docx",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         "xlsx",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": "pptx",
+	"application/vnd.oasis.opendocument.text":                                   "odt",
+	"application/vnd.oasis.opendocument.spreadsheet":                            "ods",
+	"application/vnd.oasis.opendocument.presentation":                           "odp",
+	"application/msword":            "doc",
+	"application/vnd.ms-excel":      "xls",
+	"application/vnd.ms-powerpoint": "ppt",
+}
+
+// ByMimeType returns the file type whose researcher handles the given Content-Type header value
+// Returns the file type and true if the MIME type is supported, "" and false otherwise
+func ByMimeType(contentType string) (string, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	st, exist := mimeTypes[mediaType]
+	return st, exist
 }
 
 // Is checks if the specified file type/extension is supported
 func Is(st string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
 	_, exist := allFileTypes[st]
 	return exist
 }
 
 // New creates a new researcher instance for the specified file type
-func New(st string) Researcher {
+// The researcher fetches documents with the given downloader (a default downloader if nil)
+func New(st string, downloader *Downloader) Researcher {
+	if downloader == nil {
+		downloader = defaultDownloader()
+	}
+	registryMu.RLock()
 	f := allFileTypes[st]
-	return f()
+	registryMu.RUnlock()
+	return f(downloader)
+}
+
+// NewClient creates an HTTP client suited for document downloads
+// The request policy is taken from opts, the default download timeout applies if none is set
+func NewClient(opts fetch.Options) *fetch.Client {
+	if opts.Timeout == 0 {
+		opts.Timeout = httpGetTimeout * time.Second
+	}
+	return fetch.NewClient(opts)
 }
 
 // Researcher interface defines the common operations for document metadata extraction
 // Implementations should be able to analyze documents and output results as JSON
 type Researcher interface {
-	OutJSON(writer io.Writer) error // Write metadata as JSON to the provided writer
-	Do(url string) error            // Process document at the given URL
+	OutJSON(writer io.Writer) error           // Write metadata as JSON to the provided writer
+	Do(ctx context.Context, url string) error // Process document at the given URL, aborting on cancellation
+	Type() string                             // File type of the documents analysed, e.g. pdf or docx
+}
+
+// ContentHasher is implemented by researchers that know the hash of the document they analysed,
+// as the built-in ones do; documents of researchers without it are never treated as duplicates
+type ContentHasher interface {
+	Hash() string // Hex SHA-256 of the document content, "" if unknown
+}
+
+// Validators identify the version of a document as served, for conditional requests
+type Validators struct {
+	ETag         string // ETag header ("" if none)
+	LastModified string // Last-Modified header as sent ("" if none)
+}
+
+// Versioned is implemented by researchers that know the validators of the document they downloaded,
+// as the built-in ones do; the validators are kept so a later run can ask whether it changed
+type Versioned interface {
+	Validators() Validators // Validators sent by the server, empty if none
+}
+
+// Describer is implemented by researchers exposing the properties that documents of every type have,
+// as the built-in ones do; the output can be sorted by them, and they have columns of their own in SQLite
+type Describer interface {
+	DocTitle() string    // Title of the document, "" if unknown
+	DocAuthor() string   // Author of the document, "" if unknown
+	DocCreated() string  // Creation date, RFC 3339 if recognized, "" if unknown
+	DocModified() string // Last modification date, RFC 3339 if recognized, "" if unknown
+}
+
+// FileInfo describes a downloaded document as served, whatever its content
+type FileInfo struct {
+	Size         int64  // Size of the content received in bytes, 0 if nothing was downloaded
+	LastModified string // Last-Modified header, RFC 3339 when parseable, "" if none
+}
+
+// FileDescriber is implemented by researchers that know the file they downloaded, as the built-in ones do;
+// the file information is kept in the error records of documents whose content could not be read
+type FileDescriber interface {
+	File() FileInfo // Information on the downloaded file, empty if nothing was downloaded
 }
 
 // readCloserToReadSeekerFile converts an io.ReadCloser to an os.File (which implements io.ReadSeeker)
 // This is necessary because many document processing libraries require io.ReadSeeker functionality
-// The function creates a temporary file, copies content from the reader, and returns the file
+// The function creates a temporary file in dir (the OS temp directory if empty), copies at most
+// maxSize bytes from the reader (unlimited if zero), and returns the file with the hex SHA-256
+// of its content, hashed while copying; on error the temporary file is already removed
 // Caller is responsible for closing and removing the temporary file when finished
-func readCloserToReadSeekerFile(rc io.ReadCloser) (*os.File, error) {
+func readCloserToReadSeekerFile(rc io.ReadCloser, dir string, maxSize int64) (_ *os.File, _ string, err error) {
 
 	// Create a temporary file
-	tmpFile, err := os.CreateTemp("", "readseeker-*")
+	tmpFile, err := os.CreateTemp(dir, "readseeker-*")
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	defer func() {
+		if err != nil {
+			tmpFile.Close()
+			os.Remove(tmpFile.Name())
+		}
+	}()
 
-	// Copy data with size limit
-	limitedReader := &io.LimitedReader{R: rc, N: maxFileSize}
-	_, err = io.Copy(tmpFile, limitedReader)
+	// Copy data with size limit, reading one byte past it to detect oversized files
+	var src io.Reader = rc
+	if maxSize > 0 {
+		src = io.LimitReader(rc, maxSize+1)
+	}
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tmpFile, hash), src)
 	if err != nil {
-		tmpFileName := tmpFile.Name()
-		tmpFile.Close()
-		os.Remove(tmpFileName)
-		return nil, err
+		return nil, "", err
 	}
 
-	// Check if size limit was reached (indicates file is too large)
-	if limitedReader.N == 0 {
-		tmpFileName := tmpFile.Name()
-		tmpFile.Close()
-		os.Remove(tmpFileName)
-		return nil, fmt.Errorf("file exceeds maximum allowed size of %d bytes", maxFileSize)
+	// Check if size limit was exceeded
+	if maxSize > 0 && written > maxSize {
+		return nil, "", tooLarge(maxSize)
 	}
 
 	// Seek to beginning of file
 	_, err = tmpFile.Seek(0, io.SeekStart)
 	if err != nil {
-		tmpFileName := tmpFile.Name()
-		tmpFile.Close()
-		os.Remove(tmpFileName)
-		return nil, err
+		return nil, "", err
 	}
 
-	return tmpFile, nil
+	return tmpFile, hex.EncodeToString(hash.Sum(nil)), nil
 }
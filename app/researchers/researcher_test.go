@@ -2,8 +2,13 @@ package researchers
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,7 +18,7 @@ import (
 func TestResearcherInterfaces(t *testing.T) {
 	// Test if the file types are properly registered
 	t.Run("Check registered file types", func(t *testing.T) {
-		expectedTypes := []string{"pdf", "docx", "xlsx", "pptx"}
+		expectedTypes := []string{"pdf", "docx", "xlsx", "pptx", "odt", "ods", "odp", "doc", "xls", "ppt"}
 
 		for _, fileType := range expectedTypes {
 			assert.True(t, Is(fileType), "Type %s should be registered", fileType)
@@ -24,25 +29,100 @@ func TestResearcherInterfaces(t *testing.T) {
 
 	t.Run("Factory method returns correct types", func(t *testing.T) {
 		// PDF researcher
-		pdfResearcher := New("pdf")
+		pdfResearcher := New("pdf", nil)
 		assert.NotNil(t, pdfResearcher, "PDF researcher should not be nil")
 		assert.IsType(t, &tPdf{}, pdfResearcher, "Should return PDF researcher type")
 
 		// MSOX researchers (docx, xlsx, pptx)
-		docxResearcher := New("docx")
+		docxResearcher := New("docx", nil)
 		assert.NotNil(t, docxResearcher, "DOCX researcher should not be nil")
 		assert.IsType(t, &tMsox{}, docxResearcher, "Should return MSOX researcher type")
 
-		xlsxResearcher := New("xlsx")
+		xlsxResearcher := New("xlsx", nil)
 		assert.NotNil(t, xlsxResearcher, "XLSX researcher should not be nil")
 		assert.IsType(t, &tMsox{}, xlsxResearcher, "Should return MSOX researcher type")
 
-		pptxResearcher := New("pptx")
+		pptxResearcher := New("pptx", nil)
 		assert.NotNil(t, pptxResearcher, "PPTX researcher should not be nil")
 		assert.IsType(t, &tMsox{}, pptxResearcher, "Should return MSOX researcher type")
+
+		// OpenDocument researchers (odt, ods, odp)
+		for _, fileType := range []string{"odt", "ods", "odp"} {
+			odfResearcher := New(fileType, nil)
+			assert.NotNil(t, odfResearcher, "%s researcher should not be nil", fileType)
+			assert.IsType(t, &tOdf{}, odfResearcher, "Should return ODF researcher type")
+		}
+
+		// Legacy Office researchers (doc, xls, ppt)
+		for _, fileType := range []string{"doc", "xls", "ppt"} {
+			oleResearcher := New(fileType, nil)
+			assert.NotNil(t, oleResearcher, "%s researcher should not be nil", fileType)
+			assert.IsType(t, &tOle{}, oleResearcher, "Should return OLE researcher type")
+		}
 	})
 }
 
+func TestResearcherDocType(t *testing.T) {
+	// Every researcher reports the file type it was created for, not its family
+	for st := range allFileTypes {
+		t.Run(st, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, New(st, nil).OutJSON(&buf))
+			assert.Contains(t, buf.String(), `"doc_type":"`+st+`"`, "JSON should contain the file type")
+		})
+	}
+}
+
+func TestResearcherDescriber(t *testing.T) {
+	// Every built-in researcher exposes the title and modification date of its documents
+	for st := range allFileTypes {
+		_, ok := New(st, nil).(Describer)
+		assert.True(t, ok, "%s researcher should implement Describer", st)
+	}
+
+	msox := newMsox("docx", nil)
+	msox.CoreProperty.Title = "Report"
+	msox.CoreProperty.Modified = "2024-05-01T12:00:00Z"
+	assert.Equal(t, "Report", msox.DocTitle())
+	assert.Equal(t, "2024-05-01T12:00:00Z", msox.DocModified())
+
+	pdf := newPdf(nil)
+	pdf.Title = "Manual"
+	pdf.ModDate = "2023-01-02T03:04:05+02:00"
+	assert.Equal(t, "Manual", pdf.DocTitle())
+	assert.Equal(t, "2023-01-02T03:04:05+02:00", pdf.DocModified())
+}
+
+func TestByMimeType(t *testing.T) {
+	testCases := []struct {
+		contentType string
+		expected    string
+		supported   bool
+	}{
+		{contentType: "application/pdf", expected: "pdf", supported: true},
+		{contentType: "Application/PDF", expected: "pdf", supported: true},
+		{contentType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", expected: "docx", supported: true},
+		{contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet; charset=binary", expected: "xlsx", supported: true},
+		{contentType: "application/vnd.oasis.opendocument.presentation", expected: "odp", supported: true},
+		{contentType: "application/msword", expected: "doc", supported: true},
+		{contentType: "application/vnd.ms-excel", expected: "xls", supported: true},
+		{contentType: "text/html; charset=utf-8", supported: false},
+		{contentType: "application/octet-stream", supported: false},
+		{contentType: "", supported: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.contentType, func(t *testing.T) {
+			st, ok := ByMimeType(tc.contentType)
+			assert.Equal(t, tc.supported, ok)
+			assert.Equal(t, tc.expected, st)
+			if ok {
+				assert.True(t, Is(st), "MIME type should map to a registered file type")
+			}
+		})
+	}
+}
+
 func TestReadCloserToReadSeekerFile(t *testing.T) {
 	t.Run("Successful conversion", func(t *testing.T) {
 		// Create a ReadCloser with test data
@@ -50,7 +130,7 @@ func TestReadCloserToReadSeekerFile(t *testing.T) {
 		reader := io.NopCloser(bytes.NewReader(testData))
 
 		// Convert to ReadSeeker
-		readSeeker, err := readCloserToReadSeekerFile(reader)
+		readSeeker, _, err := readCloserToReadSeekerFile(reader, "", maxFileSize)
 		require.NoError(t, err, "Should convert without error")
 		require.NotNil(t, readSeeker, "ReadSeeker should not be nil")
 
@@ -63,25 +143,65 @@ func TestReadCloserToReadSeekerFile(t *testing.T) {
 		assert.Contains(t, tmpFileName, os.TempDir(), "Temp file should be created in temp directory")
 	})
 
+	t.Run("Content hash", func(t *testing.T) {
+		readSeeker, hash, err := readCloserToReadSeekerFile(io.NopCloser(strings.NewReader("hello")), "", maxFileSize)
+		require.NoError(t, err)
+		readSeeker.Close()
+		os.Remove(readSeeker.Name())
+		assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", hash, "SHA-256 of the content")
+	})
+
 	t.Run("File size limit", func(t *testing.T) {
 		// Create a ReadCloser with data larger than the limit
 		oversizedData := make([]byte, maxFileSize+1)
 		reader := io.NopCloser(bytes.NewReader(oversizedData))
 
 		// Try to convert to ReadSeeker
-		readSeeker, err := readCloserToReadSeekerFile(reader)
+		readSeeker, _, err := readCloserToReadSeekerFile(reader, "", maxFileSize)
 		assert.Error(t, err, "Should return error for oversized file")
 		assert.Nil(t, readSeeker, "ReadSeeker should be nil for oversized file")
 		assert.Contains(t, err.Error(), "exceeds maximum allowed size", "Error should mention size limit")
 	})
 
+	t.Run("Configurable size limit", func(t *testing.T) {
+		// Exactly at the limit is allowed
+		readSeeker, _, err := readCloserToReadSeekerFile(io.NopCloser(bytes.NewReader(make([]byte, 10))), "", 10)
+		require.NoError(t, err, "File at the size limit should be accepted")
+		readSeeker.Close()
+		os.Remove(readSeeker.Name())
+
+		// One byte over is rejected
+		readSeeker, _, err = readCloserToReadSeekerFile(io.NopCloser(bytes.NewReader(make([]byte, 11))), "", 10)
+		assert.Error(t, err, "File over the size limit should be rejected")
+		assert.Nil(t, readSeeker)
+		assert.Contains(t, err.Error(), "maximum allowed size of 10 bytes")
+
+		// Zero means unlimited
+		readSeeker, _, err = readCloserToReadSeekerFile(io.NopCloser(bytes.NewReader(make([]byte, 1000))), "", 0)
+		require.NoError(t, err, "Zero limit should accept any size")
+		readSeeker.Close()
+		os.Remove(readSeeker.Name())
+	})
+
+	t.Run("Temporary directory", func(t *testing.T) {
+		dir := t.TempDir()
+		readSeeker, _, err := readCloserToReadSeekerFile(io.NopCloser(strings.NewReader("hello")), dir, maxFileSize)
+		require.NoError(t, err)
+		defer os.Remove(readSeeker.Name())
+		defer readSeeker.Close()
+		assert.Equal(t, dir, filepath.Dir(readSeeker.Name()), "Temp file should be created in the given directory")
+
+		_, _, err = readCloserToReadSeekerFile(io.NopCloser(strings.NewReader("hello")), filepath.Join(dir, "missing"), maxFileSize)
+		assert.Error(t, err, "Missing directory should be reported")
+	})
+
 	t.Run("File operations", func(t *testing.T) {
 		// Create a small file for testing
 		testData := []byte("File operation test data")
 		reader := io.NopCloser(bytes.NewReader(testData))
 
 		// Convert to ReadSeeker
-		readSeeker, err := readCloserToReadSeekerFile(reader)
+		readSeeker, _, err := readCloserToReadSeekerFile(reader, "", maxFileSize)
 		require.NoError(t, err, "Should convert without error")
 
 		// Test seeking and reading
@@ -119,8 +239,77 @@ func TestReadCloserToReadSeekerFileErrors(t *testing.T) {
 		reader := &errorReader{}
 
 		// Try to convert to ReadSeeker
-		readSeeker, err := readCloserToReadSeekerFile(reader)
+		readSeeker, _, err := readCloserToReadSeekerFile(reader, "", maxFileSize)
 		assert.Error(t, err, "Should return error when read fails")
 		assert.Nil(t, readSeeker, "ReadSeeker should be nil when read fails")
 	})
+
+	t.Run("Temporary file removed", func(t *testing.T) {
+		dir := t.TempDir()
+		_, _, err := readCloserToReadSeekerFile(&errorReader{}, dir, maxFileSize)
+		require.Error(t, err)
+		_, _, err = readCloserToReadSeekerFile(io.NopCloser(bytes.NewReader(make([]byte, 11))), dir, 10)
+		require.Error(t, err)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "No temp file should be left behind on errors")
+	})
+}
+
+// tCustomResearcher stands for a researcher registered by a user of the package
+type tCustomResearcher struct {
+	downloader *Downloader
+}
+
+func (r *tCustomResearcher) OutJSON(writer io.Writer) error           { return nil }
+func (r *tCustomResearcher) Do(ctx context.Context, url string) error { return nil }
+func (r *tCustomResearcher) Type() string                             { return "custom" }
+
+func TestRegister(t *testing.T) {
+	t.Cleanup(func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		delete(allFileTypes, "custom")
+		fileTypes = slices.DeleteFunc(fileTypes, func(st string) bool { return st == "custom" })
+	})
+
+	factory := func(downloader *Downloader) Researcher { return &tCustomResearcher{downloader: downloader} }
+
+	require.False(t, Is("custom"))
+	require.NoError(t, Register("custom", factory))
+
+	assert.True(t, Is("custom"), "Registered type should be supported")
+	types := Types()
+	assert.Equal(t, "pdf", types[0], "Built-in types should come first")
+	assert.Equal(t, "custom", types[len(types)-1], "Registered type should be listed last")
+
+	researcher := New("custom", nil)
+	require.IsType(t, &tCustomResearcher{}, researcher)
+	assert.NotNil(t, researcher.(*tCustomResearcher).downloader, "Factory should be given the default downloader")
+
+	assert.EqualError(t, Register("custom", factory), `researcher for "custom" is already registered`)
+	assert.EqualError(t, Register("pdf", factory), `researcher for "pdf" is already registered`, "Built-in types cannot be replaced")
+	assert.Error(t, Register("", factory))
+	assert.Error(t, Register("other", nil))
+	assert.False(t, Is("other"))
+}
+
+func TestTypes(t *testing.T) {
+	types := Types()
+	assert.ElementsMatch(t, slices.Collect(maps.Keys(allFileTypes)), types, "Every supported type should be listed once")
+
+	types[0] = "changed"
+	assert.Equal(t, "pdf", Types()[0], "Returned list should be a copy")
+}
+
+func TestResearcherType(t *testing.T) {
+	for _, st := range []string{"pdf", "docx", "xlsx", "pptx", "odt", "ods", "odp", "doc", "xls", "ppt"} {
+		researcher := New(st, nil)
+		assert.Equal(t, st, researcher.Type(), "Researcher should report the specific format it was created for")
+
+		var buf bytes.Buffer
+		require.NoError(t, researcher.OutJSON(&buf))
+		assert.Contains(t, buf.String(), `"doc_type":"`+st+`"`, "JSON should contain the type")
+	}
 }
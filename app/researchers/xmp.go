@@ -0,0 +1,138 @@
+package researchers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+// Namespace of the RDF elements structuring an XMP packet
+const rdfNS = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+// Conventional prefixes of the common XMP namespaces, used when a packet does not declare its own
+var xmpPrefixes = map[string]string{
+	"http://purl.org/dc/elements/1.1/":            "dc",
+	"http://ns.adobe.com/xap/1.0/":                "xmp",
+	"http://ns.adobe.com/xap/1.0/mm/":             "xmpMM",
+	"http://ns.adobe.com/xap/1.0/rights/":         "xmpRights",
+	"http://ns.adobe.com/pdf/1.3/":                "pdf",
+	"http://www.aiim.org/pdfa/ns/id/":             "pdfaid",
+	"http://www.aiim.org/pdfua/ns/id/":            "pdfuaid",
+	"http://ns.adobe.com/pdfx/1.3/":               "pdfx",
+	"http://ns.adobe.com/photoshop/1.0/":          "photoshop",
+	"http://www.w3.org/XML/1998/namespace":        "xml",
+	"http://www.w3.org/1999/02/22-rdf-syntax-ns#": "rdf",
+}
+
+// tXmpNode is an element of an XMP packet, decoded generically
+type tXmpNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []tXmpNode `xml:",any"`
+}
+
+// parseXmp extracts the properties of an XMP packet, keyed by their prefixed names (e.g. dc:title)
+// Simple values and language alternatives (the x-default one, or the first) are strings,
+// ordered and unordered arrays are lists of strings; structured values are left out
+// Returns nil if the packet holds no property
+func parseXmp(packet []byte) (map[string]any, error) {
+	var root tXmpNode
+	err := xml.NewDecoder(bytes.NewReader(packet)).Decode(&root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefixes declared in the packet take precedence over the conventional ones
+	prefixes := make(map[string]string)
+	root.walk(func(node *tXmpNode) {
+		for _, attr := range node.Attrs {
+			if attr.Name.Space == "xmlns" {
+				prefixes[attr.Value] = attr.Name.Local
+			}
+		}
+	})
+	name := func(n xml.Name) string {
+		prefix, ok := prefixes[n.Space]
+		if !ok {
+			prefix, ok = xmpPrefixes[n.Space]
+		}
+		if !ok {
+			return n.Local
+		}
+		return prefix + ":" + n.Local
+	}
+
+	props := make(map[string]any)
+	root.walk(func(node *tXmpNode) {
+		if node.XMLName != (xml.Name{Space: rdfNS, Local: "Description"}) {
+			return
+		}
+		// Properties are written either as attributes or as child elements
+		for _, attr := range node.Attrs {
+			if attr.Name.Space == "xmlns" || attr.Name.Space == rdfNS || attr.Name.Space == "" {
+				continue
+			}
+			props[name(attr.Name)] = attr.Value
+		}
+		for _, child := range node.Children {
+			if value := child.value(); value != nil {
+				props[name(child.XMLName)] = value
+			}
+		}
+	})
+
+	if len(props) == 0 {
+		return nil, nil
+	}
+	return props, nil
+}
+
+// walk calls the function for the node and all the elements below it
+func (node *tXmpNode) walk(f func(node *tXmpNode)) {
+	f(node)
+	for i := range node.Children {
+		node.Children[i].walk(f)
+	}
+}
+
+// value returns the value of a property element: a string or a list of strings,
+// or nil for structured values
+func (node *tXmpNode) value() any {
+	if len(node.Children) == 0 {
+		for _, attr := range node.Attrs {
+			if attr.Name == (xml.Name{Space: rdfNS, Local: "resource"}) {
+				return attr.Value
+			}
+		}
+		return strings.TrimSpace(node.Text)
+	}
+	if len(node.Children) != 1 || node.Children[0].XMLName.Space != rdfNS {
+		return nil
+	}
+
+	container := node.Children[0]
+	switch container.XMLName.Local {
+	case "Alt":
+		value := ""
+		for i, li := range container.Children {
+			text := strings.TrimSpace(li.Text)
+			if i == 0 {
+				value = text
+			}
+			for _, attr := range li.Attrs {
+				if attr.Name.Local == "lang" && attr.Value == "x-default" {
+					return text
+				}
+			}
+		}
+		return value
+	case "Seq", "Bag":
+		values := make([]string, 0, len(container.Children))
+		for _, li := range container.Children {
+			values = append(values, strings.TrimSpace(li.Text))
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package researchers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseXmp(t *testing.T) {
+	t.Run("Properties", func(t *testing.T) {
+		packet := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/" pdfaid:part="1" pdfaid:conformance="A"/>
+  <rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmlns:my="http://example.com/my/">
+   <dc:description><rdf:Alt><rdf:li xml:lang="de">Bericht</rdf:li><rdf:li xml:lang="x-default">Report</rdf:li></rdf:Alt></dc:description>
+   <dc:creator><rdf:Seq><rdf:li>Jane Roe</rdf:li></rdf:Seq></dc:creator>
+   <xmp:CreatorTool> Writer </xmp:CreatorTool>
+   <my:Source rdf:resource="https://example.com/source"/>
+   <my:Structured rdf:parseType="Resource"><my:A>1</my:A><my:B>2</my:B></my:Structured>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`)
+
+		props, err := parseXmp(packet)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{
+			"pdfaid:part":        "1",
+			"pdfaid:conformance": "A",
+			"dc:description":     "Report",
+			"dc:creator":         []string{"Jane Roe"},
+			"xmp:CreatorTool":    "Writer",
+			"my:Source":          "https://example.com/source",
+		}, props, "Structured values should be left out")
+	})
+
+	t.Run("Conventional prefixes", func(t *testing.T) {
+		packet := []byte(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description><CreatorTool xmlns="http://ns.adobe.com/xap/1.0/">Writer</CreatorTool></rdf:Description>
+</rdf:RDF>`)
+
+		props, err := parseXmp(packet)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"xmp:CreatorTool": "Writer"}, props)
+	})
+
+	t.Run("Empty packet", func(t *testing.T) {
+		props, err := parseXmp([]byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"/></x:xmpmeta>`))
+		require.NoError(t, err)
+		assert.Nil(t, props)
+	})
+
+	t.Run("Malformed packet", func(t *testing.T) {
+		_, err := parseXmp([]byte(`<x:xmpmeta><rdf:RDF>`))
+		assert.Error(t, err)
+	})
+}
+
+func TestPdfXmp(t *testing.T) {
+	serve := func(t *testing.T, name string) *httptest.Server {
+		pdfData, err := os.ReadFile(name)
+		require.NoError(t, err)
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(pdfData)
+		}))
+	}
+
+	t.Run("Document with XMP", func(t *testing.T) {
+		ts := serve(t, "testdata/xmp.pdf")
+		defer ts.Close()
+
+		pdf := newPdf(nil)
+		require.NoError(t, pdf.Do(context.Background(), ts.URL))
+		assert.Equal(t, 1, pdf.PageCount, "Document information should still be read")
+		assert.Equal(t, "2024-03-01T10:00:00+02:00", pdf.CreationDate, "Dates should be normalized to RFC 3339")
+		assert.Equal(t, "D:20240301100000+02'00'", pdf.CreationDateRaw, "Dates should be kept as written")
+		assert.Equal(t, "2024-03-02T00:00:00Z", pdf.ModDate)
+		assert.Equal(t, "Annual Report 2024", pdf.Xmp["dc:title"])
+		assert.Equal(t, "Financial statements", pdf.Xmp["dc:description"])
+		assert.Equal(t, []string{"Jane Roe", "John Doe"}, pdf.Xmp["dc:creator"])
+		assert.Equal(t, []string{"finance", "report"}, pdf.Xmp["dc:subject"])
+		assert.Equal(t, "Writer 7.5", pdf.Xmp["xmp:CreatorTool"])
+		assert.Equal(t, "2", pdf.Xmp["pdfaid:part"])
+		assert.Equal(t, "B", pdf.Xmp["pdfaid:conformance"])
+		assert.Equal(t, "Compliance Office", pdf.Xmp["audit:Reviewer"], "Custom properties should be kept")
+
+		var buf bytes.Buffer
+		require.NoError(t, pdf.OutJSON(&buf))
+		assert.Contains(t, buf.String(), `"xmp":{`)
+		assert.Contains(t, buf.String(), `"pdfaid:conformance":"B"`)
+	})
+
+	t.Run("Document without XMP", func(t *testing.T) {
+		ts := serve(t, "testdata/sample.pdf")
+		defer ts.Close()
+
+		pdf := newPdf(nil)
+		require.NoError(t, pdf.Do(context.Background(), ts.URL))
+		assert.Nil(t, pdf.Xmp)
+
+		var buf bytes.Buffer
+		require.NoError(t, pdf.OutJSON(&buf))
+		assert.NotContains(t, buf.String(), `"xmp"`)
+	})
+}